@@ -1,41 +1,125 @@
 package builder
 
 import (
+	"slices"
 	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 )
 
-// Match adds a $match stage to the pipeline (supports expressions).
-func (qb *QueryBuilder) Match(condition string) *QueryBuilder {
-	filter, err := qb.parseExpression(condition)
-	if err != nil {
-		filter = qb.parseConditions(condition) // Fallback to simple conditions
+// Match adds a $match stage to the pipeline (supports expressions). Values can be bound to ?
+// and :name placeholders instead of being written into the condition, keeping their Go types:
+//
+//	qb.Match("amount > ? AND status IN ?", 1000, []string{"active", "trial"})
+func (qb *QueryBuilder) Match(condition string, args ...interface{}) *QueryBuilder {
+	qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$match", Value: qb.bindParams(condition, args, qb.parseConditions)}})
+	return qb
+}
+
+// GroupBy adds a $group stage to the pipeline. Aggregates like "SUM(amount) AS total" become
+// accumulators keyed by their alias and the other fields are grouped on: a single field becomes
+// the _id, several fields a compound _id like {country: "$country", city: "$city"}. A $set stage
+// following the $group copies the grouped keys back under their field names.
+func (qb *QueryBuilder) GroupBy(fields ...string) *QueryBuilder {
+	keys := []string{}
+	group := bson.M{}
+	qb.aggregateFields = nil
+	for _, field := range fields {
+		if aggregation, err := qb.parseAggregation(field); err == nil {
+			group[qb.parseAlias(field)] = aggregation
+			qb.recordAggregate(qb.parseAlias(field), aggregation)
+			continue
+		}
+		keys = append(keys, qb.resolveField(field))
+	}
+
+	set := bson.M{}
+	switch len(keys) {
+	case 0:
+		group["_id"] = nil
+	case 1:
+		group["_id"] = "$" + keys[0]
+		set[keys[0]] = "$_id"
+	default:
+		id := bson.D{}
+		for _, field := range keys {
+			id = append(id, bson.E{Key: groupKey(field), Value: "$" + field})
+			set[field] = "$_id." + groupKey(field)
+		}
+		group["_id"] = id
+	}
+
+	qb.Group = group
+	qb.groupKeys = keys
+	qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$group", Value: group}})
+	if len(set) > 0 {
+		qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$set", Value: set}})
+	}
+	return qb
+}
+
+// SelectGrouped projects grouped documents onto the select list of a GROUP BY query: the group
+// keys and aggregates under their result keys, without the _id.
+func (qb *QueryBuilder) SelectGrouped(fields ...string) *QueryBuilder {
+	projection := bson.M{"_id": 0}
+	for _, field := range fields {
+		expression, _, aliased := splitAlias(strings.TrimSpace(field))
+		if aliased && !IsAggregate(field) {
+			projection[qb.ResultKey(field)] = "$" + qb.resolveField(expression)
+			continue
+		}
+		projection[qb.ResultKey(field)] = 1
 	}
-	qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$match", Value: filter}})
+	qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$project", Value: projection}})
 	return qb
 }
 
-// GroupBy adds a $group stage to the pipeline.
-func (qb *QueryBuilder) GroupBy(field string) *QueryBuilder {
-	qb.Group = bson.M{"_id": "$" + field}
-	qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$group", Value: qb.Group}})
+// IsGroupKey reports whether a selected field, optionally aliased, is one of the GroupBy fields.
+func (qb *QueryBuilder) IsGroupKey(field string) bool {
+	expression, _, _ := splitAlias(strings.TrimSpace(field))
+	return slices.Contains(qb.groupKeys, qb.resolveField(expression))
+}
+
+// groupKey returns the key of a field in a compound _id. Dotted paths ("address.city") become
+// "address_city", since field names inside expressions cannot contain dots.
+func groupKey(field string) string {
+	return strings.ReplaceAll(field, ".", "_")
+}
+
+// GroupAll groups all documents into a single row of aggregations, like an SQL select list of
+// aggregate functions without GROUP BY, e.g. GroupAll("COUNT(*)", "SUM(amount) AS total").
+func (qb *QueryBuilder) GroupAll(aggregations ...string) *QueryBuilder {
+	group := bson.M{"_id": nil}
+	qb.aggregateFields = nil
+	for _, agg := range aggregations {
+		aggregation, err := qb.parseAggregation(agg)
+		if err != nil {
+			qb.fail("unsupported aggregation %q: %v", agg, err)
+			continue
+		}
+		group[qb.parseAlias(agg)] = aggregation
+		qb.recordAggregate(qb.parseAlias(agg), aggregation)
+	}
+	qb.Group = group
+	qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$group", Value: group}})
 	return qb
 }
 
-// OrderBy adds a $sort stage to the pipeline.
-func (qb *QueryBuilder) OrderBy(order string) *QueryBuilder {
-	sort := bson.M{}
-	parts := strings.Split(order, " ")
-	if len(parts) == 2 {
-		direction := 1
-		if strings.ToUpper(parts[1]) == "DESC" {
-			direction = -1
+// OrderBy adds a $sort stage to the pipeline, sorting on the items in order, given as separate
+// arguments or comma-separated ("status ASC, created_at DESC"). A key may be a field or an
+// arithmetic expression ("price * qty DESC"), optionally followed by NULLS FIRST or NULLS LAST.
+func (qb *QueryBuilder) OrderBy(orders ...string) *QueryBuilder {
+	items := []sortItem{}
+	for _, order := range orders {
+		for _, item := range splitTopLevel(order) {
+			if strings.TrimSpace(item) != "" {
+				items = append(items, parseSortItem(item))
+			}
 		}
-		sort[parts[0]] = direction
 	}
-	qb.Sort = sort
-	qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$sort", Value: qb.Sort}})
+	if len(items) > 0 {
+		qb.appendSort(items)
+	}
 	return qb
 }
 
@@ -55,3 +139,47 @@ func (qb *QueryBuilder) AggregationOffset(n int64) *QueryBuilder {
 	}
 	return qb
 }
+
+// LimitPer keeps at most n documents for each value of field (grouped top-N), in the order of
+// the sort ending the pipeline, e.g. OrderBy("amount DESC").LimitPer(3, "customerId"), or of _id
+// without one. The documents are numbered per field with $setWindowFields, which needs MongoDB
+// 5.0, and sorted again afterwards.
+func (qb *QueryBuilder) LimitPer(n int64, field string) *QueryBuilder {
+	if n <= 0 {
+		return qb
+	}
+	// The helper fields of computed sort keys are unset after the sort, so number before that
+	tail := len(qb.Pipeline)
+	if stageValue(qb.Pipeline, tail-1, "$unset") != nil {
+		tail--
+	}
+	sort := bson.D{{Key: "_id", Value: 1}}
+	if keys, ok := stageValue(qb.Pipeline, tail-1, "$sort").(bson.D); ok {
+		sort = keys
+	} else {
+		tail = len(qb.Pipeline)
+	}
+	rank := []bson.D{
+		{{Key: "$setWindowFields", Value: bson.M{
+			"partitionBy": "$" + qb.resolveField(field),
+			"sortBy":      sort,
+			"output":      bson.M{limitPerRankField: bson.M{"$documentNumber": bson.M{}}},
+		}}},
+		{{Key: "$match", Value: bson.M{limitPerRankField: bson.M{"$lte": n}}}},
+		{{Key: "$sort", Value: sort}},
+		{{Key: "$unset", Value: limitPerRankField}},
+	}
+	qb.Pipeline = append(append(append([]bson.D{}, qb.Pipeline[:tail]...), rank...), qb.Pipeline[tail:]...)
+	return qb
+}
+
+// stageValue returns the body of stage i of pipeline when its operator is operator, else nil.
+func stageValue(pipeline []bson.D, i int, operator string) interface{} {
+	if i < 0 || i >= len(pipeline) || len(pipeline[i]) == 0 || pipeline[i][0].Key != operator {
+		return nil
+	}
+	return pipeline[i][0].Value
+}
+
+// limitPerRankField holds the number of a document within its LimitPer partition.
+const limitPerRankField = "__limitPerRank"
@@ -0,0 +1,291 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TenantField is the field queries, updates and deletes whose context carries a tenant filter
+// on, and inserts set; empty to disable.
+var TenantField = "tenant_id"
+
+// CreatedByField and UpdatedByField record the actor of the context on inserted and updated
+// documents; empty to disable.
+var (
+	CreatedByField = "created_by"
+	UpdatedByField = "updated_by"
+)
+
+// Annotations describe who runs an operation and why, carried in its context:
+//
+//	ctx = builder.WithTenant(builder.WithActor(ctx, "alice"), "acme")
+//	qb.WithContext(ctx).Execute(db) // Filters on tenant_id = 'acme'
+//
+// The builders filter on the tenant (see TenantField), record the actor on written documents
+// (see CreatedByField) and send every annotation as the comment of the command, so it shows
+// in the profiler and the slow query log.
+type Annotations struct {
+	Actor     string
+	Tenant    string
+	RequestID string
+	Flags     map[string]bool // Feature flags, by name
+}
+
+// annotationsKey is the context key of Annotations.
+type annotationsKey struct{}
+
+// WithAnnotations returns a copy of ctx carrying a.
+func WithAnnotations(ctx context.Context, a Annotations) context.Context {
+	return context.WithValue(orBackground(ctx), annotationsKey{}, a)
+}
+
+// AnnotationsFrom returns the annotations of ctx, zero when it has none.
+func AnnotationsFrom(ctx context.Context) Annotations {
+	if ctx == nil {
+		return Annotations{}
+	}
+	a, _ := ctx.Value(annotationsKey{}).(Annotations)
+	return a
+}
+
+// WithActor returns a copy of ctx annotated with the user or service running the operation.
+func WithActor(ctx context.Context, actor string) context.Context {
+	a := AnnotationsFrom(ctx)
+	a.Actor = actor
+	return WithAnnotations(ctx, a)
+}
+
+// WithTenant returns a copy of ctx annotated with the tenant the operation is confined to.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	a := AnnotationsFrom(ctx)
+	a.Tenant = tenant
+	return WithAnnotations(ctx, a)
+}
+
+// WithRequestID returns a copy of ctx annotated with the ID of the request running the operation.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	a := AnnotationsFrom(ctx)
+	a.RequestID = id
+	return WithAnnotations(ctx, a)
+}
+
+// WithFlag returns a copy of ctx annotated with a feature flag.
+func WithFlag(ctx context.Context, name string, enabled bool) context.Context {
+	a := AnnotationsFrom(ctx)
+	a.Flags = maps.Clone(a.Flags)
+	if a.Flags == nil {
+		a.Flags = map[string]bool{}
+	}
+	a.Flags[name] = enabled
+	return WithAnnotations(ctx, a)
+}
+
+// Flag reports whether the feature flag name is enabled.
+func (a Annotations) Flag(name string) bool {
+	return a.Flags[name]
+}
+
+// Comment returns the annotations as a command comment like
+// "actor=alice tenant=acme request=r-42 flags=beta,new-ui", empty without annotations.
+func (a Annotations) Comment() string {
+	parts := []string{}
+	for _, part := range []struct{ key, value string }{{"actor", a.Actor}, {"tenant", a.Tenant}, {"request", a.RequestID}} {
+		if part.value != "" {
+			parts = append(parts, part.key+"="+part.value)
+		}
+	}
+	flags := []string{}
+	for name, enabled := range a.Flags {
+		if enabled {
+			flags = append(flags, name)
+		}
+	}
+	if len(flags) > 0 {
+		slices.Sort(flags)
+		parts = append(parts, "flags="+strings.Join(flags, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// tenantFilter returns filter restricted to the tenant.
+func tenantFilter(filter bson.M, tenant string) bson.M {
+	match := bson.M{TenantField: tenant}
+	if len(filter) == 0 {
+		return match
+	}
+	return bson.M{"$and": []bson.M{filter, match}}
+}
+
+// annotatedPipeline returns pipeline confined to the tenant of ctx, if any: led by a $match on
+// the tenant, which also leads the pipelines of its joins, unions and subqueries, since they
+// read other collections. A stage reading another collection in a form that cannot be
+// confined is an error, rather than a query returning the documents of other tenants.
+func annotatedPipeline(ctx context.Context, pipeline []bson.D) ([]bson.D, error) {
+	a := AnnotationsFrom(ctx)
+	if a.Tenant == "" || TenantField == "" {
+		return pipeline, nil
+	}
+	confined, err := tenantPipeline(pipeline, a.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	match := bson.D{{Key: "$match", Value: tenantFilter(nil, a.Tenant)}}
+	return append([]bson.D{match}, confined...), nil
+}
+
+// tenantPipeline returns a copy of pipeline whose stages reading other collections read only
+// the documents of tenant. The stages of pipeline are left unchanged.
+func tenantPipeline(pipeline []bson.D, tenant string) ([]bson.D, error) {
+	confined := make([]bson.D, len(pipeline))
+	for i, stage := range pipeline {
+		confined[i] = make(bson.D, len(stage))
+		for j, e := range stage {
+			value, err := tenantStage(e.Key, e.Value, tenant)
+			if err != nil {
+				return nil, err
+			}
+			confined[i][j] = bson.E{Key: e.Key, Value: value}
+		}
+	}
+	return confined, nil
+}
+
+// tenantStage returns the value of the stage name confined to tenant: $lookup and $unionWith
+// get the tenant's $match leading their pipeline, $graphLookup restricts its search to the
+// tenant and the pipelines of $facet are confined in turn.
+func tenantStage(name string, value interface{}, tenant string) (interface{}, error) {
+	switch name {
+	case "$lookup", "$unionWith":
+		if collection, ok := value.(string); ok && name == "$unionWith" {
+			value = bson.M{"coll": collection}
+		}
+		spec, reads, pipeline := bson.D{}, false, []bson.D{}
+		var err error
+		forEachField(value, func(key string, value interface{}) {
+			switch key {
+			case "from", "coll":
+				reads = true
+			case "pipeline":
+				if pipeline = subPipeline(value); pipeline == nil {
+					err = fmt.Errorf("cannot confine the pipeline of %s to the tenant", name)
+				}
+				return
+			}
+			spec = append(spec, bson.E{Key: key, Value: value})
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(spec) == 0 {
+			return nil, fmt.Errorf("cannot confine %s to the tenant", name)
+		}
+		confined, err := tenantPipeline(pipeline, tenant)
+		if err != nil {
+			return nil, err
+		}
+		if reads { // Without a collection the pipeline reads $documents, which must stay its first stage
+			confined = append([]bson.D{{{Key: "$match", Value: tenantFilter(nil, tenant)}}}, confined...)
+		}
+		return append(spec, bson.E{Key: "pipeline", Value: confined}), nil
+	case "$graphLookup":
+		spec, restricted := bson.D{}, false
+		forEachField(value, func(key string, value interface{}) {
+			if key == "restrictSearchWithMatch" {
+				restricted = true
+				value = bson.M{"$and": []interface{}{value, tenantFilter(nil, tenant)}}
+			}
+			spec = append(spec, bson.E{Key: key, Value: value})
+		})
+		if len(spec) == 0 {
+			return nil, fmt.Errorf("cannot confine %s to the tenant", name)
+		}
+		if !restricted {
+			spec = append(spec, bson.E{Key: "restrictSearchWithMatch", Value: tenantFilter(nil, tenant)})
+		}
+		return spec, nil
+	case "$facet":
+		facets := bson.D{}
+		var err error
+		forEachField(value, func(key string, value interface{}) {
+			pipeline := subPipeline(value)
+			if pipeline == nil {
+				err = fmt.Errorf("cannot confine the facet %s to the tenant", key)
+				return
+			}
+			confined, facetErr := tenantPipeline(pipeline, tenant)
+			if facetErr != nil {
+				err = facetErr
+			}
+			facets = append(facets, bson.E{Key: key, Value: confined})
+		})
+		if err != nil {
+			return nil, err
+		}
+		return facets, nil
+	}
+	return value, nil
+}
+
+// annotated returns a copy of the update filtered on the tenant of its context and recording its
+// actor, or ub itself when the context has neither.
+func (ub *UpdateBuilder) annotated() *UpdateBuilder {
+	a := AnnotationsFrom(ub.ctx)
+	tenant, actor := a.Tenant != "" && TenantField != "", a.Actor != "" && UpdatedByField != ""
+	if !tenant && !actor {
+		return ub
+	}
+	annotated := *ub
+	if tenant {
+		annotated.Filter = tenantFilter(ub.Filter, a.Tenant)
+	}
+	if actor {
+		set := map[string]interface{}{}
+		switch values := ub.UpdateData["$set"].(type) {
+		case map[string]interface{}:
+			maps.Copy(set, values)
+		case bson.M:
+			maps.Copy(set, values)
+		}
+		set[UpdatedByField] = a.Actor
+		annotated.UpdateData = maps.Clone(ub.UpdateData)
+		annotated.UpdateData["$set"] = set
+	}
+	return &annotated
+}
+
+// annotated returns a copy of the delete filtered on the tenant of its context, or db itself when
+// the context has none.
+func (db *DeleteBuilder) annotated() *DeleteBuilder {
+	a := AnnotationsFrom(db.ctx)
+	if a.Tenant == "" || TenantField == "" {
+		return db
+	}
+	annotated := *db
+	annotated.Filter = tenantFilter(db.Filter, a.Tenant)
+	return &annotated
+}
+
+// annotate sets the tenant and actor of ctx on a document to insert.
+func annotate(ctx context.Context, document map[string]interface{}) {
+	a := AnnotationsFrom(ctx)
+	if a.Tenant != "" && TenantField != "" {
+		document[TenantField] = a.Tenant
+	}
+	if a.Actor != "" && CreatedByField != "" {
+		document[CreatedByField] = a.Actor
+	}
+}
+
+// commented sets the annotations of ctx as the comment of opts, one of the driver's option
+// types with a SetComment method.
+func commented[T interface{ SetComment(interface{}) T }](ctx context.Context, opts T) T {
+	if comment := AnnotationsFrom(ctx).Comment(); comment != "" {
+		return opts.SetComment(comment)
+	}
+	return opts
+}
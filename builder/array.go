@@ -0,0 +1,89 @@
+package builder
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// WhereElemMatch adds a $match stage keeping documents where an element of the array field
+// matches condition, written over the fields of the elements:
+//
+//	qb.WhereElemMatch("items", "sku = 'A1' AND qty > 5")
+func (qb *QueryBuilder) WhereElemMatch(field, condition string) *QueryBuilder {
+	return qb.MatchCond(W.Field(field).ElemMatch(W.SQL(condition)))
+}
+
+// WhereArraySize adds a $match stage keeping documents where the array field has size elements.
+func (qb *QueryBuilder) WhereArraySize(field string, size int64) *QueryBuilder {
+	return qb.MatchCond(W.Field(field).Size(size))
+}
+
+// WhereArrayAll adds a $match stage keeping documents where the array field contains every one
+// of values.
+func (qb *QueryBuilder) WhereArrayAll(field string, values ...interface{}) *QueryBuilder {
+	return qb.MatchCond(W.Field(field).All(values...))
+}
+
+// ElemMatch matches documents where an element of the array field matches cond, built over
+// the fields of the elements.
+func (f FieldCond) ElemMatch(cond Cond) Cond {
+	return Cond{build: func(qb *QueryBuilder) bson.M {
+		element := &QueryBuilder{stringIDs: qb.stringIDs, exactIDs: qb.exactIDs, location: qb.location}
+		filter := cond.filter(element)
+		qb.failWith(element.buildErrors)
+		return bson.M{qb.resolveField(f.name): bson.M{"$elemMatch": filter}}
+	}}
+}
+
+// Size matches documents where the array field has size elements.
+func (f FieldCond) Size(size int64) Cond {
+	return Cond{build: func(qb *QueryBuilder) bson.M {
+		return bson.M{qb.resolveField(f.name): bson.M{"$size": size}}
+	}}
+}
+
+// All matches documents where the array field contains every one of values.
+func (f FieldCond) All(values ...interface{}) Cond { return f.compareList("$all", values) }
+
+// sizeFilter builds the filter of "SIZE(field) = 3". Equality uses $size; other comparisons
+// compare the size in $expr, counting a missing or non-array field as empty.
+func (qb *QueryBuilder) sizeFilter(tokens []token) bson.M {
+	if len(tokens) != 6 || tokens[1].kind != tokenLParen || tokens[2].kind != tokenIdent || tokens[3].kind != tokenRParen ||
+		tokens[4].kind != tokenOperator || tokens[5].kind != tokenNumber {
+		return bson.M{}
+	}
+	field := qb.resolveField(tokens[2].text)
+	size, ok := parseNumber(tokens[5].text)
+	mongoOperator := mapOperatorToMongo(tokens[4].text)
+	if _, isInt := size.(int64); !ok || !isInt || mongoOperator == "" {
+		return bson.M{}
+	}
+	if mongoOperator == "$eq" {
+		return bson.M{field: bson.M{"$size": size}}
+	}
+	length := bson.M{"$cond": []interface{}{bson.M{"$isArray": "$" + field}, bson.M{"$size": "$" + field}, 0}}
+	return bson.M{"$expr": bson.M{mongoOperator: []interface{}{length, size}}}
+}
+
+// containsFilter builds the filter of "field [NOT] CONTAINS ALL ('a', 'b')", matching arrays
+// holding every value, or "field [NOT] CONTAINS 'a'", matching arrays holding the value.
+func (qb *QueryBuilder) containsFilter(field string, negated bool, tokens []token) bson.M {
+	if len(tokens) > 0 && tokens[0].is("ALL") {
+		values, ok := qb.parseValueList(field, tokens[1:])
+		if !ok || len(values) == 0 {
+			return bson.M{}
+		}
+		if negated {
+			return bson.M{field: bson.M{"$not": bson.M{"$all": values}}}
+		}
+		return bson.M{field: bson.M{"$all": values}}
+	}
+	if len(tokens) != 1 {
+		return bson.M{}
+	}
+	value, ok := qb.tokenValue(field, tokens[0])
+	if !ok {
+		return bson.M{}
+	}
+	if negated {
+		return bson.M{field: bson.M{"$ne": value}}
+	}
+	return bson.M{field: value}
+}
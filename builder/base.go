@@ -3,21 +3,66 @@ package builder
 import (
 	"context"
 	"errors"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"golang.org/x/text/unicode/norm"
 )
 
 type QueryBuilder struct {
-	Collection string
-	Fields     []string
-	Group      bson.M
-	Sort       bson.M
-	HavingCond bson.M
-	LimitVal   int64
-	OffsetVal  int64 // Tambahkan OffsetVal untuk OFFSET
-	Pipeline   []bson.D
+	Collection    string
+	Alias         string // Alias of Collection used to qualify fields, e.g. "e" in "employees e"
+	Fields        []string
+	Group         bson.M
+	Sort          bson.D // Keys of the last ORDER BY in order, see SortMap for a map
+	HavingCond    bson.M
+	LimitVal      int64
+	OffsetVal     int64 // Tambahkan OffsetVal untuk OFFSET
+	Pipeline      []bson.D
+	Collation     *options.Collation // Applies to the whole aggregation, set by ORDER BY ... COLLATE
+	MaxTimeMS     int64              // Server-side time limit of the aggregation, 0 for none
+	OutCollection string             // Collection the results are written to with $out
+
+	joinAliases map[string]string // Joined collection of each join alias
+	joinShape   JoinShape
+	source      string // Collection the pipeline runs on when a RIGHT JOIN re-roots it
+
+	groupKeys       []string          // Fields of the last GroupBy
+	aggregateFields map[string]string // Field of the last $group holding each accumulator
+
+	maxOffset        int64
+	strictOffset     bool
+	outFailIfExists  bool
+	batchTargetBytes int64 // Target size of cursor batches, 0 for the driver default
+	maxResultBytes   int64 // Limit of the results Execute keeps in memory, 0 for none
+	overflow         OverflowHandler
+	deadlineMargin   time.Duration // Margin before the deadline of deadline-aware pages
+	stableSort       *bool         // Whether to append _id to the outermost sort, nil for ExecutePage only
+	readFallback     *readpref.ReadPref
+	snapshot         *Snapshot
+	execOptions      ExecuteOptions
+
+	decodeProfile DecodeProfile
+	registry      *bsoncodec.Registry
+	fieldTypes    map[string]FieldType
+	normalization *norm.Form
+	nullMode      NullMode
+	stringIDs     bool           // Keep hex strings in conditions as strings instead of ObjectIDs
+	exactIDs      bool           // Compare _id with the ObjectID of hex strings only, see MixedIDs
+	location      *time.Location // Time zone of date literals without an offset, nil for UTC
+	ctx           context.Context
+	noMemo        bool
+	params        *paramBinding // Values of the placeholders of the condition being parsed
+	unscoped      bool          // No default scope to apply, see Unscoped
+
+	buildErrors
 }
 
 // NewQueryBuilder initializes a new QueryBuilder.
@@ -30,22 +75,41 @@ func NewQueryBuilder() *QueryBuilder {
 	}
 }
 
-// From specifies the collection to query.
+// Clone returns a copy of the builder that can be extended and executed independently, e.g. a
+// template shared by requests adding their own conditions. Executing never changes a builder,
+// so an unchanged builder needs no clone to run again.
+func (qb *QueryBuilder) Clone() *QueryBuilder {
+	clone := *qb
+	clone.Fields = append([]string{}, qb.Fields...)
+	clone.Pipeline = append([]bson.D{}, qb.Pipeline...)
+	clone.Sort = append(bson.D(nil), qb.Sort...)
+	clone.groupKeys = append([]string(nil), qb.groupKeys...)
+	clone.errs = append([]error(nil), qb.errs...)
+	clone.joinAliases = maps.Clone(qb.joinAliases)
+	clone.aggregateFields = maps.Clone(qb.aggregateFields)
+	clone.fieldTypes = maps.Clone(qb.fieldTypes)
+	return &clone
+}
+
+// From specifies the collection to query, optionally followed by an alias ("employees e" or "employees AS e").
 func (qb *QueryBuilder) From(collection string) *QueryBuilder {
-	qb.Collection = collection
+	parts := strings.Fields(collection)
+	switch {
+	case len(parts) == 3 && strings.ToUpper(parts[1]) == "AS":
+		qb.Collection, qb.Alias = parts[0], parts[2]
+	case len(parts) == 2:
+		qb.Collection, qb.Alias = parts[0], parts[1]
+	default:
+		qb.Collection = strings.TrimSpace(collection)
+	}
+	qb.Collection = qb.normalizeName(qb.Collection)
 	return qb
 }
 
 // Join adds a $lookup stage to the aggregation pipeline for joining collections.
 func (qb *QueryBuilder) Join(localField, fromCollection, foreignField, as string) *QueryBuilder {
-	qb.Pipeline = append(qb.Pipeline, bson.D{
-		{Key: "$lookup", Value: bson.M{
-			"from":         fromCollection,
-			"localField":   localField,
-			"foreignField": foreignField,
-			"as":           as,
-		}},
-	})
+	qb.lookup(localField, fromCollection, foreignField, as)
+	qb.shapeJoin(as)
 	return qb
 }
 
@@ -61,58 +125,273 @@ func (qb *QueryBuilder) Offset(offset int64) *QueryBuilder {
 	return qb
 }
 
+// MaxTime sets the server-side time limit for the query (maxTimeMS).
+func (qb *QueryBuilder) MaxTime(d time.Duration) *QueryBuilder {
+	qb.MaxTimeMS = d.Milliseconds()
+	return qb
+}
+
 // Execute executes the query pipeline.
-func (qb *QueryBuilder) Execute(db *mongo.Database) ([]map[string]interface{}, error) {
-	if qb.Collection == "" {
-		return nil, errors.New("collection is not specified")
+func (qb *QueryBuilder) Execute(db *mongo.Database) (_ []map[string]interface{}, err error) {
+	defer recoverTo(&err)
+	if err := qb.Err(); err != nil {
+		return nil, err
+	}
+	ctx, release, err := trackContext(qb.ctx, db)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
-	collection := db.Collection(qb.Collection)
+	memo, key, memoized := qb.memo(ctx, db.Name())
+	if memoized {
+		if results, ok := memo.get(key); ok {
+			return results, nil
+		}
+	}
 
-	// Build the pipeline
-	if qb.OffsetVal > 0 {
-		qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$skip", Value: qb.OffsetVal}})
+	observe := qb.observeExecution(ctx)
+	var results []map[string]interface{}
+	qb.profiled(ctx, func(ctx context.Context) {
+		results, err = qb.execute(ctx, db)
+	})
+	observe(len(results), err)
+	if err != nil {
+		return nil, err
 	}
-	if qb.LimitVal > 0 {
-		qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$limit", Value: qb.LimitVal}})
+
+	if memoized {
+		memo.put(key, results)
 	}
+	return results, nil
+}
+
+// execute runs the aggregation and collects its results, retrying with the read fallback
+// when the read timed out.
+func (qb *QueryBuilder) execute(ctx context.Context, db *mongo.Database) ([]map[string]interface{}, error) {
+	results, err := qb.read(ctx, db, nil)
+	if err == nil || !qb.shouldFallBack(ctx, err) {
+		return results, err
+	}
+	results, err = qb.read(ctx, db, qb.readFallback)
+	if err != nil {
+		return nil, err
+	}
+	qb.annotateStale(results)
+	return results, nil
+}
 
-	// Execute the pipeline
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// read runs the aggregation with the read preference, or the collection's when nil.
+func (qb *QueryBuilder) read(ctx context.Context, db *mongo.Database, readPref *readpref.ReadPref) ([]map[string]interface{}, error) {
+	ctx, cancel := qb.timeout(ctx)
 	defer cancel()
 
-	cursor, err := collection.Aggregate(ctx, qb.Pipeline)
+	cursor, err := qb.aggregateWith(ctx, db, readPref)
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var results []map[string]interface{}
+	tuner := qb.batchTuner()
+	accumulator := qb.accumulator()
 	for cursor.Next(ctx) {
+		tuner.observe(cursor)
 		var result map[string]interface{}
 		if err := cursor.Decode(&result); err != nil {
 			return nil, err
 		}
-		results = append(results, result)
+		if err := accumulator.add(qb.decodeResult(result), len(cursor.Current)); err != nil {
+			return nil, err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
 	}
 
+	results, err := accumulator.results()
+	if err != nil || accumulator.spilled {
+		return nil, err
+	}
 	return results, nil
 }
 
-// Select specifies the fields to include in the query result.
+// aggregate builds the final pipeline and opens its cursor.
+func (qb *QueryBuilder) aggregate(ctx context.Context, db *mongo.Database) (*mongo.Cursor, error) {
+	return qb.aggregateWith(ctx, db, nil)
+}
+
+// aggregateWith is aggregate reading with the read preference, or the collection's when nil.
+func (qb *QueryBuilder) aggregateWith(ctx context.Context, db *mongo.Database, readPref *readpref.ReadPref) (*mongo.Cursor, error) {
+	if err := qb.checkPipeline(); err != nil {
+		return nil, err
+	}
+	qb.warnOffset()
+
+	collectionOpts := options.Collection()
+	if qb.registry != nil {
+		collectionOpts.SetRegistry(qb.registry)
+	}
+	if readPref != nil {
+		collectionOpts.SetReadPreference(readPref)
+	}
+	collection := db.Collection(qb.sourceCollection(), collectionOpts)
+
+	if err := qb.checkOutTarget(ctx, db); err != nil {
+		return nil, err
+	}
+
+	opts := qb.aggregateOptions(ctx)
+	if qb.snapshot != nil {
+		ctx = qb.snapshot.context(ctx)
+	}
+
+	pipeline, err := qb.executionPipeline(ctx)
+	if err != nil {
+		return nil, err
+	}
+	recordStageUsage(pipeline)
+	return collection.Aggregate(ctx, pipeline, opts)
+}
+
+// aggregateOptions returns the options the aggregate of qb is sent with under ctx.
+func (qb *QueryBuilder) aggregateOptions(ctx context.Context) *options.AggregateOptions {
+	opts := options.Aggregate()
+	if qb.Collation != nil {
+		opts.SetCollation(qb.Collation)
+	}
+	if qb.MaxTimeMS > 0 {
+		opts.SetMaxTime(time.Duration(qb.MaxTimeMS) * time.Millisecond)
+	}
+	if qb.batchTargetBytes > 0 {
+		opts.SetBatchSize(firstBatchSize)
+	}
+	qb.applyExecuteOptions(ctx, opts)
+	if batchSize, remaining := qb.deadlineOptions(ctx); batchSize > 0 {
+		opts.SetBatchSize(batchSize)
+		if remaining > 0 && (qb.MaxTimeMS == 0 || remaining < time.Duration(qb.MaxTimeMS)*time.Millisecond) {
+			opts.SetMaxTime(remaining)
+		}
+	}
+	return opts
+}
+
+// ToPipeline returns the pipeline Execute sends for the query, with OFFSET, LIMIT, the default
+// scope and the tenant filter of the builder's context in place, without connecting to a
+// database: for testing the generated query or running it with the driver directly.
+func (qb *QueryBuilder) ToPipeline() (_ []bson.D, err error) {
+	defer recoverTo(&err)
+	if err := qb.checkPipeline(); err != nil {
+		return nil, err
+	}
+	return qb.executionPipeline(qb.ctx)
+}
+
+// checkPipeline returns the error keeping the query from being run, if any.
+func (qb *QueryBuilder) checkPipeline() error {
+	if qb.Collection == "" {
+		return errors.New("collection is not specified")
+	}
+	if err := qb.Err(); err != nil {
+		return err
+	}
+	return qb.checkOffset()
+}
+
+// executionPipeline returns the pipeline sent for the stages of qb under ctx: scoped, with the
+// sort tiebreaker, OFFSET, LIMIT, $out and the tenant filter. The stages of qb are left
+// unchanged, so the builder runs the same query every time it is executed.
+func (qb *QueryBuilder) executionPipeline(ctx context.Context) ([]bson.D, error) {
+	pipeline := append([]bson.D{}, qb.scopedPipeline()...)
+	if qb.stableSort != nil && *qb.stableSort {
+		addSortTiebreaker(pipeline)
+	}
+	if start := trailingRandomSort(pipeline); start != -1 && qb.LimitVal > 0 && qb.OffsetVal == 0 {
+		// ORDER BY RAND() LIMIT n as the outermost sort is a random sample
+		pipeline = append(pipeline[:start], bson.D{{Key: "$sample", Value: bson.M{"size": qb.LimitVal}}})
+	} else {
+		if qb.OffsetVal > 0 {
+			pipeline = append(pipeline, bson.D{{Key: "$skip", Value: qb.OffsetVal}})
+		}
+		if qb.LimitVal > 0 {
+			pipeline = append(pipeline, bson.D{{Key: "$limit", Value: qb.LimitVal}})
+		}
+	}
+	if qb.OutCollection != "" {
+		pipeline = append(pipeline, bson.D{{Key: "$out", Value: qb.OutCollection}})
+	}
+	return annotatedPipeline(ctx, pipeline)
+}
+
+// Select specifies the fields to include in the query result. With "*" all fields are kept and
+// no $project stage is added; aggregates next to it ("*", "SUM(amount) AS total") are computed
+// over all documents and added to each, see WindowAggregate.
 func (qb *QueryBuilder) Select(fields ...string) *QueryBuilder {
 	qb.Fields = append(qb.Fields, fields...)
+	if slices.Contains(qb.Fields, "*") {
+		aggregations := []string{}
+		for _, field := range fields {
+			if IsAggregate(field) {
+				aggregations = append(aggregations, field)
+			}
+		}
+		return qb.WindowAggregate(aggregations...)
+	}
 	qb.Pipeline = append(qb.Pipeline, bson.D{
 		{Key: "$project", Value: qb.buildProjection()},
 	})
 	return qb
 }
 
-// buildProjection builds a MongoDB $project stage from the Fields.
+// WindowAggregate adds aggregates computed over all documents, like "COUNT(*) AS total", to
+// every document with $setWindowFields (MongoDB 5.0+), keeping the documents themselves.
+func (qb *QueryBuilder) WindowAggregate(aggregations ...string) *QueryBuilder {
+	output := bson.M{}
+	for _, agg := range aggregations {
+		aggregation, err := qb.parseAggregation(agg)
+		if err != nil {
+			qb.fail("unsupported aggregation %q: %v", agg, err)
+			continue
+		}
+		output[qb.parseAlias(agg)] = aggregation
+	}
+	if len(output) > 0 {
+		qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$setWindowFields", Value: bson.M{"output": output}}})
+	}
+	return qb
+}
+
+// buildProjection builds a MongoDB $project stage from the Fields. Aliased fields are renamed
+// ("address.city AS city"), and a path inside another selected one ("address.city" next to
+// "address") is left out, since MongoDB rejects overlapping paths.
 func (qb *QueryBuilder) buildProjection() bson.M {
 	projection := bson.M{}
+	paths := []string{}
 	for _, field := range qb.Fields {
-		projection[field] = 1
+		if field == "*" || IsAggregate(field) {
+			continue // Skip fields without a path of their own
+		}
+		expression, alias, aliased := splitAlias(strings.TrimSpace(field))
+		path := qb.resolveField(strings.TrimSpace(expression))
+		if aliased {
+			projection[alias] = "$" + path
+			continue
+		}
+		paths = append(paths, path)
+	}
+	for _, path := range paths {
+		if !insidePath(path, paths) {
+			projection[path] = 1
+		}
 	}
 	return projection
 }
+
+// insidePath reports whether path is a subfield of one of paths, like "address.city" of "address".
+func insidePath(path string, paths []string) bool {
+	for _, parent := range paths {
+		if strings.HasPrefix(path, parent+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// forEachIDBatch pages through the _ids of documents matching filter in ascending order,
+// starting after the given _id (nil for the beginning), and calls fn with each batch.
+// It pauses interval between batches.
+func forEachIDBatch(ctx context.Context, collection *mongo.Collection, filter interface{}, batchSize int64, after interface{}, interval time.Duration, fn func(ids []interface{}) error) error {
+	opts := options.Find().
+		SetProjection(bson.M{"_id": 1}).
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(batchSize)
+
+	for {
+		pageFilter := filter
+		if after != nil {
+			pageFilter = bson.M{"$and": []interface{}{filter, bson.M{"_id": bson.M{"$gt": after}}}}
+		}
+
+		cursor, err := collection.Find(ctx, pageFilter, opts)
+		if err != nil {
+			return fmt.Errorf("failed to fetch batch: %v", err)
+		}
+		var docs []struct {
+			ID interface{} `bson:"_id"`
+		}
+		if err := cursor.All(ctx, &docs); err != nil {
+			return fmt.Errorf("failed to fetch batch: %v", err)
+		}
+		if len(docs) == 0 {
+			return nil
+		}
+
+		ids := make([]interface{}, len(docs))
+		for i, doc := range docs {
+			ids[i] = doc.ID
+		}
+		if err := fn(ids); err != nil {
+			return err
+		}
+		if int64(len(ids)) < batchSize {
+			return nil
+		}
+		after = ids[len(ids)-1]
+
+		if interval > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+	}
+}
+
+// inBatch restricts filter to the documents of one batch.
+func inBatch(filter interface{}, ids []interface{}) bson.M {
+	return bson.M{"$and": []interface{}{filter, bson.M{"_id": bson.M{"$in": ids}}}}
+}
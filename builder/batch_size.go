@@ -0,0 +1,49 @@
+package builder
+
+import (
+	"math"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// firstBatchSize is the size of the first batch of an adaptively sized cursor, before any
+// document size was observed. It matches the server's default.
+const firstBatchSize = 101
+
+// batchTuner sizes the batches of a cursor to a target number of bytes.
+type batchTuner struct {
+	targetBytes int64
+	documents   int64
+	bytes       int64
+}
+
+// AdaptiveBatchSize makes the cursor request batches of about targetBytes of documents, based
+// on the average size of the documents received so far, instead of the driver default.
+// Large targets help streams of small documents; small targets bound the memory of wide ones.
+func (qb *QueryBuilder) AdaptiveBatchSize(targetBytes int64) *QueryBuilder {
+	qb.batchTargetBytes = targetBytes
+	return qb
+}
+
+// batchTuner returns a tuner for one execution of the query, or nil without AdaptiveBatchSize.
+func (qb *QueryBuilder) batchTuner() *batchTuner {
+	if qb.batchTargetBytes <= 0 {
+		return nil
+	}
+	return &batchTuner{targetBytes: qb.batchTargetBytes}
+}
+
+// observe records the size of the cursor's current document and, once its batch is used up,
+// sets the size of the next batch from the average document size.
+func (t *batchTuner) observe(cursor *mongo.Cursor) {
+	if t == nil {
+		return
+	}
+	t.documents++
+	t.bytes += int64(len(cursor.Current))
+	if cursor.RemainingBatchLength() > 0 || t.bytes == 0 {
+		return
+	}
+	size := t.targetBytes * t.documents / t.bytes
+	cursor.SetBatchSize(int32(max(1, min(size, math.MaxInt32))))
+}
@@ -0,0 +1,53 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DeleteProgress reports the state of a batched delete after each batch.
+type DeleteProgress struct {
+	Batch   int         // Number of batches processed so far
+	Deleted int64       // Documents deleted in this batch
+	Total   int64       // Documents deleted so far
+	LastID  interface{} // _id of the last document in the batch; pass it to ResumeAfter to continue
+}
+
+// Batched makes a multi-document delete run in _id-ordered batches of batchSize documents,
+// pausing interval between batches and calling progress (if not nil) after each one.
+func (db *DeleteBuilder) Batched(batchSize int64, interval time.Duration, progress func(DeleteProgress)) *DeleteBuilder {
+	db.batchSize = batchSize
+	db.batchInterval = interval
+	db.deleteProgress = progress
+	return db
+}
+
+// ResumeAfter continues an interrupted batched delete after the _id reported by the last
+// DeleteProgress, skipping the batches already processed.
+func (db *DeleteBuilder) ResumeAfter(id interface{}) *DeleteBuilder {
+	db.resumeAfter = id
+	return db
+}
+
+// executeBatched deletes batch by batch and returns the deleted count.
+func (db *DeleteBuilder) executeBatched(ctx context.Context, collection *mongo.Collection) (int64, error) {
+	state := DeleteProgress{}
+	err := forEachIDBatch(ctx, collection, db.Filter, db.batchSize, db.resumeAfter, db.batchInterval, func(ids []interface{}) error {
+		result, err := collection.DeleteMany(ctx, inBatch(db.Filter, ids))
+		if err != nil {
+			return fmt.Errorf("failed to delete documents after _id %v: %w", state.LastID, err)
+		}
+		state.Batch++
+		state.Deleted = result.DeletedCount
+		state.Total += result.DeletedCount
+		state.LastID = ids[len(ids)-1]
+		if db.deleteProgress != nil {
+			db.deleteProgress(state)
+		}
+		return nil
+	})
+	return state.Total, err
+}
@@ -0,0 +1,37 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+)
+
+// buildErrors accumulates the errors of building steps, such as a malformed condition, so a
+// builder never runs a query that would silently match more, or less, than asked for.
+type buildErrors struct {
+	errs []error
+}
+
+// Err returns the errors recorded while building, joined, or nil. Execute refuses to run a
+// builder with errors.
+func (b *buildErrors) Err() error {
+	return errors.Join(b.errs...)
+}
+
+// fail records an error of a building step, once per message.
+func (b *buildErrors) fail(format string, args ...interface{}) {
+	err := fmt.Errorf(format, args...)
+	for _, recorded := range b.errs {
+		if recorded.Error() == err.Error() {
+			return
+		}
+	}
+	b.errs = append(b.errs, err)
+}
+
+// failWith records the errors of another builder, such as the QueryBuilder parsing the
+// conditions of an update.
+func (b *buildErrors) failWith(other buildErrors) {
+	for _, err := range other.errs {
+		b.fail("%w", err)
+	}
+}
@@ -0,0 +1,94 @@
+package builder
+
+// ConditionGroup builds a nested AND/OR tree of conditions, see WhereGroup. Conditions joined
+// with And bind tighter than those joined with Or, as in a condition string, and the connector
+// of the first condition is ignored.
+type ConditionGroup struct {
+	terms [][]Cond // Conditions joined with AND, the terms joined with OR
+}
+
+// WhereGroup adds a $match stage with the conditions built by fn:
+//
+//	qb.WhereGroup(func(g *builder.ConditionGroup) {
+//		g.Or("status = ?", "active")
+//		g.OrGroup(func(g *builder.ConditionGroup) {
+//			g.And("status = 'trial'")
+//			g.And("expires_at > ?", time.Now())
+//		})
+//	})
+//
+// matches active documents or trials that have not expired.
+func (qb *QueryBuilder) WhereGroup(fn func(g *ConditionGroup)) *QueryBuilder {
+	return qb.MatchCond(NewConditionGroup(fn))
+}
+
+// WhereGroup specifies the filter of the update as the conditions built by fn.
+func (ub *UpdateBuilder) WhereGroup(fn func(g *ConditionGroup)) *UpdateBuilder {
+	return ub.WhereCond(NewConditionGroup(fn))
+}
+
+// WhereGroup specifies the filter of the delete operation as the conditions built by fn.
+func (db *DeleteBuilder) WhereGroup(fn func(g *ConditionGroup)) *DeleteBuilder {
+	return db.WhereCond(NewConditionGroup(fn))
+}
+
+// NewConditionGroup returns the conditions built by fn as a Cond, to combine with other typed
+// conditions.
+func NewConditionGroup(fn func(g *ConditionGroup)) Cond {
+	g := &ConditionGroup{}
+	fn(g)
+	return g.Cond()
+}
+
+// And joins a condition string, with values bound to placeholders as in Match, with AND.
+func (g *ConditionGroup) And(condition string, args ...interface{}) *ConditionGroup {
+	return g.AndCond(W.SQL(condition, args...))
+}
+
+// Or joins a condition string, with values bound to placeholders as in Match, with OR.
+func (g *ConditionGroup) Or(condition string, args ...interface{}) *ConditionGroup {
+	return g.OrCond(W.SQL(condition, args...))
+}
+
+// AndCond joins a typed condition with AND.
+func (g *ConditionGroup) AndCond(cond Cond) *ConditionGroup {
+	if len(g.terms) == 0 {
+		return g.OrCond(cond)
+	}
+	last := len(g.terms) - 1
+	g.terms[last] = append(g.terms[last], cond)
+	return g
+}
+
+// OrCond joins a typed condition with OR.
+func (g *ConditionGroup) OrCond(cond Cond) *ConditionGroup {
+	g.terms = append(g.terms, []Cond{cond})
+	return g
+}
+
+// AndGroup joins the nested group built by fn with AND, like a parenthesized condition.
+func (g *ConditionGroup) AndGroup(fn func(g *ConditionGroup)) *ConditionGroup {
+	return g.AndCond(NewConditionGroup(fn))
+}
+
+// OrGroup joins the nested group built by fn with OR, like a parenthesized condition.
+func (g *ConditionGroup) OrGroup(fn func(g *ConditionGroup)) *ConditionGroup {
+	return g.OrCond(NewConditionGroup(fn))
+}
+
+// Not negates the group built by fn and joins it with AND, like NOT (...) in a condition string.
+func (g *ConditionGroup) Not(fn func(g *ConditionGroup)) *ConditionGroup {
+	return g.AndCond(NewConditionGroup(fn).Not())
+}
+
+// Cond returns the conditions of the group as a Cond; an empty group matches every document.
+func (g *ConditionGroup) Cond() Cond {
+	terms := make([]Cond, 0, len(g.terms))
+	for _, term := range g.terms {
+		terms = append(terms, logicalCond("$and", term))
+	}
+	if len(terms) == 0 {
+		return Cond{}
+	}
+	return logicalCond("$or", terms)
+}
@@ -1,64 +1,392 @@
 package builder
 
 import (
+	"errors"
 	"strconv"
 	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// parseConditions parses multiple conditions like "amount > 1000 AND status = 'active'".
+// parseConditions parses conditions like "(a = 1 OR b = 2) AND NOT c = 3" into a $and/$or tree.
+// NOT binds tighter than AND, AND binds tighter than OR, and parentheses group conditions. Malformed conditions give an
+// empty filter and an error of the builder, see Err.
 func (qb *QueryBuilder) parseConditions(conditions string) bson.M {
 	conditions = strings.TrimSpace(conditions)
+	defer qb.recoverAs("invalid condition %q", conditions)
+	tokens, err := tokenize(conditions)
+	if err != nil {
+		qb.fail("invalid condition %q: %v", conditions, err)
+		return bson.M{}
+	}
+
+	position := 0
+	var parseOr, parseAnd, parsePrimary func() (bson.M, bool)
+
+	parseLogical := func(operand func() (bson.M, bool), keyword string) func() (bson.M, bool) {
+		return func() (bson.M, bool) {
+			filters := []bson.M{}
+			for {
+				filter, ok := operand()
+				if !ok {
+					return nil, false
+				}
+				filters = append(filters, filter)
+				if position >= len(tokens) || !tokens[position].is(keyword) {
+					break
+				}
+				position++
+			}
+			if len(filters) == 1 {
+				return filters[0], true
+			}
+			return bson.M{"$" + strings.ToLower(keyword): filters}, true
+		}
+	}
+	parseAnd = parseLogical(func() (bson.M, bool) { return parsePrimary() }, "AND")
+	parseOr = parseLogical(parseAnd, "OR")
+
+	parsePrimary = func() (bson.M, bool) {
+		if position >= len(tokens) {
+			return nil, false
+		}
+
+		// NOT condition
+		if tokens[position].is("NOT") {
+			position++
+			filter, ok := parsePrimary()
+			if !ok {
+				return nil, false
+			}
+			return negateFilter(filter), true
+		}
+
+		// ( conditions )
+		if tokens[position].kind == tokenLParen {
+			if end := matchingParen(tokens, position); end != -1 && endsCondition(tokens, end+1) {
+				position++
+				filter, ok := parseOr()
+				if !ok || position != end {
+					return nil, false
+				}
+				position++
+				return filter, true
+			}
+		}
+
+		// A single comparison runs to the next AND or OR outside parentheses
+		start, depth, between := position, 0, false
+		for ; position < len(tokens); position++ {
+			tok := tokens[position]
+			if tok.kind == tokenLParen {
+				depth++
+			} else if tok.kind == tokenRParen {
+				if depth--; depth < 0 {
+					break
+				}
+			} else if depth == 0 && tok.is("BETWEEN") {
+				between = true
+			} else if depth == 0 && between && tok.is("AND") {
+				between = false
+			} else if depth == 0 && (tok.is("AND") || tok.is("OR")) {
+				break
+			}
+		}
+		if position == start {
+			return nil, false
+		}
+		end := len(conditions)
+		if position < len(tokens) {
+			end = tokens[position].pos
+		}
+		return qb.parseComparison(strings.TrimSpace(conditions[tokens[start].pos:end])), true
+	}
+
+	filter, ok := parseOr()
+	if !ok || position != len(tokens) {
+		qb.fail("invalid condition %q", conditions)
+		return bson.M{}
+	}
+	return filter
+}
+
+// negateFilter negates a filter: a single field comparison such as {status: {$eq: "a"}} becomes
+// {status: {$not: {$eq: "a"}}}, anything else is wrapped in $nor. Both also match documents
+// missing the field, unlike SQL, where NOT of a comparison with NULL is not true.
+func negateFilter(filter bson.M) bson.M {
+	if len(filter) == 1 {
+		for field, value := range filter {
+			if operators, ok := value.(bson.M); ok && !strings.HasPrefix(field, "$") && isOperatorDocument(operators) {
+				return bson.M{field: bson.M{"$not": operators}}
+			}
+		}
+	}
+	return bson.M{"$nor": []bson.M{filter}}
+}
 
-	// Split by AND/OR
-	if strings.Contains(strings.ToUpper(conditions), " AND ") {
-		parts := strings.Split(conditions, " AND ")
-		andConditions := []bson.M{}
-		for _, part := range parts {
-			andConditions = append(andConditions, qb.parseCondition(strings.TrimSpace(part)))
+// isOperatorDocument reports whether every key of a field's filter is a query operator that can be
+// negated with $not.
+func isOperatorDocument(operators bson.M) bool {
+	for operator := range operators {
+		if !strings.HasPrefix(operator, "$") || operator == "$not" {
+			return false
 		}
-		return bson.M{"$and": andConditions}
 	}
+	return len(operators) > 0
+}
 
-	if strings.Contains(strings.ToUpper(conditions), " OR ") {
-		parts := strings.Split(conditions, " OR ")
-		orConditions := []bson.M{}
-		for _, part := range parts {
-			orConditions = append(orConditions, qb.parseCondition(strings.TrimSpace(part)))
+// matchingParen returns the index of the parenthesis closing the one at tokens[open], or -1.
+func matchingParen(tokens []token, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch tokens[i].kind {
+		case tokenLParen:
+			depth++
+		case tokenRParen:
+			if depth--; depth == 0 {
+				return i
+			}
 		}
-		return bson.M{"$or": orConditions}
 	}
+	return -1
+}
 
-	// Single condition
-	return qb.parseCondition(conditions)
+// endsCondition reports whether tokens[i] ends a condition: the end, AND, OR or a closing parenthesis.
+func endsCondition(tokens []token, i int) bool {
+	return i == len(tokens) || tokens[i].is("AND") || tokens[i].is("OR") || tokens[i].kind == tokenRParen
+}
+
+// parseComparison parses a single comparison as an expression when it uses functions or
+// arithmetic, and as a simple condition otherwise, so plain comparisons keep their literal types.
+func (qb *QueryBuilder) parseComparison(condition string) bson.M {
+	if isExpression(condition) {
+		if filter, err := qb.parseExpression(condition); err == nil {
+			return filter
+		}
+	}
+	filter := qb.parseCondition(condition) // Fallback to a simple condition
+	if len(filter) == 0 {
+		qb.fail("invalid condition %q", condition)
+	}
+	return filter
 }
 
-// parseCondition parses a single condition like "amount > 1000".
+// parseCondition parses a single condition like "amount > 1000", "status NOT IN ('a', 'b')",
+// "name LIKE 'jo%'", "deleted_at IS NULL", "email EXISTS", "TYPE(age) = 'string'",
+// "tags CONTAINS ALL ('a', 'b')" or "SIZE(tags) = 3".
 func (qb *QueryBuilder) parseCondition(condition string) bson.M {
-	parts := strings.Fields(condition)
-	if len(parts) != 3 {
+	tokens, err := tokenize(condition)
+	if err != nil || len(tokens) < 2 || tokens[0].kind != tokenIdent {
+		return bson.M{}
+	}
+	field := qb.resolveField(tokens[0].text)
+
+	// field [NOT] EXISTS
+	if last := len(tokens) - 1; tokens[last].is("EXISTS") && (last == 1 || last == 2 && tokens[1].is("NOT")) {
+		return existsFilter(field, last == 2)
+	}
+
+	// TYPE(field) = 'type' and SIZE(field) = n
+	if tokens[0].is("TYPE") && tokens[1].kind == tokenLParen {
+		return qb.typeFilter(tokens)
+	}
+	if tokens[0].is("SIZE") && tokens[1].kind == tokenLParen {
+		return qb.sizeFilter(tokens)
+	}
+	if len(tokens) < 3 {
 		return bson.M{}
 	}
 
-	field, operator, value := parts[0], parts[1], strings.Trim(parts[2], "'")
-	mongoOperator := mapOperatorToMongo(operator)
+	// field IS [NOT] NULL
+	if tokens[1].is("IS") {
+		negated := tokens[2].is("NOT")
+		if negated && len(tokens) != 4 || !negated && len(tokens) != 3 || !tokens[len(tokens)-1].is("NULL") {
+			return bson.M{}
+		}
+		return qb.nullFilter(field, negated)
+	}
+
+	negated := tokens[1].is("NOT")
+	rest := tokens[1:]
+	if negated {
+		rest = rest[1:]
+	}
+
+	// field [NOT] BETWEEN low AND high
+	if len(rest) > 0 && rest[0].is("BETWEEN") {
+		if len(rest) != 4 || !rest[2].is("AND") {
+			return bson.M{}
+		}
+		low, okLow := qb.tokenValue(field, rest[1])
+		high, okHigh := qb.tokenValue(field, rest[3])
+		if !okLow || !okHigh {
+			return bson.M{}
+		}
+		if negated {
+			return bson.M{"$or": []bson.M{{field: bson.M{"$lt": low}}, {field: bson.M{"$gt": high}}}}
+		}
+		return bson.M{field: bson.M{"$gte": low, "$lte": high}}
+	}
 
-	return bson.M{field: bson.M{mongoOperator: qb.convertValue(value)}}
+	// field [NOT] LIKE|ILIKE 'pattern'
+	if len(rest) > 0 && (rest[0].is("LIKE") || rest[0].is("ILIKE")) {
+		return qb.likeFilter(field, negated, rest[0].is("ILIKE"), rest[1:])
+	}
+
+	// field [NOT] CONTAINS [ALL] value or (value, ...)
+	if len(rest) > 0 && rest[0].is("CONTAINS") {
+		return qb.containsFilter(field, negated, rest[1:])
+	}
+
+	// field [NOT] IN (value, ...)
+	if negated || rest[0].is("IN") {
+		if len(rest) == 0 || !rest[0].is("IN") {
+			return bson.M{}
+		}
+		values, ok := qb.parseValueList(field, rest[1:])
+		if !ok {
+			return bson.M{}
+		}
+		if negated {
+			return bson.M{field: bson.M{"$nin": values}}
+		}
+		return bson.M{field: bson.M{"$in": values}}
+	}
+
+	if len(tokens) != 3 || tokens[1].kind != tokenOperator {
+		return bson.M{}
+	}
+	mongoOperator := mapOperatorToMongo(tokens[1].text)
+	if ids := qb.idValues(field, tokens[2].text); tokens[2].kind == tokenString && ids != nil {
+		switch mongoOperator {
+		case "$eq":
+			return bson.M{field: bson.M{"$in": ids}}
+		case "$ne":
+			return bson.M{field: bson.M{"$nin": ids}}
+		}
+	}
+	// field operator other_field compares two fields of the same document
+	if _, keyword := keywordValue(tokens[2].text); tokens[2].kind == tokenIdent && (tokens[2].quoted || !keyword) && mongoOperator != "" {
+		other := qb.resolveField(tokens[2].text)
+		return bson.M{"$expr": bson.M{mongoOperator: []interface{}{"$" + field, "$" + other}}}
+	}
+	value, ok := qb.tokenValue(field, tokens[2])
+	if mongoOperator == "" || !ok {
+		return bson.M{}
+	}
+	return bson.M{field: bson.M{mongoOperator: value}}
 }
 
-// convertValue converts a value string to the appropriate type (e.g., int, float, string).
-func (qb *QueryBuilder) convertValue(value string) interface{} {
-	// Try to convert to an integer
-	if num, err := strconv.Atoi(value); err == nil {
-		return num
+// parseValueList parses a parenthesized list of values like "('a', 'b', 3)", or a placeholder
+// bound to a slice.
+func (qb *QueryBuilder) parseValueList(field string, tokens []token) ([]interface{}, bool) {
+	if len(tokens) == 1 && tokens[0].kind == tokenParam {
+		return qb.paramList(tokens[0])
+	}
+	if len(tokens) < 2 || tokens[0].kind != tokenLParen || tokens[len(tokens)-1].kind != tokenRParen {
+		return nil, false
+	}
+	values := []interface{}{}
+	for i, tok := range tokens[1 : len(tokens)-1] {
+		if i%2 == 1 {
+			if tok.kind != tokenComma {
+				return nil, false
+			}
+			continue
+		}
+		if ids := qb.idValues(field, tok.text); tok.kind == tokenString && ids != nil {
+			values = append(values, ids...)
+			continue
+		}
+		value, ok := qb.tokenValue(field, tok)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, value)
+	}
+	if len(tokens) > 2 && len(tokens)%2 != 1 {
+		return nil, false // Trailing comma
 	}
+	return values, true
+}
 
-	// Try to convert to a float
-	if num, err := strconv.ParseFloat(value, 64); err == nil {
+// tokenValue converts a literal token into the value compared against field.
+func (qb *QueryBuilder) tokenValue(field string, tok token) (interface{}, bool) {
+	switch tok.kind {
+	case tokenString:
+		return qb.conditionValue(field, tok.text, true), true
+	case tokenNumber, tokenIdent:
+		return qb.conditionValue(field, tok.text, tok.quoted), true // `true` in backticks stays a string
+	case tokenLiteral:
+		return qb.literalValue(tok)
+	case tokenParam:
+		return qb.paramValue(tok)
+	}
+	return nil, false
+}
+
+// conditionValue converts a condition value, using the declared type of the field if any.
+// Quoted values stay strings, so '007' is not compared as the number 7, except ObjectIDs in hex
+// (see StringIDs).
+func (qb *QueryBuilder) conditionValue(field, value string, quoted bool) interface{} {
+	if fieldType, ok := qb.fieldTypes[field]; ok {
+		return qb.convertToType(value, fieldType)
+	}
+	if quoted {
+		return qb.hexObjectID(value)
+	}
+	return qb.convertValue(value)
+}
+
+// convertValue converts a value string to the appropriate type (e.g., int, float, bool, nil for
+// NULL, string).
+func (qb *QueryBuilder) convertValue(value string) interface{} {
+	if num, ok := parseNumber(value); ok {
 		return num
 	}
+	if keyword, ok := keywordValue(value); ok {
+		return keyword
+	}
 
 	// Fallback to string
 	return value
 }
+
+// keywordValue returns the value of the TRUE, FALSE and NULL keywords, in any case.
+func keywordValue(value string) (interface{}, bool) {
+	switch strings.ToUpper(value) {
+	case "TRUE":
+		return true, true
+	case "FALSE":
+		return false, true
+	case "NULL":
+		return nil, true
+	}
+	return nil, false
+}
+
+// parseNumber parses an integer as int64, falling back to Decimal128 for integers outside the
+// int64 range so they keep their precision, or a decimal number as float64, falling back to
+// Decimal128 for exponents outside the float64 range like 1e400.
+func parseNumber(value string) (interface{}, bool) {
+	if numberLiteral.FindString(value) != value {
+		return nil, false // Rejects forms strconv accepts but SQL does not, e.g. "Inf" or "0x1p-2"
+	}
+	if num, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return num, true
+	} else if errors.Is(err, strconv.ErrRange) {
+		if num, err := primitive.ParseDecimal128(value); err == nil {
+			return num, true
+		}
+	}
+
+	if num, err := strconv.ParseFloat(value, 64); err == nil {
+		return num, true
+	} else if errors.Is(err, strconv.ErrRange) {
+		if num, err := primitive.ParseDecimal128(value); err == nil {
+			return num, true
+		}
+	}
+	return nil, false
+}
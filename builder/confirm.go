@@ -0,0 +1,133 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrWriteNotConfirmed is returned when the change events of a write are not observed in time.
+var ErrWriteNotConfirmed = errors.New("write not confirmed by change stream")
+
+// ConfirmWrite makes Execute return only after the change events of the inserted documents
+// are observed on a change stream, so downstream consumers can already see them. Documents
+// without an _id get one generated. Requires a replica set or sharded cluster, and fails inside
+// a transaction, whose events only appear at commit.
+func (ib *InsertBuilder) ConfirmWrite(timeout time.Duration) *InsertBuilder {
+	ib.confirmTimeout = timeout
+	return ib
+}
+
+// ConfirmWrite makes Execute return only after the change events of the modified documents
+// are observed on a change stream. A single-document update is applied by _id to the
+// document found first, whose events are awaited. At most 10000 documents can be
+// confirmed. Requires a replica set or sharded cluster and fails inside a transaction.
+func (ub *UpdateBuilder) ConfirmWrite(timeout time.Duration) *UpdateBuilder {
+	ub.confirmTimeout = timeout
+	return ub
+}
+
+// ConfirmWrite makes Execute return only after the change events of the deleted documents
+// are observed on a change stream. A single-document delete is applied by _id to the
+// document found first, whose events are awaited. At most 10000 documents can be
+// confirmed. Requires a replica set or sharded cluster and fails inside a transaction.
+func (db *DeleteBuilder) ConfirmWrite(timeout time.Duration) *DeleteBuilder {
+	db.confirmTimeout = timeout
+	return db
+}
+
+// maxConfirmedIDs caps the documents a confirmed write may change, keeping the $in of the
+// change stream filter far below the 16MB limit of a command.
+const maxConfirmedIDs = 10000
+
+// confirmWrite opens a change stream on the documents with the given _ids before running
+// write, then waits until as many matching events as write reports have been observed.
+func confirmWrite(ctx context.Context, collection *mongo.Collection, ids []interface{}, operations []string, timeout time.Duration, write func() (int64, error)) (int64, error) {
+	if err := checkConfirmable(ctx); err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stream, err := collection.Watch(ctx, mongo.Pipeline{{{Key: "$match", Value: bson.M{
+		"operationType":   bson.M{"$in": operations},
+		"documentKey._id": bson.M{"$in": ids},
+	}}}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to open change stream: %v", err)
+	}
+	defer stream.Close(context.Background())
+
+	count, err := write()
+	if err != nil {
+		return 0, err
+	}
+
+	for seen := int64(0); seen < count; seen++ {
+		if !stream.Next(ctx) {
+			if err := stream.Err(); err != nil && ctx.Err() == nil {
+				return count, fmt.Errorf("failed to read change stream: %v", err)
+			}
+			return count, ErrWriteNotConfirmed
+		}
+	}
+	return count, nil
+}
+
+// matchingIDs returns the _ids of the documents filter matches, or of the first one when multi
+// is false. Matching more than maxConfirmedIDs documents is an error.
+func matchingIDs(ctx context.Context, collection *mongo.Collection, filter interface{}, multi bool, timeout time.Duration) ([]interface{}, error) {
+	if err := checkConfirmable(ctx); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	opts := options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(maxConfirmedIDs + 1)
+	if !multi {
+		opts.SetLimit(1)
+	}
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find documents to confirm: %v", err)
+	}
+	var docs []struct {
+		ID interface{} `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("failed to find documents to confirm: %v", err)
+	}
+	if len(docs) > maxConfirmedIDs {
+		return nil, fmt.Errorf("cannot confirm a write of more than %d documents", maxConfirmedIDs)
+	}
+
+	ids := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	return ids, nil
+}
+
+// checkConfirmable returns an error when ctx runs in a transaction: its writes produce change
+// events only at commit, so a confirmation inside it would always time out.
+func checkConfirmable(ctx context.Context) error {
+	if session, ok := mongo.SessionFromContext(ctx).(mongo.XSession); ok && session.ClientSession().TransactionRunning() {
+		return errors.New("ConfirmWrite cannot be used inside a transaction")
+	}
+	return nil
+}
+
+// confirmedFilter returns filter restricted to the document matchingIDs found for a
+// single-document write, so the write changes the document whose events are awaited rather than
+// any other match. Multi-document writes keep filter.
+func confirmedFilter(filter map[string]interface{}, ids []interface{}, multi bool) map[string]interface{} {
+	if multi || len(ids) != 1 {
+		return filter
+	}
+	return map[string]interface{}{"$and": []interface{}{filter, bson.M{"_id": ids[0]}}}
+}
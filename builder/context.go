@@ -0,0 +1,39 @@
+package builder
+
+import "context"
+
+// WithContext makes the query run with ctx, e.g. a mongo.SessionContext to run it inside
+// a transaction. The query's own time limits still apply.
+func (qb *QueryBuilder) WithContext(ctx context.Context) *QueryBuilder {
+	qb.ctx = ctx
+	return qb
+}
+
+// WithContext makes the update run with ctx, e.g. a mongo.SessionContext to run it inside
+// a transaction.
+func (ub *UpdateBuilder) WithContext(ctx context.Context) *UpdateBuilder {
+	ub.ctx = ctx
+	return ub
+}
+
+// WithContext makes the delete run with ctx, e.g. a mongo.SessionContext to run it inside
+// a transaction.
+func (db *DeleteBuilder) WithContext(ctx context.Context) *DeleteBuilder {
+	db.ctx = ctx
+	return db
+}
+
+// WithContext makes the insert run with ctx, e.g. a mongo.SessionContext to run it inside
+// a transaction.
+func (ib *InsertBuilder) WithContext(ctx context.Context) *InsertBuilder {
+	ib.ctx = ctx
+	return ib
+}
+
+// orBackground returns ctx, or context.Background() when no context was set.
+func orBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
@@ -24,25 +24,30 @@ func NewCreateIndexBuilder(collection string) *CreateIndexBuilder {
 	}
 }
 
-// Index adds a new index with the specified name and fields.
+// Index adds a new index with the specified name and fields. Fields may be nested paths
+// ("address.city"), and default to ascending without ASC or DESC.
 func (ib *CreateIndexBuilder) Index(name string, fields string) *CreateIndexBuilder {
 	keys := bson.D{}
 
-	// Parse fields like "status ASC, amount DESC"
+	// Parse fields like "status ASC, meta.created_at DESC"
 	fieldParts := strings.Split(fields, ",")
 	for _, part := range fieldParts {
 		part = strings.TrimSpace(part)
-		field := strings.Fields(part)
-		if len(field) != 2 {
+		path, end, _, ok := scanIdentifier(part, 0)
+		if !ok || path == "" {
 			continue
 		}
 
 		direction := 1
-		if strings.ToUpper(field[1]) == "DESC" {
+		switch strings.ToUpper(strings.TrimSpace(part[end:])) {
+		case "", "ASC":
+		case "DESC":
 			direction = -1
+		default:
+			continue
 		}
 
-		keys = append(keys, bson.E{Key: field[0], Value: direction})
+		keys = append(keys, bson.E{Key: path, Value: direction})
 	}
 
 	ib.Indexes = append(ib.Indexes, mongo.IndexModel{
@@ -57,6 +62,9 @@ func (ib *CreateIndexBuilder) Execute(db *mongo.Database) error {
 	if ib.Collection == "" {
 		return fmt.Errorf("collection name is not specified")
 	}
+	if db == nil {
+		return ErrNoDatabase
+	}
 
 	collection := db.Collection(ib.Collection)
 	for _, index := range ib.Indexes {
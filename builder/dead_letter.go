@@ -0,0 +1,172 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DeadLetter is a document a bulk write could not write.
+type DeadLetter struct {
+	Collection string      // Collection the document was written to
+	Document   interface{} // The document as given to the builder
+	Err        error       // Error of the last attempt
+	Attempts   int         // Number of attempts made
+}
+
+// DeadLetterSink receives the documents of bulk writes that failed permanently, so ingestion
+// does not silently drop them. An error of the sink fails the write.
+type DeadLetterSink interface {
+	DeadLetters(ctx context.Context, letters []DeadLetter) error
+}
+
+// DeadLetterFunc adapts a function to a DeadLetterSink.
+type DeadLetterFunc func(ctx context.Context, letters []DeadLetter) error
+
+// DeadLetters calls f.
+func (f DeadLetterFunc) DeadLetters(ctx context.Context, letters []DeadLetter) error {
+	return f(ctx, letters)
+}
+
+// CollectionSink returns a sink storing dead letters as documents of collection in db, with
+// the fields collection, document, error, attempts and failedAt.
+func CollectionSink(db *mongo.Database, collection string) DeadLetterSink {
+	return DeadLetterFunc(func(ctx context.Context, letters []DeadLetter) error {
+		records := make([]interface{}, len(letters))
+		for i, letter := range letters {
+			records[i] = bson.M{
+				"collection": letter.Collection,
+				"document":   letter.Document,
+				"error":      letter.Err.Error(),
+				"attempts":   letter.Attempts,
+				"failedAt":   time.Now(),
+			}
+		}
+		if _, err := db.Collection(collection).InsertMany(ctx, records); err != nil {
+			return fmt.Errorf("failed to store dead letters: %v", err)
+		}
+		return nil
+	})
+}
+
+// DeadLetters makes Execute write the documents unordered, retry the ones failing with a
+// transient error (such as a failover) up to retries times, and hand the documents still
+// failing to sink instead of failing the insert. Execute then returns the IDs of the
+// documents written.
+func (ib *InsertBuilder) DeadLetters(sink DeadLetterSink, retries int) *InsertBuilder {
+	ib.deadLetters = deadLetterPolicy{sink: sink, retries: retries}
+	return ib
+}
+
+// DeadLetters makes Execute write the documents unordered, retry the ones failing with a
+// transient error up to retries times, and hand the documents still failing to sink instead
+// of failing the upsert. The counts of the result cover the documents written.
+func (ub *UpsertBuilder) DeadLetters(sink DeadLetterSink, retries int) *UpsertBuilder {
+	ub.deadLetters = deadLetterPolicy{sink: sink, retries: retries}
+	return ub
+}
+
+// deadLetterPolicy holds the sink and retries of a bulk write.
+type deadLetterPolicy struct {
+	sink    DeadLetterSink
+	retries int
+}
+
+// transientCodes are the server error codes of writes that may succeed when retried:
+// interruptions, failovers, write conflicts and time limits.
+var transientCodes = []int{50, 91, 112, 189, 262, 10107, 11600, 11602, 13435, 13436}
+
+// deliver writes docs with write, given the positions in docs of the documents of each batch,
+// and returns the positions of the documents written. Documents failing with a transient error
+// are written again, the others go to the sink. An error that is neither a write error of
+// single documents nor transient fails the whole write.
+func (p deadLetterPolicy) deliver(ctx context.Context, collection string, docs []interface{}, write func(positions []int, batch []interface{}) error) ([]int, error) {
+	pending := make([]int, len(docs))
+	for i := range pending {
+		pending[i] = i
+	}
+	written := []int{}
+	letters := []DeadLetter{}
+	for attempt := 1; len(pending) > 0; attempt++ {
+		batch := make([]interface{}, len(pending))
+		for i, position := range pending {
+			batch[i] = docs[position]
+		}
+		failures, err := writeFailures(write(pending, batch), len(batch))
+		if err != nil {
+			return nil, err
+		}
+
+		retry := []int{}
+		for i, position := range pending {
+			failure, failed := failures[i]
+			switch {
+			case !failed:
+				written = append(written, position)
+			case isTransient(failure) && attempt <= p.retries:
+				retry = append(retry, position)
+			default:
+				letters = append(letters, DeadLetter{Collection: collection, Document: docs[position], Err: failure, Attempts: attempt})
+			}
+		}
+		pending = retry
+
+		if len(pending) > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Duration(attempt) * 100 * time.Millisecond):
+			}
+		}
+	}
+
+	if len(letters) > 0 {
+		if err := p.sink.DeadLetters(ctx, letters); err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(written)
+	return written, nil
+}
+
+// writeFailures returns the error of each failed document of a batch of n documents, by index
+// in the batch. A transient error of the whole batch fails every document; other errors of the
+// whole batch are returned.
+func writeFailures(err error, n int) (map[int]error, error) {
+	failures := map[int]error{}
+	if err == nil {
+		return failures, nil
+	}
+	var bulk mongo.BulkWriteException
+	if errors.As(err, &bulk) && len(bulk.WriteErrors) > 0 {
+		for _, writeError := range bulk.WriteErrors {
+			failures[writeError.Index] = writeError
+		}
+		return failures, nil
+	}
+	if !isTransient(err) {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		failures[i] = err
+	}
+	return failures, nil
+}
+
+// isTransient reports whether a write may succeed when retried.
+func isTransient(err error) bool {
+	var writeError mongo.BulkWriteError
+	if errors.As(err, &writeError) {
+		return slices.Contains(transientCodes, writeError.Code)
+	}
+	var labeled mongo.LabeledError
+	if errors.As(err, &labeled) && (labeled.HasErrorLabel("RetryableWriteError") || labeled.HasErrorLabel("TransientTransactionError")) {
+		return true
+	}
+	return mongo.IsNetworkError(err) || mongo.IsTimeout(err)
+}
@@ -0,0 +1,85 @@
+package builder
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DecodeProfile controls the Go types of the values returned by Execute.
+type DecodeProfile int
+
+const (
+	DecodeNative DecodeProfile = iota // Driver types such as primitive.ObjectID and primitive.DateTime
+	DecodeJSON                        // JSON-friendly values: ObjectIDs as hex strings, dates as RFC3339 strings
+)
+
+// Decode sets how result values are converted (DecodeNative by default).
+func (qb *QueryBuilder) Decode(profile DecodeProfile) *QueryBuilder {
+	qb.decodeProfile = profile
+	return qb
+}
+
+// DecodeRegistry decodes results with a custom codec registry, e.g. to map BSON dates to
+// a domain type. The decode profile is applied after the registry.
+func (qb *QueryBuilder) DecodeRegistry(registry *bsoncodec.Registry) *QueryBuilder {
+	qb.registry = registry
+	return qb
+}
+
+// decodeResult applies the decode profile to a result document.
+func (qb *QueryBuilder) decodeResult(result map[string]interface{}) map[string]interface{} {
+	if qb.decodeProfile != DecodeJSON {
+		return result
+	}
+	return jsonFriendly(result).(map[string]interface{})
+}
+
+// jsonFriendly converts BSON-specific values into types encoding/json renders naturally.
+func jsonFriendly(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = jsonFriendly(item)
+		}
+		return v
+	case bson.M:
+		return jsonFriendly(map[string]interface{}(v))
+	case bson.D:
+		doc := make(map[string]interface{}, len(v))
+		for _, elem := range v {
+			doc[elem.Key] = jsonFriendly(elem.Value)
+		}
+		return doc
+	case bson.A:
+		return jsonFriendly([]interface{}(v))
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonFriendly(item)
+		}
+		return v
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.DateTime:
+		return v.Time().UTC().Format(time.RFC3339Nano)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case primitive.Timestamp:
+		return time.Unix(int64(v.T), 0).UTC().Format(time.RFC3339)
+	case primitive.Decimal128:
+		return v.String()
+	case primitive.Binary:
+		return v.Data // Encoded as base64 by encoding/json
+	case primitive.Regex:
+		return "/" + v.Pattern + "/" + v.Options
+	case primitive.JavaScript:
+		return string(v)
+	case primitive.Symbol:
+		return string(v)
+	case primitive.Null, primitive.Undefined:
+		return nil
+	}
+	return value
+}
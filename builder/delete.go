@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // DeleteBuilder helps in deleting documents from a MongoDB collection.
@@ -14,6 +15,19 @@ type DeleteBuilder struct {
 	Collection string
 	Filter     map[string]interface{}
 	Multi      bool // If true, deletes multiple documents
+
+	writePolicy
+	batchSize      int64
+	batchInterval  time.Duration
+	deleteProgress func(DeleteProgress)
+	resumeAfter    interface{}
+	confirmTimeout time.Duration
+	stringIDs      bool
+	exactIDs       bool
+	location       *time.Location
+	ctx            context.Context
+
+	buildErrors
 }
 
 // NewDeleteBuilder initializes a new DeleteBuilder for a specific collection.
@@ -25,10 +39,12 @@ func NewDeleteBuilder(collection string) *DeleteBuilder {
 	}
 }
 
-// Where specifies the filter condition for the delete operation.
-func (db *DeleteBuilder) Where(condition string) *DeleteBuilder {
-	qb := QueryBuilder{}
-	db.Filter = qb.parseConditions(condition) // Reuse parseConditions from QueryBuilder
+// Where specifies the filter condition for the delete operation, with values bound to
+// placeholders as in QueryBuilder.Match.
+func (db *DeleteBuilder) Where(condition string, args ...interface{}) *DeleteBuilder {
+	qb := QueryBuilder{stringIDs: db.stringIDs, exactIDs: db.exactIDs, location: db.location}
+	db.Filter = qb.bindParams(condition, args, qb.parseConditions) // Reuse parseConditions from QueryBuilder
+	db.failWith(qb.buildErrors)
 	return db
 }
 
@@ -39,27 +55,61 @@ func (db *DeleteBuilder) SetMulti(multi bool) *DeleteBuilder {
 }
 
 // Execute performs the delete operation.
-func (db *DeleteBuilder) Execute(dbInstance *mongo.Database) (int64, error) {
+func (db *DeleteBuilder) Execute(dbInstance *mongo.Database) (_ int64, err error) {
+	defer recoverTo(&err)
 	if db.Collection == "" {
 		return 0, errors.New("collection name is not specified")
 	}
+	if err := db.Err(); err != nil {
+		return 0, err
+	}
+	ctx, release, err := trackContext(db.ctx, dbInstance)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
 
+	db = db.annotated()
 	collection := dbInstance.Collection(db.Collection)
+	if db.confirmTimeout <= 0 {
+		return db.write(ctx, collection)
+	}
+
+	ids, err := matchingIDs(ctx, collection, db.Filter, db.Multi, db.confirmTimeout)
+	if err != nil {
+		return 0, err
+	}
+	confirmed := *db
+	confirmed.Filter = confirmedFilter(db.Filter, ids, db.Multi)
+	return confirmWrite(ctx, collection, ids, []string{"delete"}, db.confirmTimeout, func() (int64, error) {
+		return confirmed.write(ctx, collection)
+	})
+}
 
+// write performs DeleteOne or DeleteMany with ctx and returns the deleted count.
+func (db *DeleteBuilder) write(ctx context.Context, collection *mongo.Collection) (int64, error) {
 	// DeleteOne or DeleteMany
 	var result *mongo.DeleteResult
 	var err error
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	batchCtx := ctx
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	if db.Multi {
-		result, err = collection.DeleteMany(ctx, db.Filter)
+		collection, err = db.writePolicy.apply(ctx, collection, db.Filter)
+		if err != nil {
+			return 0, err
+		}
+		if db.batchSize > 0 {
+			return db.executeBatched(batchCtx, collection)
+		}
+		result, err = collection.DeleteMany(ctx, db.Filter, commented(ctx, options.Delete()))
 	} else {
-		result, err = collection.DeleteOne(ctx, db.Filter)
+		result, err = collection.DeleteOne(ctx, db.Filter, commented(ctx, options.Delete()))
 	}
 
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete documents: %v", err)
+		return 0, fmt.Errorf("failed to delete documents: %w", err)
 	}
 
 	return result.DeletedCount, nil
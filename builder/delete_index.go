@@ -32,6 +32,9 @@ func (dib *DeleteIndexBuilder) Execute(db *mongo.Database) error {
 	if dib.Collection == "" {
 		return fmt.Errorf("collection name is not specified")
 	}
+	if db == nil {
+		return ErrNoDatabase
+	}
 
 	collection := db.Collection(dib.Collection)
 	for _, index := range dib.Indexes {
@@ -0,0 +1,166 @@
+package builder
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FieldChange holds both values of a field that differs between two result sets.
+type FieldChange struct {
+	Old interface{}
+	New interface{}
+}
+
+// DocumentChange is a document present in both result sets with different field values.
+type DocumentChange struct {
+	Key    map[string]interface{}
+	Fields map[string]FieldChange // Keyed by dotted field path
+}
+
+// ResultDiff is the difference between two result sets.
+type ResultDiff struct {
+	Added   []map[string]interface{} // Documents only in the second result set
+	Removed []map[string]interface{} // Documents only in the first result set
+	Changed []DocumentChange
+	// Documents repeating the key of an earlier document of the same result set, left out of
+	// the comparison
+	Duplicates []map[string]interface{}
+}
+
+// Diff compares two result sets, matching documents by keyFields ("_id" when none are given),
+// e.g. for reconciliation jobs comparing the same query on two databases.
+func Diff(resultsA, resultsB []map[string]interface{}, keyFields ...string) ResultDiff {
+	if len(keyFields) == 0 {
+		keyFields = []string{"_id"}
+	}
+
+	diff := ResultDiff{}
+	indexB := map[string]map[string]interface{}{}
+	for _, doc := range resultsB {
+		if key := diffKey(doc, keyFields); indexB[key] == nil {
+			indexB[key] = doc
+		} else {
+			diff.Duplicates = append(diff.Duplicates, doc)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, docA := range resultsA {
+		key := diffKey(docA, keyFields)
+		if seen[key] {
+			diff.Duplicates = append(diff.Duplicates, docA)
+			continue
+		}
+		seen[key] = true
+
+		docB, ok := indexB[key]
+		if !ok {
+			diff.Removed = append(diff.Removed, docA)
+			continue
+		}
+		fields := map[string]FieldChange{}
+		diffFields("", docA, docB, fields)
+		if len(fields) > 0 {
+			keyValues := map[string]interface{}{}
+			for _, field := range keyFields {
+				keyValues[field] = docA[field]
+			}
+			diff.Changed = append(diff.Changed, DocumentChange{Key: keyValues, Fields: fields})
+		}
+	}
+
+	for _, docB := range resultsB {
+		key := diffKey(docB, keyFields)
+		if !seen[key] {
+			seen[key] = true
+			diff.Added = append(diff.Added, docB)
+		}
+	}
+	return diff
+}
+
+// diffKey builds the matching key of a document from its key fields. The values are written
+// with their types, so the string "1" and the number 1 are different keys.
+func diffKey(doc map[string]interface{}, keyFields []string) string {
+	parts := make([]string, len(keyFields))
+	for i, field := range keyFields {
+		parts[i] = fmt.Sprintf("%#v", normalizeDiffValue(doc[field]))
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// diffFields records the differing fields of two documents, descending into subdocuments.
+func diffFields(prefix string, a, b map[string]interface{}, changes map[string]FieldChange) {
+	keys := map[string]bool{}
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	for _, key := range sorted {
+		path := prefix + key
+		valueA, valueB := normalizeDiffValue(a[key]), normalizeDiffValue(b[key])
+		subA, okA := valueA.(map[string]interface{})
+		subB, okB := valueB.(map[string]interface{})
+		if okA && okB {
+			diffFields(path+".", subA, subB, changes)
+			continue
+		}
+		if !reflect.DeepEqual(valueA, valueB) {
+			changes[path] = FieldChange{Old: a[key], New: b[key]}
+		}
+	}
+}
+
+// normalizeDiffValue makes equal values compare equal regardless of their BSON representation,
+// e.g. int32 and int64 numbers or bson.M and bson.D subdocuments. Integers stay int64, so large
+// ones keep their precision; floats holding an integer become that integer.
+func normalizeDiffValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float32:
+		return normalizeDiffValue(float64(v))
+	case float64:
+		if v == math.Trunc(v) && v >= -(1<<63) && v < 1<<63 {
+			return int64(v)
+		}
+		return v
+	case bson.M:
+		return normalizeDiffValue(map[string]interface{}(v))
+	case bson.D:
+		return normalizeDiffValue(v.Map())
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			normalized[key] = normalizeDiffValue(item)
+		}
+		return normalized
+	case primitive.A:
+		return normalizeDiffValue([]interface{}(v))
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized[i] = normalizeDiffValue(item)
+		}
+		return normalized
+	}
+	return value
+}
@@ -0,0 +1,99 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrShuttingDown is returned by operations started against a client that is being drained.
+var ErrShuttingDown = errors.New("client is shutting down")
+
+// clientOperations tracks the in-flight operations against one client.
+type clientOperations struct {
+	mu      sync.Mutex
+	closed  bool
+	next    int
+	active  map[int]context.CancelFunc
+	drained chan struct{} // Closed when the last operation finishes after closing
+}
+
+// operations holds the clientOperations of each *mongo.Client.
+var operations sync.Map
+
+// operationsOf returns the operations of client.
+func operationsOf(client *mongo.Client) *clientOperations {
+	ops, _ := operations.LoadOrStore(client, &clientOperations{active: map[int]context.CancelFunc{}})
+	return ops.(*clientOperations)
+}
+
+// trackContext registers an operation against the client of db and returns ctx derived into a
+// context Drain cancels, with the function ending the operation. The builder's own context is
+// left unchanged, so executions of one builder may overlap.
+func trackContext(ctx context.Context, db *mongo.Database) (context.Context, func(), error) {
+	if db == nil {
+		return nil, nil, ErrNoDatabase
+	}
+	ops := operationsOf(db.Client())
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+	if ops.closed {
+		return nil, nil, ErrShuttingDown
+	}
+
+	tracked, cancel := context.WithCancel(orBackground(ctx))
+	id := ops.next
+	ops.next++
+	ops.active[id] = cancel
+
+	return tracked, func() {
+		cancel()
+		ops.mu.Lock()
+		defer ops.mu.Unlock()
+		delete(ops.active, id)
+		if ops.closed && len(ops.active) == 0 && ops.drained != nil {
+			close(ops.drained)
+			ops.drained = nil
+		}
+	}, nil
+}
+
+// Drain stops new operations against client, which fail with ErrShuttingDown, and waits for
+// the in-flight ones. When ctx ends first, the remaining operations are cancelled, which
+// aborts their cursors, and Drain returns the context's error once they have returned. The
+// client is forgotten when Drain returns, so operations started later run again: Drain is
+// meant to be followed by Disconnect.
+func Drain(ctx context.Context, client *mongo.Client) error {
+	ops := operationsOf(client)
+	defer operations.CompareAndDelete(client, ops)
+	ops.mu.Lock()
+	ops.closed = true
+	if len(ops.active) == 0 {
+		ops.mu.Unlock()
+		return nil
+	}
+	if ops.drained == nil {
+		ops.drained = make(chan struct{})
+	}
+	drained := ops.drained
+	ops.mu.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	ops.mu.Lock()
+	for _, cancel := range ops.active {
+		cancel()
+	}
+	remaining := len(ops.active)
+	ops.mu.Unlock()
+	if remaining > 0 {
+		<-drained
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,69 @@
+package builder
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultTimeout limits the time a query runs when no Timeout is set.
+const defaultTimeout = 10 * time.Second
+
+// ExecuteOptions tune how a query runs, see WithOptions.
+type ExecuteOptions struct {
+	Timeout      time.Duration      // Client-side time limit of Execute, 10 seconds when zero, none when negative
+	AllowDiskUse bool               // Lets stages like $sort and $group write temporary files beyond their memory limit
+	BatchSize    int32              // Documents per cursor batch, 0 for the driver default
+	Comment      string             // Comment shown in the profiler and the slow query log
+	Collation    *options.Collation // Collation of the whole aggregation
+	Hint         interface{}        // Index to use, by name or key document like bson.D{{"status", 1}}
+	MaxTime      time.Duration      // Server-side time limit (maxTimeMS), 0 for none
+}
+
+// WithOptions sets how the query runs. Collation and MaxTime replace the values set by
+// ORDER BY ... COLLATE and MaxTime; the comment is sent next to the annotations of the context.
+func (qb *QueryBuilder) WithOptions(opts ExecuteOptions) *QueryBuilder {
+	qb.execOptions = opts
+	if opts.Collation != nil {
+		qb.Collation = opts.Collation
+	}
+	if opts.MaxTime > 0 {
+		qb.MaxTime(opts.MaxTime)
+	}
+	return qb
+}
+
+// timeout returns ctx limited to the client-side time limit of the query.
+func (qb *QueryBuilder) timeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	switch timeout := qb.execOptions.Timeout; {
+	case timeout < 0:
+		return context.WithCancel(ctx)
+	case timeout > 0:
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithTimeout(ctx, defaultTimeout)
+}
+
+// applyExecuteOptions sets the aggregate options of WithOptions not kept in fields of qb.
+func (qb *QueryBuilder) applyExecuteOptions(ctx context.Context, opts *options.AggregateOptions) {
+	if qb.execOptions.AllowDiskUse {
+		opts.SetAllowDiskUse(true)
+	}
+	if qb.execOptions.BatchSize > 0 {
+		opts.SetBatchSize(qb.execOptions.BatchSize)
+	}
+	if qb.execOptions.Hint != nil {
+		opts.SetHint(qb.execOptions.Hint)
+	}
+	comments := []string{}
+	for _, comment := range []string{qb.execOptions.Comment, AnnotationsFrom(ctx).Comment()} {
+		if comment != "" {
+			comments = append(comments, comment)
+		}
+	}
+	if len(comments) > 0 {
+		opts.SetComment(strings.Join(comments, " "))
+	}
+}
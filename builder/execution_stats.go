@@ -0,0 +1,88 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// indexScanStages are the plan stages reading an index.
+var indexScanStages = map[string]bool{
+	"IXSCAN": true, "COUNT_SCAN": true, "DISTINCT_SCAN": true, "IDHACK": true,
+	"EXPRESS_IXSCAN": true, "EXPRESS_IDHACK": true,
+}
+
+// ErrStatsUnavailable is returned, with the results, when the query ran but explain could not
+// collect its execution stats.
+var ErrStatsUnavailable = errors.New("query executed but its stats are unavailable")
+
+// ExecutionStats describe how the server ran a query, see ExecuteWithStats.
+type ExecutionStats struct {
+	Returned     int           // Documents returned
+	ServerTime   time.Duration // Execution time reported by the server
+	DocsExamined int64         // Documents the server read, summed over shards
+	KeysExamined int64         // Index keys the server read, summed over shards
+	IndexUsed    bool          // Whether the plan read an index rather than scanning the collection
+	Indexes      []string      // Names of the indexes the plan read, sorted
+}
+
+// ExecuteWithStats executes the query like Execute and returns how the server ran it, taken from
+// explain with executionStats verbosity of the same pipeline and aggregate options. Explain runs
+// the pipeline again, so it costs as much as a second execution; the stats are nil when the query
+// writes with $out. When explain fails the results are returned with ErrStatsUnavailable.
+func (qb *QueryBuilder) ExecuteWithStats(db *mongo.Database) ([]map[string]interface{}, *ExecutionStats, error) {
+	results, err := qb.Execute(db)
+	if err != nil || qb.OutCollection != "" {
+		return results, nil, err
+	}
+	explain, err := qb.Explain(db, "executionStats")
+	if err != nil {
+		return results, nil, fmt.Errorf("%w: %v", ErrStatsUnavailable, err)
+	}
+	stats := explainedStats(explain)
+	stats.Returned = len(results)
+	return results, stats, nil
+}
+
+// explainedStats sums the executionStats sections of an explain output: the top-level one when
+// the whole pipeline ran in the query layer, the one of the $cursor stage otherwise, or those of
+// each shard.
+func explainedStats(explain bson.M) *ExecutionStats {
+	stats := &ExecutionStats{}
+	indexes := map[string]bool{}
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case bson.M:
+			if execution, ok := v["executionStats"].(bson.M); ok {
+				stats.DocsExamined += max(explainCount(execution["totalDocsExamined"]), 0)
+				stats.KeysExamined += max(explainCount(execution["totalKeysExamined"]), 0)
+				serverTime := time.Duration(max(explainCount(execution["executionTimeMillis"]), 0)) * time.Millisecond
+				stats.ServerTime = max(stats.ServerTime, serverTime) // Shards run concurrently
+			}
+			if stage, ok := v["stage"].(string); ok && indexScanStages[stage] {
+				stats.IndexUsed = true
+				if name, ok := v["indexName"].(string); ok {
+					indexes[name] = true
+				}
+			}
+			for _, item := range v {
+				walk(item)
+			}
+		case bson.A:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(explain)
+	for name := range indexes {
+		stats.Indexes = append(stats.Indexes, name)
+	}
+	slices.Sort(stats.Indexes)
+	return stats
+}
@@ -0,0 +1,77 @@
+package builder
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// existsFilter builds the filter of "field EXISTS" or "field NOT EXISTS", which, unlike IS NULL,
+// tell a missing field from a field set to null.
+func existsFilter(field string, negated bool) bson.M {
+	return bson.M{field: bson.M{"$exists": !negated}}
+}
+
+// typeFilter builds the $type filter of "TYPE(field) = 'string'", "TYPE(field) != 'null'" or
+// "TYPE(field) [NOT] IN ('int', 'long')". Types are BSON type aliases or numbers, and the
+// "number" alias matches every numeric type.
+func (qb *QueryBuilder) typeFilter(tokens []token) bson.M {
+	if len(tokens) < 6 || tokens[1].kind != tokenLParen || tokens[2].kind != tokenIdent || tokens[3].kind != tokenRParen {
+		return bson.M{}
+	}
+	field := qb.resolveField(tokens[2].text)
+	rest := tokens[4:]
+
+	var types interface{}
+	negated := false
+	switch {
+	case len(rest) == 2 && rest[0].kind == tokenOperator && (rest[0].text == "=" || mapOperatorToMongo(rest[0].text) == "$ne"):
+		value, ok := typeName(rest[1])
+		if !ok {
+			return bson.M{}
+		}
+		types, negated = value, rest[0].text != "="
+	default:
+		if negated = rest[0].is("NOT"); negated {
+			rest = rest[1:]
+		}
+		if len(rest) < 3 || !rest[0].is("IN") || rest[1].kind != tokenLParen || rest[len(rest)-1].kind != tokenRParen {
+			return bson.M{}
+		}
+		list := []interface{}{}
+		for i, tok := range rest[2 : len(rest)-1] {
+			if i%2 == 1 {
+				if tok.kind != tokenComma {
+					return bson.M{}
+				}
+				continue
+			}
+			value, ok := typeName(tok)
+			if !ok {
+				return bson.M{}
+			}
+			list = append(list, value)
+		}
+		if len(list) == 0 || len(rest)%2 != 0 {
+			return bson.M{} // Empty list or trailing comma
+		}
+		types = list
+	}
+
+	if negated {
+		return bson.M{field: bson.M{"$not": bson.M{"$type": types}}}
+	}
+	return bson.M{field: bson.M{"$type": types}}
+}
+
+// typeName returns the BSON type a token names in a TYPE() condition: an alias like 'string' or
+// a type number like 2.
+func typeName(tok token) (interface{}, bool) {
+	switch tok.kind {
+	case tokenString:
+		return tok.text, tok.text != ""
+	case tokenNumber:
+		if num, ok := parseNumber(tok.text); ok {
+			if code, isInt := num.(int64); isInt {
+				return code, true
+			}
+		}
+	}
+	return nil, false
+}
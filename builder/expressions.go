@@ -2,55 +2,201 @@ package builder
 
 import (
 	"errors"
-	"regexp"
-	"strconv"
 	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 )
 
-// parseExpression parses expressions like "SUM(amount) / COUNT(*) > 1000".
-func (qb *QueryBuilder) parseExpression(expression string) (bson.M, error) {
+// isExpression reports whether a condition contains a function call or an arithmetic operator.
+// TYPE(field) and SIZE(field) starting a condition are conditions of their own.
+func isExpression(condition string) bool {
+	tokens, err := tokenize(condition)
+	if err != nil {
+		return false
+	}
+	for i, tok := range tokens {
+		switch {
+		case tok.kind == tokenOperator && strings.Contains("+-*/", tok.text):
+			return true
+		case tok.kind == tokenLParen && i > 0 && tokens[i-1].kind == tokenIdent && !tokens[i-1].is("IN") && !isPredicateCall(tokens, i-1):
+			return true
+		}
+	}
+	return false
+}
+
+// isPredicateCall reports whether tokens[i] is the TYPE or SIZE starting a condition.
+func isPredicateCall(tokens []token, i int) bool {
+	return i == 0 && (tokens[i].is("TYPE") || tokens[i].is("SIZE"))
+}
+
+// parseExpression parses expressions like "SUM(amount) / COUNT(*) > 1000" or "price * qty >= 100".
+func (qb *QueryBuilder) parseExpression(expression string) (_ bson.M, err error) {
+	defer recoverTo(&err)
 	expression = strings.TrimSpace(expression)
 
-	re := regexp.MustCompile(`([\w\(\)\*]+)\s*([+\-*/><=]+)\s*([\w\(\)\*]+)`)
-	matches := re.FindStringSubmatch(expression)
-	if len(matches) < 4 {
-		return nil, errors.New("invalid expression format")
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
 	}
-
-	operand1, operator, operand2 := matches[1], matches[2], matches[3]
-	mongoOperator := mapOperatorToMongo(operator)
-	if mongoOperator == "" {
-		return nil, errors.New("unsupported operator in expression")
+	depth := 0
+	for _, tok := range tokens {
+		switch tok.kind {
+		case tokenLParen:
+			depth++
+		case tokenRParen:
+			depth--
+		case tokenOperator:
+			mongoOperator := mapOperatorToMongo(tok.text)
+			if depth != 0 || strings.Contains("+-*/", tok.text) || mongoOperator == "" {
+				continue
+			}
+			left, err := qb.parseArithmetic(expression[:tok.pos])
+			if err != nil {
+				return nil, err
+			}
+			right, err := qb.parseArithmetic(expression[tok.pos+len(tok.text):])
+			if err != nil {
+				return nil, err
+			}
+			return bson.M{"$expr": bson.M{mongoOperator: []interface{}{left, right}}}, nil
+		}
 	}
-
-	return bson.M{
-		"$expr": bson.M{
-			mongoOperator: []interface{}{
-				qb.parseFieldOrValue(operand1),
-				qb.parseFieldOrValue(operand2),
-			},
-		},
-	}, nil
+	return nil, errors.New("invalid expression format")
 }
 
 // parseFieldOrValue parses a field (e.g., SUM(amount)) or a literal value.
 func (qb *QueryBuilder) parseFieldOrValue(input string) interface{} {
 	input = strings.TrimSpace(input)
 
-	if num, err := strconv.ParseFloat(input, 64); err == nil {
+	if num, ok := parseNumber(input); ok {
 		return num
 	}
 
-	if strings.HasPrefix(strings.ToUpper(input), "SUM(") {
-		field := strings.TrimSuffix(strings.TrimPrefix(input, "SUM("), ")")
-		return bson.M{"$sum": "$" + field}
+	if name, argument, ok := functionCall(input); ok {
+		switch name {
+		case "SUM":
+			return bson.M{"$sum": "$" + argument}
+		case "COUNT":
+			return bson.M{"$sum": 1}
+		}
 	}
 
-	if strings.HasPrefix(strings.ToUpper(input), "COUNT(") {
-		return bson.M{"$sum": 1}
+	return "$" + qb.resolveField(input)
+}
+
+// parseArithmetic parses arithmetic like "price * (qty + 1)" into an aggregation expression,
+// with * and / binding tighter than + and -.
+func (qb *QueryBuilder) parseArithmetic(expression string) (interface{}, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	position := 0
+	var parseSum, parseProduct, parseOperand func() (interface{}, error)
+
+	parseOperand = func() (interface{}, error) {
+		if position >= len(tokens) {
+			return nil, errors.New("unexpected end of arithmetic expression")
+		}
+		tok := tokens[position]
+		position++
+		switch tok.kind {
+		case tokenLParen:
+			value, err := parseSum()
+			if err != nil {
+				return nil, err
+			}
+			if position >= len(tokens) || tokens[position].kind != tokenRParen {
+				return nil, errors.New("missing closing parenthesis")
+			}
+			position++
+			return value, nil
+		case tokenNumber:
+			return qb.parseFieldOrValue(tok.text), nil
+		case tokenString:
+			return bson.M{"$literal": tok.text}, nil
+		case tokenLiteral:
+			value, ok := qb.literalValue(tok)
+			if !ok {
+				return nil, errors.New("invalid literal " + tok.text)
+			}
+			return bson.M{"$literal": value}, nil
+		case tokenParam:
+			value, ok := qb.paramValue(tok)
+			if !ok {
+				return nil, errors.New("no value for placeholder " + tok.text)
+			}
+			return bson.M{"$literal": value}, nil
+		case tokenIdent:
+			if position < len(tokens) && tokens[position].kind == tokenLParen {
+				// Function call, e.g. SUM(amount)
+				end, depth := position+1, 1
+				for ; end < len(tokens); end++ {
+					if tokens[end].kind == tokenLParen {
+						depth++
+					} else if tokens[end].kind == tokenRParen {
+						if depth--; depth == 0 {
+							break
+						}
+					}
+				}
+				if end == len(tokens) {
+					return nil, errors.New("missing closing parenthesis")
+				}
+				arguments := expression[tokens[position].pos+1 : tokens[end].pos]
+				position = end + 1
+				value, registered, err := qb.parseFunction(strings.ToUpper(tok.text), arguments)
+				if registered {
+					return value, err
+				}
+				return qb.parseFieldOrValue(expression[tok.pos : tokens[end].pos+1]), nil
+			}
+			if keyword, ok := keywordValue(tok.text); ok && !tok.quoted {
+				return bson.M{"$literal": keyword}, nil
+			}
+			return qb.parseFieldOrValue(tok.text), nil
+		case tokenOperator:
+			if tok.text == "-" {
+				// Unary minus, e.g. -price
+				value, err := parseOperand()
+				if err != nil {
+					return nil, err
+				}
+				return bson.M{"$multiply": []interface{}{-1, value}}, nil
+			}
+		}
+		return nil, errors.New("unexpected token in arithmetic expression: " + tok.text)
 	}
 
-	return "$" + input
+	parseBinary := func(operand func() (interface{}, error), operators string) func() (interface{}, error) {
+		return func() (interface{}, error) {
+			left, err := operand()
+			if err != nil {
+				return nil, err
+			}
+			for position < len(tokens) && tokens[position].kind == tokenOperator && strings.Contains(operators, tokens[position].text) {
+				mongoOperator := mapOperatorToMongo(tokens[position].text)
+				position++
+				right, err := operand()
+				if err != nil {
+					return nil, err
+				}
+				left = bson.M{mongoOperator: []interface{}{left, right}}
+			}
+			return left, nil
+		}
+	}
+	parseProduct = parseBinary(parseOperand, "*/")
+	parseSum = parseBinary(parseProduct, "+-")
+
+	value, err := parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if position != len(tokens) {
+		return nil, errors.New("unexpected token in arithmetic expression")
+	}
+	return value, nil
 }
@@ -0,0 +1,127 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MarshalJSON renders the aggregate command of the query as canonical Extended JSON, e.g.
+// {"aggregate":"users","pipeline":[{"$match":{"age":{"$gt":{"$numberLong":"18"}}}}]}, so values
+// keep their BSON types.
+func (qb *QueryBuilder) MarshalJSON() ([]byte, error) {
+	pipeline, err := qb.ToPipeline()
+	if err != nil {
+		return nil, err
+	}
+	return bson.MarshalExtJSON(bson.D{
+		{Key: "aggregate", Value: qb.sourceCollection()},
+		{Key: "pipeline", Value: pipeline},
+	}, true, false)
+}
+
+// String renders the query as the equivalent mongosh command, for debugging what is sent.
+func (qb *QueryBuilder) String() string {
+	pipeline, err := qb.ToPipeline()
+	if err != nil {
+		return fmt.Sprintf("invalid query: %v", err)
+	}
+	return mongoshCommand(qb.sourceCollection(), "aggregate", pipeline)
+}
+
+// MarshalJSON renders the update command as canonical Extended JSON, with the filter and
+// update document or pipeline the update sends.
+func (ub *UpdateBuilder) MarshalJSON() ([]byte, error) {
+	if err := ub.checkWrite(ub.Collection); err != nil {
+		return nil, err
+	}
+	annotated := ub.annotated()
+	update, err := annotated.buildUpdate()
+	if err != nil {
+		return nil, err
+	}
+	return bson.MarshalExtJSON(bson.D{
+		{Key: "update", Value: ub.Collection},
+		{Key: "updates", Value: bson.A{bson.D{
+			{Key: "q", Value: annotated.Filter},
+			{Key: "u", Value: update},
+			{Key: "multi", Value: ub.Multi},
+		}}},
+	}, true, false)
+}
+
+// String renders the update as the equivalent mongosh command, for debugging what is sent.
+func (ub *UpdateBuilder) String() string {
+	if err := ub.checkWrite(ub.Collection); err != nil {
+		return fmt.Sprintf("invalid update: %v", err)
+	}
+	annotated := ub.annotated()
+	update, err := annotated.buildUpdate()
+	if err != nil {
+		return fmt.Sprintf("invalid update: %v", err)
+	}
+	method := "updateOne"
+	if ub.Multi {
+		method = "updateMany"
+	}
+	return mongoshCommand(ub.Collection, method, annotated.Filter, update)
+}
+
+// MarshalJSON renders the delete command as canonical Extended JSON, with the filter the
+// delete sends.
+func (db *DeleteBuilder) MarshalJSON() ([]byte, error) {
+	if err := db.checkWrite(db.Collection); err != nil {
+		return nil, err
+	}
+	limit := 1
+	if db.Multi {
+		limit = 0
+	}
+	return bson.MarshalExtJSON(bson.D{
+		{Key: "delete", Value: db.Collection},
+		{Key: "deletes", Value: bson.A{bson.D{
+			{Key: "q", Value: db.annotated().Filter},
+			{Key: "limit", Value: limit},
+		}}},
+	}, true, false)
+}
+
+// String renders the delete as the equivalent mongosh command, for debugging what is sent.
+func (db *DeleteBuilder) String() string {
+	if err := db.checkWrite(db.Collection); err != nil {
+		return fmt.Sprintf("invalid delete: %v", err)
+	}
+	method := "deleteOne"
+	if db.Multi {
+		method = "deleteMany"
+	}
+	return mongoshCommand(db.Collection, method, db.annotated().Filter)
+}
+
+// checkWrite returns the error keeping a write on collection from being run, if any.
+func (b *buildErrors) checkWrite(collection string) error {
+	if collection == "" {
+		return errors.New("collection name is not specified")
+	}
+	return b.Err()
+}
+
+// mongoshCommand renders a call of method on collection in mongosh syntax. The arguments are
+// written as canonical Extended JSON read by EJSON.deserialize, so they keep their BSON types
+// when pasted into the shell.
+func mongoshCommand(collection, method string, args ...interface{}) string {
+	command := fmt.Sprintf("db.getCollection(%q).%s(", collection, method)
+	for i, arg := range args {
+		encoded, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: arg}}, true, false)
+		if err != nil {
+			return fmt.Sprintf("invalid %s: %v", method, err)
+		}
+		if i > 0 {
+			command += ", "
+		}
+		// Strip the {"v": ...} wrapper, needed since MarshalExtJSON only encodes documents
+		command += "EJSON.deserialize(" + string(encoded[len(`{"v":`):len(encoded)-1]) + ")"
+	}
+	return command + ")"
+}
@@ -0,0 +1,60 @@
+package builder
+
+import (
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FieldType is the BSON type a field is declared to hold.
+type FieldType int
+
+const (
+	FieldString  FieldType = iota // Values are compared as strings, even if they look numeric
+	FieldInt64                    // Values are compared as 64-bit integers
+	FieldDouble                   // Values are compared as doubles
+	FieldDecimal                  // Values are compared as Decimal128
+	FieldBool                     // Values are compared as booleans
+	FieldDate                     // Values are ISO-8601 dates, compared as BSON dates
+)
+
+// FieldTypes declares the types of fields, e.g. from a schema, so condition values are
+// converted to the declared type instead of being guessed from their syntax. A value that
+// does not parse as the declared type is kept as a string and matches nothing.
+func (qb *QueryBuilder) FieldTypes(types map[string]FieldType) *QueryBuilder {
+	if qb.fieldTypes == nil {
+		qb.fieldTypes = map[string]FieldType{}
+	}
+	for field, fieldType := range types {
+		qb.fieldTypes[field] = fieldType
+	}
+	return qb
+}
+
+// convertToType converts a condition value to a declared field type.
+func (qb *QueryBuilder) convertToType(value string, fieldType FieldType) interface{} {
+	switch fieldType {
+	case FieldInt64:
+		if num, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return num
+		}
+	case FieldDouble:
+		if num, err := strconv.ParseFloat(value, 64); err == nil {
+			return num
+		}
+	case FieldDecimal:
+		if num, err := primitive.ParseDecimal128(value); err == nil {
+			return num
+		}
+	case FieldBool:
+		if b, err := strconv.ParseBool(strings.ToLower(value)); err == nil {
+			return b
+		}
+	case FieldDate:
+		if date, ok := qb.parseDate(value); ok {
+			return date
+		}
+	}
+	return value
+}
@@ -0,0 +1,116 @@
+package builder
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+)
+
+// Fragment is a reusable piece of a query, such as the joins and filter of an "active paying
+// customer", defined once and included in any query with Include, or in SQL with
+// "INCLUDE name(args)":
+//
+//	builder.RegisterFragment("active_paying", builder.Fragment{
+//		Params: []string{"since"},
+//		Where:  "status = 'active' AND plan != 'free' AND paid_at >= :since",
+//	})
+//	qb.From("customers").Include("active_paying", since)
+type Fragment struct {
+	Params []string       // Names of the parameters, bound in order to the arguments of Include
+	Joins  []FragmentJoin // Joins added before the condition, so it can use joined fields
+	Where  string         // Condition, referring to the parameters as :name
+	Select []string       // Fields added to the projection of the query's Select
+}
+
+// FragmentJoin is a join of a Fragment, with the arguments of Join.
+type FragmentJoin struct {
+	LocalField   string
+	From         string
+	ForeignField string
+	As           string
+}
+
+var (
+	fragmentsMu sync.Mutex // Serializes registrations
+	fragments   atomic.Pointer[map[string]Fragment]
+)
+
+func init() {
+	fragments.Store(&map[string]Fragment{})
+}
+
+// RegisterFragment registers a fragment under name, replacing any previous one; a zero Fragment
+// removes it.
+func RegisterFragment(name string, fragment Fragment) {
+	fragmentsMu.Lock()
+	defer fragmentsMu.Unlock()
+	table := maps.Clone(*fragments.Load())
+	if len(fragment.Params) == 0 && len(fragment.Joins) == 0 && fragment.Where == "" && len(fragment.Select) == 0 {
+		delete(table, name)
+	} else {
+		table[name] = fragment
+	}
+	fragments.Store(&table)
+}
+
+// LookupFragment returns the fragment registered under name.
+func LookupFragment(name string) (Fragment, bool) {
+	fragment, ok := (*fragments.Load())[name]
+	return fragment, ok
+}
+
+// With combines the fragment with others into one fragment taking the parameters of each in
+// order and matching all their conditions.
+func (f Fragment) With(others ...Fragment) Fragment {
+	combined := Fragment{
+		Params: append([]string{}, f.Params...),
+		Joins:  append([]FragmentJoin{}, f.Joins...),
+		Where:  f.Where,
+		Select: append([]string{}, f.Select...),
+	}
+	for _, other := range others {
+		combined.Params = append(combined.Params, other.Params...)
+		combined.Joins = append(combined.Joins, other.Joins...)
+		combined.Select = append(combined.Select, other.Select...)
+		switch {
+		case other.Where == "":
+		case combined.Where == "":
+			combined.Where = other.Where
+		default:
+			combined.Where = "(" + combined.Where + ") AND (" + other.Where + ")"
+		}
+	}
+	return combined
+}
+
+// Include adds the fragment registered under name, with args bound to its parameters. An
+// unknown name is an error of the builder, see Err.
+func (qb *QueryBuilder) Include(name string, args ...interface{}) *QueryBuilder {
+	fragment, ok := LookupFragment(name)
+	if !ok {
+		qb.fail("unknown fragment %s", name)
+		return qb
+	}
+	return qb.Apply(fragment, args...)
+}
+
+// Apply adds the joins, condition and selected fields of fragment, with args bound to its
+// parameters. Another number of arguments than parameters is an error of the builder, see Err.
+func (qb *QueryBuilder) Apply(fragment Fragment, args ...interface{}) *QueryBuilder {
+	if len(args) != len(fragment.Params) {
+		qb.fail("%d arguments for the %d parameters of a fragment", len(args), len(fragment.Params))
+		return qb
+	}
+	for _, join := range fragment.Joins {
+		qb.Join(join.LocalField, join.From, join.ForeignField, join.As)
+	}
+	if fragment.Where != "" {
+		params := Params{}
+		for i, name := range fragment.Params {
+			params[name] = args[i]
+		}
+		qb.Match(fragment.Where, params)
+	}
+	qb.Fields = append(qb.Fields, fragment.Select...) // Projected by Select, so later stages still see every field
+	return qb
+}
@@ -0,0 +1,101 @@
+package builder
+
+import (
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Function translates a call of a custom function in expressions into an aggregation
+// expression. Its arguments are already translated: fields as "$field" references, literals
+// as {$literal: value} and nested expressions as aggregation expressions.
+//
+//	builder.RegisterFunction("LOWER", func(args []interface{}) (interface{}, error) {
+//		return bson.M{"$toLower": args[0]}, nil
+//	})
+type Function func(args []interface{}) (interface{}, error)
+
+// functionTable is a snapshot of the registered functions. Snapshots are never modified:
+// registering copies the table, so parsing reads it without locking.
+type functionTable struct {
+	functions    map[string]Function // Custom functions of expressions
+	accumulators map[string]string   // Aggregate functions taking a field and their $group accumulator
+}
+
+var (
+	functionsMu sync.Mutex // Serializes registrations
+	functions   atomic.Pointer[functionTable]
+)
+
+func init() {
+	functions.Store(&functionTable{
+		functions: map[string]Function{},
+		accumulators: map[string]string{
+			"SUM":      "$sum",
+			"AVG":      "$avg",
+			"MIN":      "$min",
+			"MAX":      "$max",
+			"FIRST":    "$first",
+			"LAST":     "$last",
+			"PUSH":     "$push",
+			"ADDTOSET": "$addToSet",
+		},
+	})
+}
+
+// RegisterFunction makes a custom function callable in the expressions of every query, like
+// "LOWER(name) = 'ada'". Names match in any case; literal functions such as DATE and NOW take
+// precedence. Registration is meant for init time, see RegisterFunctions.
+func RegisterFunction(name string, fn Function) {
+	RegisterFunctions(map[string]Function{name: fn})
+}
+
+// RegisterFunctions registers many custom functions with a single copy of the function table,
+// e.g. hundreds of them at init. Queries being parsed meanwhile keep the table they started with.
+func RegisterFunctions(fns map[string]Function) {
+	updateFunctions(func(table *functionTable) {
+		for name, fn := range fns {
+			table.functions[strings.ToUpper(name)] = fn
+		}
+	})
+}
+
+// RegisterAccumulator makes an aggregate function taking a field available in SELECT, GROUP BY
+// and HAVING, e.g. RegisterAccumulator("STDDEV", "$stdDevPop") for "STDDEV(price) AS spread".
+func RegisterAccumulator(name, operator string) {
+	updateFunctions(func(table *functionTable) {
+		table.accumulators[strings.ToUpper(name)] = operator
+	})
+}
+
+// updateFunctions replaces the function table with a modified copy.
+func updateFunctions(update func(table *functionTable)) {
+	functionsMu.Lock()
+	defer functionsMu.Unlock()
+	current := functions.Load()
+	table := &functionTable{functions: maps.Clone(current.functions), accumulators: maps.Clone(current.accumulators)}
+	update(table)
+	functions.Store(table)
+}
+
+// parseFunction translates a call of a registered function with its argument list. It
+// reports false when name is not registered.
+func (qb *QueryBuilder) parseFunction(name, arguments string) (interface{}, bool, error) {
+	fn, ok := functions.Load().functions[name]
+	if !ok {
+		return nil, false, nil
+	}
+	args := []interface{}{}
+	if strings.TrimSpace(arguments) != "" {
+		for _, argument := range splitTopLevel(arguments) {
+			arg, err := qb.parseArithmetic(argument)
+			if err != nil {
+				return nil, true, err
+			}
+			args = append(args, arg)
+		}
+	}
+	value, err := fn(args)
+	return value, true, err
+}
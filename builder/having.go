@@ -1,13 +1,80 @@
 package builder
 
-import "go.mongodb.org/mongo-driver/bson"
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
 
 // Having adds a $match stage after $group to filter aggregated results (supports expressions).
+// Aliases of the grouped aggregates are fields of the grouped documents; aggregate functions
+// like "SUM(amount) > 1000" refer to the matching accumulator, which is added to the $group
+// and removed after the $match when it was not selected.
 func (qb *QueryBuilder) Having(condition string) *QueryBuilder {
-	filter, err := qb.parseExpression(condition)
-	if err != nil {
-		filter = qb.parseConditions(condition) // Fallback to simple conditions
+	condition, hidden := qb.resolveAggregates(condition)
+	qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$match", Value: qb.parseConditions(condition)}})
+	if len(hidden) > 0 {
+		qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$unset", Value: hidden}})
 	}
-	qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$match", Value: filter}})
 	return qb
 }
+
+// resolveAggregates replaces the aggregate function calls of a HAVING condition with the
+// fields of the last $group holding them, and returns the fields it had to add to the $group.
+func (qb *QueryBuilder) resolveAggregates(condition string) (string, []string) {
+	tokens, err := tokenize(condition)
+	if err != nil || qb.Group == nil {
+		return condition, nil
+	}
+
+	var resolved strings.Builder
+	hidden := []string{}
+	last := 0
+	for i := 0; i+1 < len(tokens); i++ {
+		if tokens[i].kind != tokenIdent || tokens[i].quoted || tokens[i+1].kind != tokenLParen {
+			continue
+		}
+		end := matchingParen(tokens, i+1)
+		if end == -1 {
+			break
+		}
+		call := condition[tokens[i].pos : tokens[end].pos+1]
+		aggregation, err := qb.parseAggregation(call)
+		if err != nil {
+			continue // Not an aggregate, e.g. an arithmetic function
+		}
+
+		field, ok := qb.aggregateFields[fmt.Sprint(aggregation)]
+		if !ok {
+			field = fmt.Sprintf("__having%d", len(qb.aggregateFields))
+			qb.Group[field] = aggregation
+			qb.recordAggregate(field, aggregation)
+			hidden = append(hidden, field)
+		}
+		resolved.WriteString(condition[last:tokens[i].pos])
+		resolved.WriteString(fieldReference(field))
+		last = tokens[end].pos + 1
+		i = end
+	}
+	resolved.WriteString(condition[last:])
+	return resolved.String(), hidden
+}
+
+// recordAggregate records that the grouped documents hold aggregation under field.
+func (qb *QueryBuilder) recordAggregate(field string, aggregation bson.M) {
+	if qb.aggregateFields == nil {
+		qb.aggregateFields = map[string]string{}
+	}
+	if _, ok := qb.aggregateFields[fmt.Sprint(aggregation)]; !ok {
+		qb.aggregateFields[fmt.Sprint(aggregation)] = field
+	}
+}
+
+// fieldReference writes a field name for a condition, in backticks unless it is a plain name.
+func fieldReference(field string) string {
+	if _, end, quoted, ok := scanIdentifier(field, 0); ok && !quoted && end == len(field) {
+		return field
+	}
+	return "`" + field + "`"
+}
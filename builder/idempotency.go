@@ -0,0 +1,137 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// IdempotencyCollection stores the keys of idempotent writes.
+	IdempotencyCollection = "mongoquery_idempotency"
+
+	// IdempotencyTTL is how long keys are kept before MongoDB removes them with a TTL index.
+	IdempotencyTTL = 24 * time.Hour
+
+	// ErrIdempotencyInProgress is returned when another call with the same key has not finished yet.
+	ErrIdempotencyInProgress = errors.New("operation with this idempotency key is in progress")
+
+	// ErrIdempotencyNotRecorded is returned, with the result, when the write succeeded but its
+	// completion could not be recorded under the key, so retries get ErrIdempotencyInProgress
+	// until the key expires.
+	ErrIdempotencyNotRecorded = errors.New("idempotent write succeeded but was not recorded")
+
+	// ttlIndexes remembers the databases whose idempotency TTL index was created.
+	ttlIndexes sync.Map
+)
+
+// idempotencyRecord is the document stored per idempotency key.
+type idempotencyRecord struct {
+	Key       string        `bson:"_id"`
+	Done      bool          `bson:"done"`
+	Result    bson.RawValue `bson:"result,omitempty"`
+	CreatedAt time.Time     `bson:"createdAt"`
+}
+
+// IdempotencyKey makes Execute record the insert under key, so a retried call with the same
+// key (e.g. after a timeout) returns the original inserted IDs instead of inserting again.
+func (ib *InsertBuilder) IdempotencyKey(key string) *InsertBuilder {
+	ib.idempotencyKey = key
+	return ib
+}
+
+// IdempotencyKey makes Execute record the update under key, so a retried call with the same
+// key returns the original modified count instead of applying the update again.
+func (ub *UpdateBuilder) IdempotencyKey(key string) *UpdateBuilder {
+	ub.idempotencyKey = key
+	return ub
+}
+
+// idempotencyTimeout bounds each access to the keys collection.
+const idempotencyTimeout = 10 * time.Second
+
+// runIdempotent reserves key for collection, runs op and stores its result. When the key was
+// already completed, op is skipped and the stored result is returned. The keys collection is
+// accessed with ctx, so inside a transaction the key is reserved and recorded atomically with
+// the write and released when the transaction aborts. The result is recorded on ctx without its
+// cancellation, so a write that was made is not left in progress; when recording fails, the
+// result is returned with ErrIdempotencyNotRecorded.
+func runIdempotent(ctx context.Context, db *mongo.Database, collection, key string, op func() (interface{}, error)) (bson.RawValue, error) {
+	keys := db.Collection(IdempotencyCollection)
+	if err := ensureIdempotencyIndex(db, keys); err != nil {
+		return bson.RawValue{}, err
+	}
+
+	reserveCtx, cancel := context.WithTimeout(ctx, idempotencyTimeout)
+	defer cancel()
+	id := collection + ":" + key
+	_, err := keys.InsertOne(reserveCtx, idempotencyRecord{Key: id, CreatedAt: time.Now()})
+	if mongo.IsDuplicateKeyError(err) {
+		var record idempotencyRecord
+		if err := keys.FindOne(reserveCtx, bson.M{"_id": id}).Decode(&record); err != nil {
+			return bson.RawValue{}, fmt.Errorf("failed to read idempotency key %s: %v", key, err)
+		}
+		if !record.Done {
+			return bson.RawValue{}, ErrIdempotencyInProgress
+		}
+		return record.Result, nil
+	}
+	if err != nil {
+		return bson.RawValue{}, fmt.Errorf("failed to reserve idempotency key %s: %v", key, err)
+	}
+
+	result, err := op()
+	bookkeepingCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), idempotencyTimeout)
+	defer cancel()
+	if err != nil {
+		// Release the key so the operation can be retried
+		if _, releaseErr := keys.DeleteOne(bookkeepingCtx, bson.M{"_id": id}); releaseErr != nil {
+			return bson.RawValue{}, errors.Join(err, fmt.Errorf("failed to release idempotency key %s: %v", key, releaseErr))
+		}
+		return bson.RawValue{}, err
+	}
+
+	stored, err := marshalRawValue(result)
+	if err != nil {
+		return bson.RawValue{}, err
+	}
+	_, err = keys.UpdateOne(bookkeepingCtx, bson.M{"_id": id}, bson.M{"$set": bson.M{"done": true, "result": result}})
+	if err != nil {
+		return stored, fmt.Errorf("%w: key %s: %v", ErrIdempotencyNotRecorded, key, err)
+	}
+	return stored, nil
+}
+
+// ensureIdempotencyIndex creates the TTL index on the keys collection once per database. It runs
+// outside the caller's session, since indexes cannot be created inside a transaction.
+func ensureIdempotencyIndex(db *mongo.Database, keys *mongo.Collection) error {
+	if _, done := ttlIndexes.Load(db.Name()); done {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), idempotencyTimeout)
+	defer cancel()
+	_, err := keys.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "createdAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(IdempotencyTTL.Seconds())),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create idempotency TTL index: %v", err)
+	}
+	ttlIndexes.Store(db.Name(), true)
+	return nil
+}
+
+// marshalRawValue converts a result into the form it is stored in.
+func marshalRawValue(value interface{}) (bson.RawValue, error) {
+	kind, data, err := bson.MarshalValue(value)
+	if err != nil {
+		return bson.RawValue{}, err
+	}
+	return bson.RawValue{Type: kind, Value: data}, nil
+}
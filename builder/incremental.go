@@ -0,0 +1,287 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// WatermarkCollection stores the high watermarks of incremental aggregations.
+	WatermarkCollection = "mongoquery_watermarks"
+
+	// WatermarkLease is how long a run holds the watermark document of its aggregation. A run
+	// that stopped without releasing it keeps the next runs out until it expires.
+	WatermarkLease = 10 * time.Minute
+
+	// ErrIncrementalRunInProgress is returned when another run of the aggregation holds its lease.
+	ErrIncrementalRunInProgress = errors.New("another run of this incremental aggregation is in progress")
+)
+
+// watermarkRecord is the document stored per incremental aggregation.
+type watermarkRecord struct {
+	Key       string        `bson:"_id"`
+	Value     bson.RawValue `bson:"value"`
+	UpdatedAt time.Time     `bson:"updatedAt"`
+}
+
+// IncrementalAggregation maintains a summary collection, such as counters or rollups, from the
+// documents added to a source collection since its previous run:
+//
+//	job := builder.NewIncrementalAggregation("orders", "order_totals", "created_at").
+//		GroupBy("status").
+//		Aggregate("SUM(amount) AS total", "COUNT(*) AS orders")
+//	run, err := job.Run(mdb.Database)
+//
+// Each run groups the documents whose watermark field lies above the watermark of the previous
+// run and adds the groups to the summary documents, creating the missing ones. The watermark
+// field must increase with every new document, like an insertion date or an ObjectID _id. A run
+// holds a lease on its watermark so runs do not overlap, and updates the summary documents and
+// the watermark in one transaction, so every document is counted exactly once. Runs need a
+// replica set or sharded cluster.
+type IncrementalAggregation struct {
+	Source     string
+	Target     string
+	Watermark  string
+	groupKeys  []string
+	aggregates []string
+	opts       ExecuteOptions
+	ctx        context.Context
+}
+
+// IncrementalRun describes one run of an IncrementalAggregation.
+type IncrementalRun struct {
+	From  bson.RawValue // Watermark of the previous run, zero for the first run
+	To    bson.RawValue // Watermark of the newest document merged by this run
+	Empty bool          // No document was added since the previous run
+}
+
+// mergeOperators maps the accumulators of mergeable aggregates to the expression combining the
+// existing value with the value of the new group.
+var mergeOperators = map[string]string{
+	"$sum":      "$add",
+	"$min":      "$min",
+	"$max":      "$max",
+	"$push":     "$concatArrays",
+	"$addToSet": "$setUnion",
+}
+
+// mergeIdentities are the values standing in for a summary field that does not exist yet, for
+// the operators that do not ignore missing values.
+var mergeIdentities = map[string]interface{}{
+	"$add":          0,
+	"$concatArrays": bson.A{},
+	"$setUnion":     bson.A{},
+}
+
+// NewIncrementalAggregation creates an incremental aggregation of source into target, tracking
+// the watermark field.
+func NewIncrementalAggregation(source, target, watermark string) *IncrementalAggregation {
+	return &IncrementalAggregation{Source: source, Target: target, Watermark: watermark}
+}
+
+// GroupBy sets the fields the summary documents are grouped on; without them a single summary
+// document totals the whole collection.
+func (ia *IncrementalAggregation) GroupBy(fields ...string) *IncrementalAggregation {
+	ia.groupKeys = append(ia.groupKeys, fields...)
+	return ia
+}
+
+// Aggregate adds aggregates like "SUM(amount) AS total" or "COUNT(*) AS orders" to the summary
+// documents. SUM, COUNT, MIN, MAX, LAST, PUSH and ADDTOSET merge across runs; AVG and FIRST
+// do not, keep a SUM and a COUNT to compute averages instead.
+func (ia *IncrementalAggregation) Aggregate(aggregations ...string) *IncrementalAggregation {
+	ia.aggregates = append(ia.aggregates, aggregations...)
+	return ia
+}
+
+// WithOptions sets how the grouping query of each run is executed. Without a Timeout the runs
+// are bounded by the deadline of the context, or by the default of Execute when it has none; a
+// first run over a large collection may need a negative Timeout and AllowDiskUse.
+func (ia *IncrementalAggregation) WithOptions(opts ExecuteOptions) *IncrementalAggregation {
+	ia.opts = opts
+	return ia
+}
+
+// WithContext makes the runs use ctx.
+func (ia *IncrementalAggregation) WithContext(ctx context.Context) *IncrementalAggregation {
+	ia.ctx = ctx
+	return ia
+}
+
+// Run merges the documents added since the previous run into the summary collection and
+// records the new watermark. Documents added during the run are left for the next one. While
+// another run holds the lease, Run fails with ErrIncrementalRunInProgress.
+func (ia *IncrementalAggregation) Run(db *mongo.Database) (*IncrementalRun, error) {
+	if ia.Source == "" || ia.Target == "" || ia.Watermark == "" {
+		return nil, errors.New("source, target and watermark field are required")
+	}
+	ctx := orBackground(ia.ctx)
+	marks := db.Collection(WatermarkCollection)
+	key := ia.Source + ":" + ia.Target
+	owner, err := ia.lease(ctx, marks, key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		marks.UpdateOne(releaseCtx, bson.M{"_id": key, "lease.owner": owner}, bson.M{"$unset": bson.M{"lease": ""}})
+	}()
+
+	// The previous watermark and the newest document bound the documents of this run
+	run := &IncrementalRun{}
+	var record watermarkRecord
+	if err := marks.FindOne(ctx, bson.M{"_id": key}).Decode(&record); err != nil {
+		return nil, fmt.Errorf("failed to read watermark of %s: %v", ia.Target, err)
+	}
+	run.From = record.Value
+
+	var newest bson.Raw
+	opts := options.FindOne().SetSort(bson.D{{Key: ia.Watermark, Value: -1}}).SetProjection(bson.M{ia.Watermark: 1})
+	err = db.Collection(ia.Source).FindOne(ctx, ia.window(run.From, bson.RawValue{}), opts).Decode(&newest)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		run.To, run.Empty = run.From, true
+		return run, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the newest document of %s: %v", ia.Source, err)
+	}
+	if run.To, err = newest.LookupErr(ia.Watermark); err != nil {
+		return nil, fmt.Errorf("newest document of %s has no %s", ia.Source, ia.Watermark)
+	}
+
+	qb, combine, err := ia.pipeline(run.From, run.To)
+	if err != nil {
+		return nil, err
+	}
+	queryOpts := ia.opts
+	if _, ok := ctx.Deadline(); ok && queryOpts.Timeout == 0 {
+		queryOpts.Timeout = -1 // The deadline of ctx bounds the run
+	}
+	groups, err := qb.WithContext(ctx).WithOptions(queryOpts).Execute(db)
+	if err != nil {
+		return nil, err
+	}
+
+	merges := make([]mongo.WriteModel, len(groups))
+	for i, group := range groups {
+		merges[i] = mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": group["_id"]}).SetUpdate(mergeUpdate(group, combine)).SetUpsert(true)
+	}
+	err = RunTransaction(ctx, db.Client(), RetryPolicy{}, func(ctx mongo.SessionContext) error {
+		if _, err := db.Collection(ia.Target).BulkWrite(ctx, merges); err != nil {
+			return fmt.Errorf("failed to merge into %s: %v", ia.Target, err)
+		}
+		update := bson.M{"$set": bson.M{"value": run.To, "updatedAt": time.Now()}}
+		result, err := marks.UpdateOne(ctx, bson.M{"_id": key, "lease.owner": owner}, update)
+		if err != nil {
+			return fmt.Errorf("failed to record watermark of %s: %w", ia.Target, err)
+		}
+		if result.MatchedCount == 0 {
+			return fmt.Errorf("lease on the watermark of %s expired during the run", ia.Target)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// lease takes the lease on the watermark document key, creating the document on the first run,
+// and returns the owner recorded in it.
+func (ia *IncrementalAggregation) lease(ctx context.Context, marks *mongo.Collection, key string) (primitive.ObjectID, error) {
+	owner, now := primitive.NewObjectID(), time.Now()
+	free := bson.M{"_id": key, "$or": bson.A{
+		bson.M{"lease": bson.M{"$exists": false}},
+		bson.M{"lease.until": bson.M{"$lte": now}},
+	}}
+	lease := bson.M{"$set": bson.M{"lease": bson.M{"owner": owner, "until": now.Add(WatermarkLease)}}}
+	_, err := marks.UpdateOne(ctx, free, lease, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return owner, ErrIncrementalRunInProgress // The document exists with a live lease
+	}
+	if err != nil {
+		return owner, fmt.Errorf("failed to lease watermark of %s: %v", ia.Target, err)
+	}
+	return owner, nil
+}
+
+// window returns the filter of the documents above from and up to to, leaving out zero bounds.
+func (ia *IncrementalAggregation) window(from, to bson.RawValue) bson.M {
+	bounds := bson.M{}
+	if from.Type != 0 {
+		bounds["$gt"] = from
+	}
+	if to.Type != 0 {
+		bounds["$lte"] = to
+	}
+	if len(bounds) == 0 {
+		return bson.M{}
+	}
+	return bson.M{ia.Watermark: bounds}
+}
+
+// pipeline builds the query grouping the documents of the window, and returns it with the
+// operator combining each aggregate with its summary field, see mergeUpdate.
+func (ia *IncrementalAggregation) pipeline(from, to bson.RawValue) (*QueryBuilder, map[string]string, error) {
+	for _, aggregate := range ia.aggregates {
+		if !IsAggregate(aggregate) {
+			return nil, nil, fmt.Errorf("unsupported aggregate %s", aggregate)
+		}
+	}
+	qb := NewQueryBuilder().From(ia.Source).NoMemo()
+	qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$match", Value: ia.window(from, to)}})
+	if len(ia.groupKeys) == 0 {
+		qb.GroupAll(ia.aggregates...)
+	} else {
+		qb.GroupBy(append(append([]string{}, ia.groupKeys...), ia.aggregates...)...)
+	}
+
+	combine := map[string]string{}
+	for field, value := range qb.Group {
+		accumulator, ok := value.(bson.M)
+		if field == "_id" || !ok {
+			continue
+		}
+		for operator := range accumulator {
+			switch combiner, mergeable := mergeOperators[operator]; {
+			case operator == "$last":
+				combine[field] = operator
+			case mergeable:
+				combine[field] = combiner
+			default:
+				return nil, nil, fmt.Errorf("aggregate %s (%s) cannot be merged across runs", field, operator)
+			}
+		}
+	}
+	return qb, combine, nil
+}
+
+// mergeUpdate returns the pipeline update adding group to its summary document: aggregates
+// are combined with the existing values, group keys and LAST aggregates are set.
+func mergeUpdate(group map[string]interface{}, combine map[string]string) []bson.M {
+	set := bson.M{}
+	for field, value := range group {
+		if field == "_id" {
+			continue
+		}
+		combiner, ok := combine[field]
+		if !ok || combiner == "$last" {
+			set[field] = bson.M{"$literal": value}
+			continue
+		}
+		var existing interface{} = "$" + field
+		if identity, ok := mergeIdentities[combiner]; ok {
+			existing = bson.M{"$ifNull": bson.A{existing, identity}}
+		}
+		set[field] = bson.M{combiner: bson.A{existing, bson.M{"$literal": value}}}
+	}
+	return []bson.M{{"$set": set}}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // InsertBuilder helps in inserting documents into a MongoDB collection.
@@ -13,6 +16,13 @@ type InsertBuilder struct {
 	Collection string
 	Fields     []string
 	ValuesList [][]interface{}
+
+	idempotencyKey string
+	confirmTimeout time.Duration
+	deadLetters    deadLetterPolicy
+	ctx            context.Context
+
+	buildErrors
 }
 
 // NewInsertBuilder initializes a new InsertBuilder for a specific collection.
@@ -27,21 +37,54 @@ func (ib *InsertBuilder) InsertInto(collection string, fields []string) *InsertB
 	return ib
 }
 
-// Values adds a row of values corresponding to the specified fields.
+// Values adds a row of values corresponding to the specified fields. A row with another number
+// of values is an error of the builder, see Err.
 func (ib *InsertBuilder) Values(values []interface{}) *InsertBuilder {
 	if len(values) != len(ib.Fields) {
-		panic("number of values must match the number of fields")
+		ib.fail("row %d has %d values for %d fields", len(ib.ValuesList)+1, len(values), len(ib.Fields))
+		return ib
 	}
 	ib.ValuesList = append(ib.ValuesList, values)
 	return ib
 }
 
 // Execute performs the insert operation.
-func (ib *InsertBuilder) Execute(db *mongo.Database) (interface{}, error) {
+func (ib *InsertBuilder) Execute(db *mongo.Database) (_ interface{}, err error) {
+	defer recoverTo(&err)
 	if ib.Collection == "" {
 		return nil, errors.New("collection name is not specified")
 	}
+	if err := ib.Err(); err != nil {
+		return nil, err
+	}
+	ctx, release, err := trackContext(ib.ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if ib.idempotencyKey == "" {
+		return ib.execute(ctx, db)
+	}
+
+	stored, err := runIdempotent(ctx, db, ib.Collection, ib.idempotencyKey, func() (interface{}, error) {
+		return ib.execute(ctx, db)
+	})
+	if err != nil && !errors.Is(err, ErrIdempotencyNotRecorded) {
+		return nil, err
+	}
+	var ids interface{}
+	if err := stored.Unmarshal(&ids); err != nil {
+		return nil, fmt.Errorf("failed to decode stored insert result: %v", err)
+	}
+	if list, ok := ids.(primitive.A); ok {
+		return []interface{}(list), err
+	}
+	return ids, err
+}
 
+// execute inserts the documents with ctx and returns the inserted ID or IDs.
+func (ib *InsertBuilder) execute(ctx context.Context, db *mongo.Database) (interface{}, error) {
 	collection := db.Collection(ib.Collection)
 	documents := []interface{}{}
 
@@ -51,23 +94,80 @@ func (ib *InsertBuilder) Execute(db *mongo.Database) (interface{}, error) {
 		for i, field := range ib.Fields {
 			document[field] = row[i]
 		}
+		annotate(ctx, document)
 		documents = append(documents, document)
 	}
+	if ib.confirmTimeout > 0 {
+		return ib.insertConfirmed(ctx, collection, documents)
+	}
+	return ib.insert(ctx, collection, documents)
+}
+
+// insertConfirmed inserts the documents and waits for their change events.
+func (ib *InsertBuilder) insertConfirmed(ctx context.Context, collection *mongo.Collection, documents []interface{}) (interface{}, error) {
+	ids := make([]interface{}, len(documents))
+	for i, document := range documents {
+		fields := document.(map[string]interface{})
+		if _, ok := fields["_id"]; !ok {
+			fields["_id"] = primitive.NewObjectID()
+		}
+		ids[i] = fields["_id"]
+	}
+
+	var inserted interface{}
+	_, err := confirmWrite(ctx, collection, ids, []string{"insert"}, ib.confirmTimeout, func() (int64, error) {
+		var err error
+		inserted, err = ib.insert(ctx, collection, documents)
+		if ids, ok := inserted.([]interface{}); ok {
+			return int64(len(ids)), err // Dead letters are not inserted
+		}
+		return int64(len(documents)), err
+	})
+	return inserted, err
+}
+
+// insert performs InsertOne or InsertMany with ctx and returns the inserted ID or IDs.
+func (ib *InsertBuilder) insert(ctx context.Context, collection *mongo.Collection, documents []interface{}) (interface{}, error) {
+	if ib.deadLetters.sink != nil && len(documents) > 0 {
+		return ib.insertDeadLettered(ctx, collection, documents)
+	}
 
 	// Perform the insert
 	if len(documents) == 1 {
-		res, err := collection.InsertOne(context.TODO(), documents[0])
+		res, err := collection.InsertOne(ctx, documents[0], commented(ctx, options.InsertOne()))
 		if err != nil {
-			return nil, fmt.Errorf("failed to insert document: %v", err)
+			return nil, fmt.Errorf("failed to insert document: %w", err)
 		}
 		return res.InsertedID, nil
 	} else if len(documents) > 1 {
-		res, err := collection.InsertMany(context.TODO(), documents)
+		res, err := collection.InsertMany(ctx, documents, commented(ctx, options.InsertMany()))
 		if err != nil {
-			return nil, fmt.Errorf("failed to insert documents: %v", err)
+			return nil, fmt.Errorf("failed to insert documents: %w", err)
 		}
 		return res.InsertedIDs, nil
 	}
 
 	return nil, errors.New("no documents to insert")
 }
+
+// insertDeadLettered inserts the documents unordered, handing the ones that fail to the dead
+// letter sink, and returns the IDs of the inserted documents.
+func (ib *InsertBuilder) insertDeadLettered(ctx context.Context, collection *mongo.Collection, documents []interface{}) (interface{}, error) {
+	for _, document := range documents {
+		if fields := document.(map[string]interface{}); fields["_id"] == nil {
+			fields["_id"] = primitive.NewObjectID() // Known before the write, for the IDs of the written documents
+		}
+	}
+	written, err := ib.deadLetters.deliver(ctx, collection.Name(), documents, func(_ []int, batch []interface{}) error {
+		_, err := collection.InsertMany(ctx, batch, options.InsertMany().SetOrdered(false))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert documents: %w", err)
+	}
+	ids := make([]interface{}, len(written))
+	for i, position := range written {
+		ids[i] = documents[position].(map[string]interface{})["_id"]
+	}
+	return ids, nil
+}
@@ -0,0 +1,238 @@
+package builder
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// JoinShape controls how joined documents are returned.
+type JoinShape int
+
+const (
+	JoinNested JoinShape = iota // Joined documents as an array under the alias (MongoDB style)
+	JoinFlat                    // One row per joined pair with the joined document under the alias (SQL style)
+	JoinSingle                  // The first joined document under the alias, for to-one joins
+	JoinInner                   // Like JoinFlat, but documents without a joined document are dropped (INNER JOIN)
+)
+
+var (
+	// joinConditionSplitter splits an ON clause into its ANDed conditions.
+	joinConditionSplitter = regexp.MustCompile(`(?i)\s+AND\s+`)
+
+	// joinCondition matches a single ON condition like "orders.total >= limits.min".
+	joinCondition = regexp.MustCompile(`^\s*(\S+)\s*(=|!=|<>|>=|<=|>|<)\s*(\S+)\s*$`)
+)
+
+// JoinOn adds a $lookup stage using let/pipeline, so the join can combine several ANDed
+// conditions including inequalities, e.g. "orders.customerId = c._id AND orders.date >= c.since".
+// Fields qualified with the alias or the joined collection name refer to the joined documents;
+// all other fields refer to the current documents.
+func (qb *QueryBuilder) JoinOn(fromCollection, as, on string) *QueryBuilder {
+	let := bson.M{}
+	conditions := []interface{}{}
+
+	for _, part := range joinConditionSplitter.Split(strings.TrimSpace(on), -1) {
+		matches := joinCondition.FindStringSubmatch(part)
+		if matches == nil {
+			qb.fail("invalid join condition %q", part)
+			continue
+		}
+		mongoOperator := mapOperatorToMongo(matches[2])
+		conditions = append(conditions, bson.M{mongoOperator: []interface{}{
+			qb.joinOperand(matches[1], fromCollection, as, let),
+			qb.joinOperand(matches[3], fromCollection, as, let),
+		}})
+	}
+
+	lookup := bson.M{
+		"from":     fromCollection,
+		"pipeline": []bson.D{{{Key: "$match", Value: bson.M{"$expr": bson.M{"$and": conditions}}}}},
+		"as":       as,
+	}
+	if len(let) > 0 {
+		lookup["let"] = let
+	}
+	qb.addJoinAlias(as, fromCollection)
+	qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$lookup", Value: lookup}})
+	qb.shapeJoin(as)
+	return qb
+}
+
+// SetJoinShape sets how documents of subsequent joins are returned (JoinNested by default).
+func (qb *QueryBuilder) SetJoinShape(shape JoinShape) *QueryBuilder {
+	qb.joinShape = shape
+	return qb
+}
+
+// lookup appends a plain equality $lookup stage.
+func (qb *QueryBuilder) lookup(localField, fromCollection, foreignField, as string) {
+	qb.addJoinAlias(as, fromCollection)
+	qb.Pipeline = append(qb.Pipeline, bson.D{
+		{Key: "$lookup", Value: bson.M{
+			"from":         fromCollection,
+			"localField":   qb.resolveField(localField),
+			"foreignField": foreignField,
+			"as":           as,
+		}},
+	})
+}
+
+// shapeJoin reshapes the joined array stored under as according to the join shape.
+func (qb *QueryBuilder) shapeJoin(as string) {
+	switch qb.joinShape {
+	case JoinFlat:
+		qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$unwind", Value: bson.M{
+			"path":                       "$" + as,
+			"preserveNullAndEmptyArrays": true,
+		}}})
+	case JoinInner:
+		qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$unwind", Value: "$" + as}})
+	case JoinSingle:
+		qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$set", Value: bson.M{
+			as: bson.M{"$arrayElemAt": []interface{}{"$" + as, 0}},
+		}}})
+	}
+}
+
+// addJoinAlias records that documents joined from collection are stored under as.
+func (qb *QueryBuilder) addJoinAlias(as, collection string) {
+	if qb.joinAliases == nil {
+		qb.joinAliases = map[string]string{}
+	}
+	qb.joinAliases[as] = collection
+}
+
+// resolveField strips the collection alias from a qualified field ("e.name" becomes "name").
+// Fields qualified with a join alias are kept, since joined documents are stored under it,
+// and fields qualified with a joined collection's name are moved under its alias
+// ("orders.total" becomes "o.total" after JOIN orders o).
+func (qb *QueryBuilder) resolveField(field string) string {
+	field = qb.normalizeName(field)
+	if name, end, quoted, ok := scanIdentifier(field, 0); ok && quoted && end == len(field) {
+		field = name // Strip backticks from quoted paths like `address`.`city`
+	}
+	qualifier, rest, ok := strings.Cut(field, ".")
+	if !ok {
+		return field
+	}
+	if _, joined := qb.joinAliases[qualifier]; joined {
+		return field
+	}
+	if (qb.Alias != "" && qualifier == qb.Alias) || (qb.Collection != "" && qualifier == qb.Collection) {
+		return rest
+	}
+	for alias, collection := range qb.joinAliases {
+		if qualifier == collection {
+			return alias + "." + rest
+		}
+	}
+	return field
+}
+
+// joinOperand resolves one side of an ON condition into a literal, a joined field,
+// or a let variable bound to a field of the current documents.
+func (qb *QueryBuilder) joinOperand(operand, fromCollection, as string, let bson.M) interface{} {
+	if strings.HasPrefix(operand, "'") || strings.HasPrefix(operand, "\"") {
+		if text, end, ok := scanString(operand, 0); ok && end == len(operand) {
+			return bson.M{"$literal": text}
+		}
+	}
+	if value := qb.convertValue(operand); value != interface{}(operand) {
+		return value // Numeric literal
+	}
+	if name, end, quoted, ok := scanIdentifier(operand, 0); ok && quoted && end == len(operand) {
+		operand = name // Strip backticks from quoted names
+	}
+
+	if qualifier, field, ok := strings.Cut(operand, "."); ok {
+		switch {
+		case qualifier == as:
+			return "$" + field
+		case qualifier == qb.Alias, qualifier == qb.Collection:
+			operand = field
+		case qualifier == fromCollection:
+			return "$" + field
+		}
+	}
+
+	// Reuse the variable when the same local field appears more than once
+	for name, value := range let {
+		if value == "$"+operand {
+			return "$$" + name
+		}
+	}
+	name := fmt.Sprintf("local%d", len(let))
+	let[name] = "$" + operand
+	return "$$" + name
+}
+
+// RightJoin emulates a RIGHT JOIN by re-rooting the pipeline on fromCollection and looking up
+// the current documents. Documents keep the LEFT JOIN shape: current fields at the top level and
+// the joined document under as. The stages built so far run inside the $lookup (MongoDB 5.0+).
+func (qb *QueryBuilder) RightJoin(localField, fromCollection, foreignField, as string) *QueryBuilder {
+	const matched = "__rightJoin"
+
+	lookup := bson.M{
+		"from":         qb.sourceCollection(),
+		"localField":   foreignField,
+		"foreignField": qb.resolveField(localField),
+		"as":           matched,
+	}
+	if len(qb.Pipeline) > 0 {
+		lookup["pipeline"] = qb.Pipeline
+	}
+
+	qb.source = fromCollection
+	qb.addJoinAlias(as, fromCollection)
+	qb.Pipeline = []bson.D{
+		{{Key: "$lookup", Value: lookup}},
+		{{Key: "$unwind", Value: bson.M{"path": "$" + matched, "preserveNullAndEmptyArrays": true}}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": bson.M{"$mergeObjects": []interface{}{
+			bson.M{"$ifNull": []interface{}{"$" + matched, bson.M{}}},
+			bson.M{as: []interface{}{"$$ROOT"}},
+		}}}}},
+		{{Key: "$unset", Value: as + "." + matched}},
+	}
+	qb.shapeJoin(as)
+	return qb
+}
+
+// FullOuterJoin emulates a FULL OUTER JOIN as a LEFT JOIN followed by a $unionWith of the
+// joined documents that match none of the current documents (MongoDB 5.0+).
+func (qb *QueryBuilder) FullOuterJoin(localField, fromCollection, foreignField, as string) *QueryBuilder {
+	const unmatched = "__fullOuterJoin"
+
+	antiJoin := bson.M{
+		"from":         qb.sourceCollection(),
+		"localField":   foreignField,
+		"foreignField": qb.resolveField(localField),
+		"as":           unmatched,
+	}
+	if len(qb.Pipeline) > 0 {
+		antiJoin["pipeline"] = append([]bson.D{}, qb.Pipeline...)
+	}
+
+	qb.lookup(localField, fromCollection, foreignField, as)
+	qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$unionWith", Value: bson.M{
+		"coll": fromCollection,
+		"pipeline": []bson.D{
+			{{Key: "$lookup", Value: antiJoin}},
+			{{Key: "$match", Value: bson.M{unmatched: bson.M{"$size": 0}}}},
+			{{Key: "$replaceRoot", Value: bson.M{"newRoot": bson.M{as: []interface{}{"$$ROOT"}}}}},
+			{{Key: "$unset", Value: as + "." + unmatched}},
+		},
+	}}})
+	qb.shapeJoin(as)
+	return qb
+}
+
+// sourceCollection returns the collection the pipeline is executed against.
+func (qb *QueryBuilder) sourceCollection() string {
+	if qb.source != "" {
+		return qb.source
+	}
+	return qb.Collection
+}
@@ -0,0 +1,75 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// JSONMode selects the Extended JSON v2 format written by ExecuteToJSON.
+type JSONMode int
+
+const (
+	JSONRelaxed   JSONMode = iota // Relaxed mode: plain JSON numbers and ISO-8601 dates where lossless
+	JSONCanonical                 // Canonical mode: every BSON type is wrapped, e.g. {"$numberInt": "1"}
+)
+
+// ExecuteToJSON executes the query and streams the results to w as a JSON array of
+// Extended JSON v2 documents, so types like ObjectId and Decimal128 round-trip losslessly.
+func (qb *QueryBuilder) ExecuteToJSON(db *mongo.Database, w io.Writer, mode JSONMode) (err error) {
+	defer recoverTo(&err)
+	ctx, release, err := trackContext(qb.ctx, db)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	observe := qb.observeExecution(ctx)
+	var documents int
+	qb.profiled(ctx, func(ctx context.Context) {
+		documents, err = qb.executeToJSON(ctx, db, w, mode)
+	})
+	observe(documents, err)
+	return err
+}
+
+// executeToJSON streams the results to w and returns the number of documents written.
+func (qb *QueryBuilder) executeToJSON(ctx context.Context, db *mongo.Database, w io.Writer, mode JSONMode) (int, error) {
+	ctx, cancel := qb.timeout(ctx)
+	defer cancel()
+
+	cursor, err := qb.aggregate(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	if _, err := io.WriteString(w, "["); err != nil {
+		return 0, err
+	}
+	tuner := qb.batchTuner()
+	documents := 0
+	for ; cursor.Next(ctx); documents++ {
+		tuner.observe(cursor)
+		doc, err := bson.MarshalExtJSON(cursor.Current, mode == JSONCanonical, false)
+		if err != nil {
+			return documents, fmt.Errorf("failed to encode result: %v", err)
+		}
+		if documents > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return documents, err
+			}
+		}
+		if _, err := w.Write(doc); err != nil {
+			return documents, err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return documents, err
+	}
+	_, err = io.WriteString(w, "]")
+	return documents, err
+}
@@ -0,0 +1,225 @@
+package builder
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind classifies the tokens of a condition.
+type tokenKind int
+
+const (
+	tokenIdent    tokenKind = iota // Field names and keywords, e.g. "orders.total" or "IN"
+	tokenNumber                    // Numeric literals, e.g. "-5" or "2.75"
+	tokenString                    // Single- or double-quoted string literals, text without the quotes
+	tokenOperator                  // Comparison and arithmetic operators
+	tokenLParen
+	tokenRParen
+	tokenComma
+	tokenLiteral // Literal function like OBJECTID('65a1...') or DATE('2024-01-01'), see literal
+	tokenParam   // Placeholder bound to a value, "?", "?2" or ":name", see Match
+)
+
+// token is a lexical unit of a condition.
+type token struct {
+	kind   tokenKind
+	text   string
+	pos    int         // Byte offset in the condition
+	quoted bool        // Identifier written in backticks, never a keyword
+	value  interface{} // literal of a tokenLiteral
+}
+
+// is reports whether the token is the given keyword, ignoring case.
+func (t token) is(keyword string) bool {
+	return t.kind == tokenIdent && !t.quoted && strings.EqualFold(t.text, keyword)
+}
+
+// tokenize splits a condition like "status IN ('a', 'b') AND age >= 18" into tokens.
+func tokenize(input string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			text, end, ok := scanString(input, i)
+			if !ok {
+				return nil, errors.New("unterminated string literal")
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, pos: i})
+			i = end
+		case startsNumber(input[i:]) && (isDigit(c) || c == '.' || expectsOperand(tokens)):
+			end := i + len(numberLiteral.FindString(input[i:]))
+			tokens = append(tokens, token{kind: tokenNumber, text: input[i:end], pos: i})
+			i = end
+		case c == '`' || startsIdent(input[i:]):
+			text, end, quoted, ok := scanIdentifier(input, i)
+			if !ok {
+				return nil, errors.New("unterminated quoted identifier")
+			}
+			if strings.IndexByte(text, 0) != -1 {
+				return nil, errors.New("NUL byte in identifier") // BSON names cannot hold it
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: text, pos: i, quoted: quoted})
+			i = end
+		case c == '?' || c == ':' && i+1 < len(input) && startsIdent(input[i+1:]):
+			end := i + 1
+			for end < len(input) && c == '?' && isDigit(input[end]) {
+				end++
+			}
+			for end < len(input) && c == ':' {
+				r, size := utf8.DecodeRuneInString(input[end:])
+				if !isIdentRune(r) {
+					break
+				}
+				end += size
+			}
+			tokens = append(tokens, token{kind: tokenParam, text: input[i:end], pos: i})
+			i = end
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: i})
+			i++
+		default:
+			operator := ""
+			for _, candidate := range []string{"!=", "<>", ">=", "<=", "=", ">", "<", "+", "-", "*", "/"} {
+				if strings.HasPrefix(input[i:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, errors.New("unexpected character " + string(c))
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: operator, pos: i})
+			i += len(operator)
+		}
+	}
+	return foldLiteralCalls(input, tokens), nil
+}
+
+// scanString reads the quoted string starting at input[start], where a doubled quote stands for
+// one quote and a backslash escapes the next character (\n, \t and \r in string literals stand
+// for newline, tab and carriage return). It returns the text and the offset after the closing quote.
+func scanString(input string, start int) (string, int, bool) {
+	quote := input[start]
+	var text strings.Builder
+	for i := start + 1; i < len(input); i++ {
+		switch c := input[i]; {
+		case c == '\\' && i+1 < len(input):
+			i++
+			if quote == '`' {
+				text.WriteByte(input[i])
+			} else {
+				text.WriteByte(escapeSequence(input[i]))
+			}
+		case c == quote && i+1 < len(input) && input[i+1] == quote:
+			i++
+			text.WriteByte(quote)
+		case c == quote:
+			return text.String(), i + 1, true
+		default:
+			text.WriteByte(c)
+		}
+	}
+	return "", len(input), false
+}
+
+// escapeSequence returns the character a backslash escape like \n stands for.
+func escapeSequence(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	}
+	return c
+}
+
+// numberLiteral matches a numeric literal with optional sign, fraction and exponent: -5, .5, 1e6, 2.5E-3.
+var numberLiteral = regexp.MustCompile(`^[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?`)
+
+// startsNumber reports whether input starts with a numeric literal not followed by a word character.
+func startsNumber(input string) bool {
+	match := numberLiteral.FindString(input)
+	return match != "" && (len(match) == len(input) || !startsIdent(input[len(match):]))
+}
+
+// expectsOperand reports whether the next token starts an operand, so a minus sign is a negative number.
+func expectsOperand(tokens []token) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	switch last := tokens[len(tokens)-1]; last.kind {
+	case tokenOperator, tokenLParen, tokenComma:
+		return true
+	case tokenIdent:
+		return last.is("AND") || last.is("OR") || last.is("NOT") || last.is("IN") || last.is("BETWEEN")
+	}
+	return false
+}
+
+// isDigit reports whether c is a decimal digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// startsIdent reports whether input starts with a character that can start a field name,
+// including non-ASCII letters.
+func startsIdent(input string) bool {
+	r, _ := utf8.DecodeRuneInString(input)
+	return r == '_' || r == '$' || unicode.IsLetter(r)
+}
+
+// isIdentRune reports whether r can be part of a field name segment.
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// scanIdentifier reads a possibly dotted field name starting at input[start], whose segments are
+// plain names or names quoted in backticks, e.g. `größe`. It returns the name without backticks,
+// the offset after it, and whether any segment was quoted.
+func scanIdentifier(input string, start int) (string, int, bool, bool) {
+	var name strings.Builder
+	quoted := false
+	i := start
+	for {
+		if i < len(input) && input[i] == '`' {
+			text, end, ok := scanString(input, i)
+			if !ok {
+				return "", len(input), true, false
+			}
+			name.WriteString(text)
+			quoted, i = true, end
+		} else {
+			segment := i
+			for i < len(input) {
+				r, size := utf8.DecodeRuneInString(input[i:])
+				if !isIdentRune(r) {
+					break
+				}
+				i += size
+			}
+			name.WriteString(input[segment:i])
+		}
+
+		// Continue with the next path segment
+		if i+1 < len(input) && input[i] == '.' && (input[i+1] == '`' || startsIdent(input[i+1:])) {
+			name.WriteByte('.')
+			i++
+			continue
+		}
+		return name.String(), i, quoted, true
+	}
+}
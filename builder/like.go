@@ -0,0 +1,91 @@
+package builder
+
+import (
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// likeFilter builds the $regex filter of "field [NOT] LIKE|ILIKE 'pattern' [ESCAPE 'c']".
+// Unquoted operands, such as placeholders awaiting a bound value, are kept as they are.
+func (qb *QueryBuilder) likeFilter(field string, negated, insensitive bool, tokens []token) bson.M {
+	if len(tokens) != 1 && (len(tokens) != 3 || !tokens[1].is("ESCAPE") || tokens[2].kind != tokenString || len(tokens[2].text) != 1) {
+		return bson.M{}
+	}
+
+	var pattern string
+	switch tokens[0].kind {
+	case tokenString:
+		escape := byte(0)
+		if len(tokens) == 3 {
+			escape = tokens[2].text[0]
+		}
+		pattern = likeToRegex(tokens[0].text, escape)
+	case tokenIdent:
+		pattern = tokens[0].text
+	case tokenParam:
+		value, ok := qb.paramValue(tokens[0])
+		text, isString := value.(string)
+		if !ok || !isString {
+			return bson.M{}
+		}
+		escape := byte(0)
+		if len(tokens) == 3 {
+			escape = tokens[2].text[0]
+		}
+		pattern = likeToRegex(text, escape)
+	default:
+		return bson.M{}
+	}
+
+	regex := bson.M{"$regex": pattern}
+	if insensitive {
+		regex["$options"] = "i"
+	}
+	if negated {
+		return bson.M{field: bson.M{"$not": regex}}
+	}
+	return bson.M{field: regex}
+}
+
+// LikeToRegex converts an SQL LIKE pattern into an anchored regular expression, where % matches
+// any run of characters, _ matches one character, and everything else matches literally.
+func LikeToRegex(pattern string) string {
+	return likeToRegex(pattern, 0)
+}
+
+// likeToRegex converts a LIKE pattern; a wildcard preceded by escape matches literally.
+// Leading and trailing % are dropped from the anchors so prefix patterns can use an index.
+func likeToRegex(pattern string, escape byte) string {
+	var regex strings.Builder
+	regex.WriteString("^")
+	var literal strings.Builder
+	flush := func() {
+		regex.WriteString(regexp.QuoteMeta(literal.String()))
+		literal.Reset()
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case escape != 0 && c == escape && i+1 < len(pattern):
+			i++
+			literal.WriteByte(pattern[i])
+		case c == '%':
+			flush()
+			regex.WriteString(".*")
+		case c == '_':
+			flush()
+			regex.WriteString(".")
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	flush()
+	regex.WriteString("$")
+
+	result := strings.TrimPrefix(strings.TrimSuffix(regex.String(), ".*$"), "^.*")
+	if result == "" || result == "^" {
+		return "" // Matches everything
+	}
+	return result
+}
@@ -0,0 +1,130 @@
+package builder
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// literal is a literal function call like OBJECTID('65a1...'), DATE('2024-01-01') or NOW(), or a
+// typed literal like DATE '2024-01-01', folded into a single tokenLiteral.
+type literal struct {
+	name     string // Function name in upper case
+	argument string
+}
+
+// literalFunctions are the literal functions, with whether they take a string argument.
+var literalFunctions = map[string]bool{"OBJECTID": true, "DATE": true, "TIMESTAMP": true, "NOW": false}
+
+// dateLayouts are the ISO-8601 forms accepted by DATE and TIMESTAMP; those without an offset
+// are read in the query's time zone.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// TimeZone sets the time zone of date literals without an offset, like DATE('2024-01-01').
+// The default is UTC.
+func (qb *QueryBuilder) TimeZone(location *time.Location) *QueryBuilder {
+	qb.location = location
+	return qb
+}
+
+// TimeZone sets the time zone of date literals in the conditions of later Where calls, see
+// QueryBuilder.TimeZone.
+func (ub *UpdateBuilder) TimeZone(location *time.Location) *UpdateBuilder {
+	ub.location = location
+	return ub
+}
+
+// TimeZone sets the time zone of date literals in the conditions of later Where calls, see
+// QueryBuilder.TimeZone.
+func (db *DeleteBuilder) TimeZone(location *time.Location) *DeleteBuilder {
+	db.location = location
+	return db
+}
+
+// foldLiteralCalls replaces the tokens of literal function calls and typed literals with a
+// single tokenLiteral, so conditions treat them like any other value.
+func foldLiteralCalls(input string, tokens []token) []token {
+	folded := make([]token, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		name := strings.ToUpper(tok.text)
+		takesArgument, known := literalFunctions[name]
+		if tok.kind != tokenIdent || tok.quoted || !known {
+			folded = append(folded, tok)
+			continue
+		}
+
+		end, argument := -1, ""
+		switch {
+		case takesArgument && i+3 < len(tokens) && tokens[i+1].kind == tokenLParen && tokens[i+2].kind == tokenString && tokens[i+3].kind == tokenRParen:
+			end, argument = i+3, tokens[i+2].text // DATE('2024-01-01')
+		case takesArgument && i+1 < len(tokens) && tokens[i+1].kind == tokenString:
+			end, argument = i+1, tokens[i+1].text // DATE '2024-01-01'
+		case !takesArgument && i+2 < len(tokens) && tokens[i+1].kind == tokenLParen && tokens[i+2].kind == tokenRParen:
+			end = i + 2 // NOW()
+		}
+		if end == -1 {
+			folded = append(folded, tok)
+			continue
+		}
+		stop := tokens[end].pos + 1
+		if tokens[end].kind == tokenString {
+			_, stop, _ = scanString(input, tokens[end].pos)
+		}
+		folded = append(folded, token{kind: tokenLiteral, text: input[tok.pos:stop], pos: tok.pos, value: literal{name: name, argument: argument}})
+		i = end
+	}
+	return folded
+}
+
+// literalValue evaluates a literal token. It reports false for an invalid argument, such as
+// a malformed ObjectID or date, making the condition invalid.
+func (qb *QueryBuilder) literalValue(tok token) (interface{}, bool) {
+	l, _ := tok.value.(literal)
+	switch l.name {
+	case "OBJECTID":
+		id, err := primitive.ObjectIDFromHex(l.argument)
+		return id, err == nil
+	case "DATE":
+		date, ok := qb.parseDate(l.argument)
+		if !ok {
+			return nil, false
+		}
+		local := date.In(qb.timeZone())
+		return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, qb.timeZone()).UTC(), true
+	case "TIMESTAMP":
+		return qb.parseDate(l.argument)
+	case "NOW":
+		return time.Now().UTC(), true
+	}
+	return nil, false
+}
+
+// parseDate parses an ISO-8601 date or date-time, reading those without an offset in the
+// query's time zone.
+func (qb *QueryBuilder) parseDate(text string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if date, err := time.ParseInLocation(layout, strings.TrimSpace(text), qb.timeZone()); err == nil {
+			return date.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// timeZone returns the time zone of date literals.
+func (qb *QueryBuilder) timeZone() *time.Location {
+	if qb.location == nil {
+		return time.UTC
+	}
+	return qb.location
+}
@@ -0,0 +1,115 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoKey is the context key of the result memo.
+type memoKey struct{}
+
+// resultMemo caches the results of the queries executed with one context.
+type resultMemo struct {
+	mu      sync.Mutex
+	results map[string][]map[string]interface{}
+}
+
+// WithMemo returns a context under which identical queries executed with WithContext return
+// the result of the first execution instead of querying again, e.g. for the lifetime of one
+// HTTP request. Queries writing with $out, handing their rows to a MaxResultBytes overflow
+// handler or built with NoMemo are never cached.
+func WithMemo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, memoKey{}, &resultMemo{results: map[string][]map[string]interface{}{}})
+}
+
+// NoMemo makes the query always run against the database, even under a WithMemo context.
+func (qb *QueryBuilder) NoMemo() *QueryBuilder {
+	qb.noMemo = true
+	return qb
+}
+
+// memo returns the result memo of ctx and the query's key in it.
+func (qb *QueryBuilder) memo(ctx context.Context, database string) (*resultMemo, string, bool) {
+	if qb.noMemo || qb.OutCollection != "" || qb.overflow != nil {
+		return nil, "", false
+	}
+	memo, ok := ctx.Value(memoKey{}).(*resultMemo)
+	if !ok {
+		return nil, "", false
+	}
+	// The pipeline sent covers the scope, the stable sort, OFFSET, LIMIT and the tenant
+	pipeline, err := qb.executionPipeline(ctx)
+	if err != nil {
+		return nil, "", false
+	}
+	// %#v prints maps with sorted keys and values with their types, so equal queries get equal keys
+	key := fmt.Sprintf("%s.%s %#v %#v %#v %d %d %v %p %v %p %d",
+		database, qb.sourceCollection(), pipeline, qb.Collation, qb.execOptions, qb.MaxTimeMS,
+		qb.decodeProfile, qb.fieldTypes, qb.registry, qb.readFallback, qb.snapshot, qb.maxResultBytes)
+	return memo, key, true
+}
+
+// get returns a copy of the cached results for key.
+func (m *resultMemo) get(key string) ([]map[string]interface{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	results, ok := m.results[key]
+	return copyRows(results), ok
+}
+
+// put caches a copy of results under key.
+func (m *resultMemo) put(key string, results []map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results[key] = copyRows(results)
+}
+
+// copyRows returns a deep copy of the rows, so callers modifying a result, including its
+// subdocuments and arrays, do not change the cache.
+func copyRows(rows []map[string]interface{}) []map[string]interface{} {
+	if rows == nil {
+		return nil
+	}
+	copied := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		copied[i] = copyValue(row).(map[string]interface{})
+	}
+	return copied
+}
+
+// copyValue returns a copy of a decoded value sharing no maps, slices or byte arrays with it.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = copyValue(item)
+		}
+		return copied
+	case bson.M:
+		return bson.M(copyValue(map[string]interface{}(v)).(map[string]interface{}))
+	case bson.D:
+		copied := make(bson.D, len(v))
+		for i, e := range v {
+			copied[i] = bson.E{Key: e.Key, Value: copyValue(e.Value)}
+		}
+		return copied
+	case bson.A:
+		return bson.A(copyValue([]interface{}(v)).([]interface{}))
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	case []byte:
+		return append([]byte(nil), v...)
+	case primitive.Binary:
+		return primitive.Binary{Subtype: v.Subtype, Data: append([]byte(nil), v.Data...)}
+	}
+	return value
+}
@@ -6,13 +6,14 @@ import (
 
 // NestedGroupBy adds a nested $group stage to the pipeline.
 func (qb *QueryBuilder) NestedGroupBy(field string, aggregations ...string) *QueryBuilder {
-	nestedGroup := bson.M{"_id": "$" + field}
+	nestedGroup := bson.M{"_id": "$" + qb.resolveField(field)}
 
 	for _, agg := range aggregations {
 		alias := qb.parseAlias(agg) // Get the alias
 		aggregation, err := qb.parseAggregation(agg)
 		if err != nil {
-			continue // Skip unsupported aggregations
+			qb.fail("unsupported aggregation %q: %v", agg, err)
+			continue
 		}
 		nestedGroup[alias] = aggregation
 	}
@@ -0,0 +1,21 @@
+package builder
+
+import "golang.org/x/text/unicode/norm"
+
+// NormalizeNames normalizes field and collection names to a Unicode normalization form, so
+// names typed with combining characters ("e" + U+0301) match keys stored precomposed ("é").
+// MongoDB compares names byte by byte, so use the form the data was written in; norm.NFC is
+// the most common. It applies to the names used after the call.
+func (qb *QueryBuilder) NormalizeNames(form norm.Form) *QueryBuilder {
+	qb.normalization = &form
+	qb.Collection = qb.normalizeName(qb.Collection)
+	return qb
+}
+
+// normalizeName applies the configured normalization form to a name.
+func (qb *QueryBuilder) normalizeName(name string) string {
+	if qb.normalization == nil {
+		return name
+	}
+	return qb.normalization.String(name)
+}
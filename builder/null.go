@@ -0,0 +1,29 @@
+package builder
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// NullMode selects how IS NULL and IS NOT NULL conditions are translated.
+type NullMode int
+
+const (
+	NullEquality NullMode = iota // IS NULL matches null and missing fields: {field: null}
+	NullExists                   // IS NULL matches missing fields only: {field: {$exists: false}}
+)
+
+// NullSemantics selects how IS NULL and IS NOT NULL conditions are translated. With the
+// default NullEquality a field set to null and a missing field both count as NULL.
+func (qb *QueryBuilder) NullSemantics(mode NullMode) *QueryBuilder {
+	qb.nullMode = mode
+	return qb
+}
+
+// nullFilter builds the filter of "field IS [NOT] NULL".
+func (qb *QueryBuilder) nullFilter(field string, negated bool) bson.M {
+	if qb.nullMode == NullExists {
+		return bson.M{field: bson.M{"$exists": negated}}
+	}
+	if negated {
+		return bson.M{field: bson.M{"$ne": nil}}
+	}
+	return bson.M{field: nil}
+}
@@ -0,0 +1,70 @@
+package builder
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// StringIDs keeps quoted 24-character hex values in conditions as strings, for collections
+// whose ids are strings. By default they become ObjectIDs, so `_id = '65a1...'` matches.
+// OBJECTID('65a1...') is an ObjectID either way.
+func (qb *QueryBuilder) StringIDs(enabled bool) *QueryBuilder {
+	qb.stringIDs = enabled
+	return qb
+}
+
+// StringIDs keeps hex values in the conditions of later Where calls as strings, see
+// QueryBuilder.StringIDs.
+func (ub *UpdateBuilder) StringIDs(enabled bool) *UpdateBuilder {
+	ub.stringIDs = enabled
+	return ub
+}
+
+// StringIDs keeps hex values in the conditions of later Where calls as strings, see
+// QueryBuilder.StringIDs.
+func (db *DeleteBuilder) StringIDs(enabled bool) *DeleteBuilder {
+	db.stringIDs = enabled
+	return db
+}
+
+// MixedIDs sets whether a quoted hex value compared with _id by =, !=, IN or NOT IN matches
+// both the ObjectID and the raw string, as {_id: {$in: [ObjectID, "65a1..."]}}, for collections
+// mixing both id types. It is enabled by default; StringIDs compares with the string only.
+func (qb *QueryBuilder) MixedIDs(enabled bool) *QueryBuilder {
+	qb.exactIDs = !enabled
+	return qb
+}
+
+// MixedIDs sets whether the conditions of later Where calls compare _id with both the ObjectID
+// and the string of hex values, see QueryBuilder.MixedIDs.
+func (ub *UpdateBuilder) MixedIDs(enabled bool) *UpdateBuilder {
+	ub.exactIDs = !enabled
+	return ub
+}
+
+// MixedIDs sets whether the conditions of later Where calls compare _id with both the ObjectID
+// and the string of hex values, see QueryBuilder.MixedIDs.
+func (db *DeleteBuilder) MixedIDs(enabled bool) *DeleteBuilder {
+	db.exactIDs = !enabled
+	return db
+}
+
+// hexObjectID converts a string holding an ObjectID in hex to the ObjectID, unless StringIDs is
+// set, and returns other values unchanged.
+func (qb *QueryBuilder) hexObjectID(value string) interface{} {
+	if qb.stringIDs || !primitive.IsValidObjectID(value) {
+		return value
+	}
+	id, _ := primitive.ObjectIDFromHex(value)
+	return id
+}
+
+// idValues returns the ObjectID and the raw string of a hex value compared with _id, see
+// MixedIDs, or nil when the value only matches itself.
+func (qb *QueryBuilder) idValues(field, value string) []interface{} {
+	if _, typed := qb.fieldTypes[field]; typed || field != "_id" || qb.exactIDs {
+		return nil
+	}
+	id, ok := qb.hexObjectID(value).(primitive.ObjectID)
+	if !ok {
+		return nil
+	}
+	return []interface{}{id, value}
+}
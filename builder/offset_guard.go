@@ -0,0 +1,74 @@
+package builder
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DefaultMaxOffset is the offset above which Execute warns about deep $skip pagination.
+var DefaultMaxOffset int64 = 10000
+
+// OffsetWarning receives the queries executed with an offset above their OffsetGuard threshold.
+type OffsetWarning func(collection string, offset, threshold int64)
+
+var (
+	offsetWarningMu sync.RWMutex
+	offsetWarning   OffsetWarning
+)
+
+// SetOffsetWarning installs the function called when a query is executed with an offset above
+// its OffsetGuard threshold, e.g. to log it. Without one, or after SetOffsetWarning(nil), deep
+// offsets are allowed silently unless the guard is strict.
+func SetOffsetWarning(w OffsetWarning) {
+	offsetWarningMu.Lock()
+	defer offsetWarningMu.Unlock()
+	offsetWarning = w
+}
+
+// OffsetGuard sets the offset threshold for this query (zero uses DefaultMaxOffset, negative
+// disables the check). Above it Execute calls the function of SetOffsetWarning, or fails when
+// strict is true.
+func (qb *QueryBuilder) OffsetGuard(threshold int64, strict bool) *QueryBuilder {
+	qb.maxOffset = threshold
+	qb.strictOffset = strict
+	return qb
+}
+
+// checkOffset returns the error of a strict offset guard the query exceeds.
+func (qb *QueryBuilder) checkOffset() error {
+	offset, threshold, exceeded := qb.guardedOffset()
+	if !exceeded || !qb.strictOffset {
+		return nil
+	}
+	return fmt.Errorf("offset %d on collection %s exceeds %d; deep $skip scans every skipped document, consider keyset pagination (WHERE _id > last ORDER BY _id)", offset, qb.Collection, threshold)
+}
+
+// warnOffset reports an offset above the guard threshold to the function of SetOffsetWarning.
+// It is called when the query is sent, not when its pipeline is only built.
+func (qb *QueryBuilder) warnOffset() {
+	offsetWarningMu.RLock()
+	w := offsetWarning
+	offsetWarningMu.RUnlock()
+	if w == nil {
+		return
+	}
+	if offset, threshold, exceeded := qb.guardedOffset(); exceeded {
+		w(qb.Collection, offset, threshold)
+	}
+}
+
+// guardedOffset returns the largest of OFFSET and the $skip stages in the pipeline, the
+// threshold of the guard and whether the offset exceeds it.
+func (qb *QueryBuilder) guardedOffset() (int64, int64, bool) {
+	threshold := qb.maxOffset
+	if threshold == 0 {
+		threshold = DefaultMaxOffset
+	}
+	offset := qb.OffsetVal
+	for _, stage := range qb.Pipeline {
+		if skip, ok := stage[0].Value.(int64); ok && stage[0].Key == "$skip" && skip > offset {
+			offset = skip
+		}
+	}
+	return offset, threshold, threshold >= 0 && offset > threshold
+}
@@ -0,0 +1,32 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Out writes the query results into collection with a final $out stage, replacing its
+// contents. When failIfExists is true, Execute refuses to run if the collection already exists.
+func (qb *QueryBuilder) Out(collection string, failIfExists bool) *QueryBuilder {
+	qb.OutCollection = collection
+	qb.outFailIfExists = failIfExists
+	return qb
+}
+
+// checkOutTarget fails when the $out target must not exist but does.
+func (qb *QueryBuilder) checkOutTarget(ctx context.Context, db *mongo.Database) error {
+	if qb.OutCollection == "" || !qb.outFailIfExists {
+		return nil
+	}
+	names, err := db.ListCollectionNames(ctx, bson.M{"name": qb.OutCollection})
+	if err != nil {
+		return fmt.Errorf("failed to check collection %s: %v", qb.OutCollection, err)
+	}
+	if len(names) > 0 {
+		return fmt.Errorf("collection %s already exists", qb.OutCollection)
+	}
+	return nil
+}
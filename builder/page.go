@@ -0,0 +1,142 @@
+package builder
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// deadlineBatchSize is the cursor batch size of deadline-aware pages, small so that rows
+// arrive as they are found and a deadline cuts off at most one batch.
+const deadlineBatchSize = 16
+
+// Page is one page of query results.
+type Page struct {
+	Rows    []map[string]interface{}
+	Partial bool   // The deadline ended the page before it was filled
+	Next    string // Continuation token for ResumeFrom, empty when no rows remain
+}
+
+// DeadlineAware makes ExecutePage stop reading margin before the deadline of the query's
+// context and return the rows read so far as a partial page with a continuation token,
+// instead of failing with a timeout. Cursor batches are kept small so rows arrive early. A
+// deadline reached before the first row still fails, and without a deadline pages are not cut.
+func (qb *QueryBuilder) DeadlineAware(margin time.Duration) *QueryBuilder {
+	qb.deadlineMargin = margin
+	return qb
+}
+
+// ResumeFrom continues the query after the rows of the page that returned token. An invalid
+// token is an error of the builder, see Err.
+func (qb *QueryBuilder) ResumeFrom(token string) *QueryBuilder {
+	offset, err := decodePageToken(token)
+	if err != nil {
+		qb.fail("%v", err)
+		return qb
+	}
+	qb.OffsetVal = offset
+	return qb
+}
+
+// ExecutePage executes the query and returns its rows as a page. Full pages of LIMIT rows
+// and partial pages ended by a DeadlineAware deadline carry a continuation token.
+func (qb *QueryBuilder) ExecutePage(db *mongo.Database) (_ *Page, err error) {
+	defer recoverTo(&err)
+	if err := qb.Err(); err != nil {
+		return nil, err
+	}
+	ctx, release, err := trackContext(qb.ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	observe := qb.observeExecution(ctx)
+	page, err := qb.executePage(ctx, db)
+	if err != nil {
+		observe(0, err)
+		return nil, err
+	}
+	observe(len(page.Rows), nil)
+	return page, nil
+}
+
+// executePage reads the rows of one page.
+func (qb *QueryBuilder) executePage(ctx context.Context, db *mongo.Database) (*Page, error) {
+	ctx, cancel := qb.timeout(ctx)
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); ok && qb.deadlineMargin > 0 {
+		ctx, cancel = context.WithDeadline(ctx, deadline.Add(-qb.deadlineMargin))
+		defer cancel()
+	}
+
+	query := qb
+	if qb.stableSort == nil {
+		query = qb.Clone().StableSort(true) // Pages of ties would otherwise repeat or skip rows
+	}
+	offset := qb.OffsetVal
+	page := &Page{}
+	cursor, err := query.aggregate(ctx, db)
+	if err == nil {
+		defer cursor.Close(ctx)
+		for cursor.Next(ctx) {
+			var result map[string]interface{}
+			if err := cursor.Decode(&result); err != nil {
+				return nil, err
+			}
+			page.Rows = append(page.Rows, qb.decodeResult(result))
+		}
+		err = cursor.Err()
+	}
+	if err != nil {
+		// A partial page without rows would resume at the same offset forever
+		if qb.deadlineMargin <= 0 || len(page.Rows) == 0 || !(mongo.IsTimeout(err) || ctx.Err() != nil) {
+			return nil, err
+		}
+		page.Partial = true
+	}
+
+	if page.Partial || (qb.LimitVal > 0 && int64(len(page.Rows)) == qb.LimitVal) {
+		page.Next = encodePageToken(offset + int64(len(page.Rows)))
+	}
+	return page, nil
+}
+
+// deadlineOptions returns the batch size and server time limit of a deadline-aware page
+// running with ctx, or zeros when the page is not deadline-aware.
+func (qb *QueryBuilder) deadlineOptions(ctx context.Context) (int32, time.Duration) {
+	if qb.deadlineMargin <= 0 {
+		return 0, 0
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return deadlineBatchSize, 0
+	}
+	return deadlineBatchSize, max(time.Until(deadline), time.Millisecond)
+}
+
+// pageTokenPrefix starts the decoded continuation tokens.
+const pageTokenPrefix = "offset:"
+
+// encodePageToken encodes the offset of the next page as a continuation token.
+func encodePageToken(offset int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(pageTokenPrefix + strconv.FormatInt(offset, 10)))
+}
+
+// decodePageToken decodes the offset of a continuation token.
+func decodePageToken(token string) (int64, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		if text, ok := strings.CutPrefix(string(decoded), pageTokenPrefix); ok {
+			if offset, err := strconv.ParseInt(text, 10, 64); err == nil && offset >= 0 {
+				return offset, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid continuation token %q", token)
+}
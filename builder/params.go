@@ -0,0 +1,111 @@
+package builder
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Params holds the values of named placeholders, passed as an argument of Match:
+//
+//	qb.Match("created_at > :since AND status = :status", builder.Params{"since": since, "status": "active"})
+type Params map[string]interface{}
+
+// paramBinding holds the values bound to the placeholders of a condition.
+type paramBinding struct {
+	positional []interface{}
+	named      Params
+}
+
+// bindParams parses condition with parse, binding its placeholders to args: each ? takes the next
+// positional argument, ?N the Nth one and :name the value of name in a Params argument. Bound
+// values are used as they are, never converted from strings. A placeholder without a value or an
+// unused positional argument is an error of the builder, see Err.
+func (qb *QueryBuilder) bindParams(condition string, args []interface{}, parse func(string) bson.M) bson.M {
+	tokens, err := tokenize(condition)
+	if err != nil {
+		return parse(condition)
+	}
+	binding := &paramBinding{named: Params{}}
+	for _, arg := range args {
+		if named, ok := arg.(Params); ok {
+			maps.Copy(binding.named, named)
+		} else {
+			binding.positional = append(binding.positional, arg)
+		}
+	}
+
+	// Number the bare ? so each comparison, parsed on its own, keeps its argument
+	var numbered strings.Builder
+	used := make([]bool, len(binding.positional))
+	next, last := 0, 0
+	for _, tok := range tokens {
+		if tok.kind != tokenParam {
+			continue
+		}
+		if tok.text[0] == ':' {
+			if _, ok := binding.named[tok.text[1:]]; !ok {
+				qb.fail("no value for placeholder %s in %q", tok.text, condition)
+				return bson.M{}
+			}
+			continue
+		}
+		index, err := strconv.Atoi(tok.text[1:])
+		if err != nil {
+			next++
+			index = next
+			numbered.WriteString(condition[last:tok.pos] + "?" + strconv.Itoa(index))
+			last = tok.pos + len(tok.text)
+		}
+		if index < 1 || index > len(used) {
+			qb.fail("no value for placeholder ?%d in %q", index, condition)
+			return bson.M{}
+		}
+		used[index-1] = true
+	}
+	numbered.WriteString(condition[last:])
+	if unused := slices.Index(used, false); unused != -1 {
+		qb.fail("no placeholder for argument %d in %q", unused+1, condition)
+		return bson.M{}
+	}
+
+	previous := qb.params
+	qb.params = binding
+	defer func() { qb.params = previous }()
+	return parse(numbered.String())
+}
+
+// paramValue returns the value bound to a placeholder token, false outside bindParams.
+func (qb *QueryBuilder) paramValue(tok token) (interface{}, bool) {
+	if qb.params == nil {
+		return nil, false
+	}
+	if tok.text[0] == ':' {
+		value, ok := qb.params.named[tok.text[1:]]
+		return value, ok
+	}
+	index, err := strconv.Atoi(tok.text[1:])
+	if err != nil || index < 1 || index > len(qb.params.positional) {
+		return nil, false
+	}
+	return qb.params.positional[index-1], true
+}
+
+// paramList returns the elements of a slice bound to a placeholder, as in "status IN ?".
+// Byte slices and arrays such as ObjectIDs are single values.
+func (qb *QueryBuilder) paramList(tok token) ([]interface{}, bool) {
+	value, ok := qb.paramValue(tok)
+	list := reflect.ValueOf(value)
+	if !ok || list.Kind() != reflect.Slice && list.Kind() != reflect.Array || list.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	values := make([]interface{}, list.Len())
+	for i := range values {
+		values[i] = list.Index(i).Interface()
+	}
+	return values, true
+}
@@ -8,21 +8,44 @@ import (
 )
 
 // parseAggregation parses aggregation functions like "SUM(amount)".
-func (qb *QueryBuilder) parseAggregation(field string) (bson.M, error) {
-	field = strings.TrimSpace(field)
-
-	// Handle SUM
-	if strings.HasPrefix(strings.ToUpper(field), "SUM(") {
-		innerField := strings.TrimSuffix(strings.TrimPrefix(field, "SUM("), ")")
-		return bson.M{"$sum": "$" + strings.TrimSpace(innerField)}, nil
+func (qb *QueryBuilder) parseAggregation(field string) (_ bson.M, err error) {
+	defer recoverTo(&err)
+	field, _, _ = splitAlias(strings.TrimSpace(field))
+	name, argument, ok := functionCall(field)
+	if !ok {
+		return nil, errors.New("unsupported aggregation function")
 	}
 
-	// Handle COUNT
-	if strings.HasPrefix(strings.ToUpper(field), "COUNT(") {
+	if name == "COUNT" {
 		return bson.M{"$sum": 1}, nil
 	}
-
-	// Handle other aggregation functions (if needed)
-	// Example: MAX, MIN, etc.
+	if accumulator, ok := functions.Load().accumulators[name]; ok {
+		if argument == "" {
+			return nil, errors.New("aggregation function requires a field")
+		}
+		return bson.M{accumulator: "$" + qb.resolveField(argument)}, nil
+	}
 	return nil, errors.New("unsupported aggregation function")
 }
+
+// IsAggregate reports whether a selected field is a supported aggregate function like
+// "SUM(amount) AS total".
+func IsAggregate(field string) bool {
+	_, err := (&QueryBuilder{}).parseAggregation(field)
+	return err == nil
+}
+
+// functionCall splits a call like "sum(amount)" into its upper-cased name and its argument,
+// so function names match in any case.
+func functionCall(input string) (string, string, bool) {
+	input = strings.TrimSpace(input)
+	open := strings.Index(input, "(")
+	if open <= 0 || !strings.HasSuffix(input, ")") {
+		return "", "", false
+	}
+	name := strings.TrimSpace(input[:open])
+	if text, end, quoted, ok := scanIdentifier(name, 0); !ok || quoted || end != len(name) || text == "" {
+		return "", "", false
+	}
+	return strings.ToUpper(name), strings.TrimSpace(input[open+1 : len(input)-1]), true
+}
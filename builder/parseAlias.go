@@ -4,11 +4,43 @@ import "strings"
 
 // parseAlias extracts the alias from a field like "SUM(amount) AS totalAmount".
 func (qb *QueryBuilder) parseAlias(field string) string {
-	// Check if there's an alias (contains "AS")
-	if strings.Contains(strings.ToUpper(field), " AS ") {
-		parts := strings.SplitN(field, " AS ", 2)
-		return strings.TrimSpace(parts[1])
+	if _, alias, ok := splitAlias(field); ok {
+		return alias
 	}
 	// Default to the field itself if no alias is provided
 	return field
 }
+
+// ResultKey returns the key a selected field appears under in the result documents: its
+// alias, the text of an unaliased aggregate like "COUNT(*)", or the field resolved like in
+// conditions ("u.name" becomes "name").
+func (qb *QueryBuilder) ResultKey(field string) string {
+	expression, alias, ok := splitAlias(strings.TrimSpace(field))
+	if ok {
+		return alias
+	}
+	if _, _, isCall := functionCall(expression); isCall {
+		return expression
+	}
+	return qb.resolveField(expression)
+}
+
+// splitAlias splits "SUM(amount) AS total" (AS in any case) into the expression and its alias.
+func splitAlias(field string) (string, string, bool) {
+	tokens, err := tokenize(field)
+	if err != nil {
+		return field, "", false
+	}
+	depth := 0
+	for _, tok := range tokens {
+		switch {
+		case tok.kind == tokenLParen:
+			depth++
+		case tok.kind == tokenRParen:
+			depth--
+		case depth == 0 && tok.is("AS"):
+			return strings.TrimSpace(field[:tok.pos]), strings.TrimSpace(field[tok.pos+len(tok.text):]), true
+		}
+	}
+	return field, "", false
+}
@@ -0,0 +1,253 @@
+package builder
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Cond is a typed condition, an alternative to condition strings producing the same filters:
+//
+//	cond := builder.W.Field("amount").Gt(1000).And(builder.W.Field("status").Eq("active"))
+//	qb.MatchCond(cond)
+//
+// Conditions combine with And, Or and Not, and mix with condition strings through W.SQL.
+type Cond struct {
+	build func(qb *QueryBuilder) bson.M
+}
+
+// W starts typed conditions, see Cond.
+var W conditionDSL
+
+// conditionDSL is the type of W.
+type conditionDSL struct{}
+
+// FieldCond builds comparisons of one field, see W.Field.
+type FieldCond struct {
+	name string
+}
+
+// Field starts a comparison of a field, which may be a dotted path ("address.city") or
+// qualified with the collection alias ("o.amount").
+func (conditionDSL) Field(name string) FieldCond {
+	return FieldCond{name: name}
+}
+
+// SQL wraps a condition string like "status = 'active' OR amount > ?" as a Cond, with values
+// bound to placeholders as in QueryBuilder.Match.
+func (conditionDSL) SQL(condition string, args ...interface{}) Cond {
+	return Cond{build: func(qb *QueryBuilder) bson.M { return qb.bindParams(condition, args, qb.parseConditions) }}
+}
+
+// And matches documents matching every one of conds.
+func (conditionDSL) And(conds ...Cond) Cond {
+	return logicalCond("$and", conds)
+}
+
+// Or matches documents matching at least one of conds.
+func (conditionDSL) Or(conds ...Cond) Cond {
+	return logicalCond("$or", conds)
+}
+
+// Not negates cond like NOT in a condition string.
+func (conditionDSL) Not(cond Cond) Cond {
+	return cond.Not()
+}
+
+// And matches documents matching c and every one of others.
+func (c Cond) And(others ...Cond) Cond {
+	return logicalCond("$and", append([]Cond{c}, others...))
+}
+
+// Or matches documents matching c or one of others.
+func (c Cond) Or(others ...Cond) Cond {
+	return logicalCond("$or", append([]Cond{c}, others...))
+}
+
+// Not negates c like NOT in a condition string.
+func (c Cond) Not() Cond {
+	return Cond{build: func(qb *QueryBuilder) bson.M { return negateFilter(c.filter(qb)) }}
+}
+
+// Filter returns the MongoDB filter of the condition.
+func (c Cond) Filter() bson.M {
+	return c.filter(&QueryBuilder{})
+}
+
+// filter builds the filter of the condition for qb, resolving aliases and declared field types.
+func (c Cond) filter(qb *QueryBuilder) bson.M {
+	if c.build == nil {
+		return bson.M{}
+	}
+	return c.build(qb)
+}
+
+// logicalCond combines conditions with $and or $or; a single condition is returned as is.
+func logicalCond(operator string, conds []Cond) Cond {
+	return Cond{build: func(qb *QueryBuilder) bson.M {
+		if len(conds) == 1 {
+			return conds[0].filter(qb)
+		}
+		filters := make([]bson.M, len(conds))
+		for i, cond := range conds {
+			filters[i] = cond.filter(qb)
+		}
+		return bson.M{operator: filters}
+	}}
+}
+
+// Eq matches documents where the field equals value.
+func (f FieldCond) Eq(value interface{}) Cond { return f.compare("$eq", value) }
+
+// Ne matches documents where the field does not equal value.
+func (f FieldCond) Ne(value interface{}) Cond { return f.compare("$ne", value) }
+
+// Gt matches documents where the field is greater than value.
+func (f FieldCond) Gt(value interface{}) Cond { return f.compare("$gt", value) }
+
+// Gte matches documents where the field is greater than or equal to value.
+func (f FieldCond) Gte(value interface{}) Cond { return f.compare("$gte", value) }
+
+// Lt matches documents where the field is less than value.
+func (f FieldCond) Lt(value interface{}) Cond { return f.compare("$lt", value) }
+
+// Lte matches documents where the field is less than or equal to value.
+func (f FieldCond) Lte(value interface{}) Cond { return f.compare("$lte", value) }
+
+// In matches documents where the field equals one of values.
+func (f FieldCond) In(values ...interface{}) Cond { return f.compareList("$in", values) }
+
+// NotIn matches documents where the field equals none of values.
+func (f FieldCond) NotIn(values ...interface{}) Cond { return f.compareList("$nin", values) }
+
+// Between matches documents where the field lies between low and high, inclusive.
+func (f FieldCond) Between(low, high interface{}) Cond {
+	return Cond{build: func(qb *QueryBuilder) bson.M {
+		field := qb.resolveField(f.name)
+		return bson.M{field: bson.M{"$gte": qb.typedValue(field, low), "$lte": qb.typedValue(field, high)}}
+	}}
+}
+
+// Like matches documents where the field matches an SQL LIKE pattern ("jo%").
+func (f FieldCond) Like(pattern string) Cond { return f.like(pattern, false) }
+
+// ILike matches documents where the field matches an SQL LIKE pattern, ignoring case.
+func (f FieldCond) ILike(pattern string) Cond { return f.like(pattern, true) }
+
+// IsNull matches documents where the field is null, following NullSemantics.
+func (f FieldCond) IsNull() Cond { return f.null(false) }
+
+// IsNotNull matches documents where the field is not null, following NullSemantics.
+func (f FieldCond) IsNotNull() Cond { return f.null(true) }
+
+// Exists matches documents having the field, even when it is null.
+func (f FieldCond) Exists() Cond { return f.exists(false) }
+
+// NotExists matches documents missing the field.
+func (f FieldCond) NotExists() Cond { return f.exists(true) }
+
+// Type matches documents where the field has one of the BSON types, given as aliases like
+// "string" or type numbers.
+func (f FieldCond) Type(types ...interface{}) Cond {
+	return Cond{build: func(qb *QueryBuilder) bson.M {
+		if len(types) == 1 {
+			return bson.M{qb.resolveField(f.name): bson.M{"$type": types[0]}}
+		}
+		return bson.M{qb.resolveField(f.name): bson.M{"$type": types}}
+	}}
+}
+
+// compare builds {field: {operator: value}}.
+func (f FieldCond) compare(operator string, value interface{}) Cond {
+	return Cond{build: func(qb *QueryBuilder) bson.M {
+		field := qb.resolveField(f.name)
+		if text, ok := value.(string); ok && (operator == "$eq" || operator == "$ne") {
+			if ids := qb.idValues(field, text); ids != nil && operator == "$eq" {
+				return bson.M{field: bson.M{"$in": ids}}
+			} else if ids != nil {
+				return bson.M{field: bson.M{"$nin": ids}}
+			}
+		}
+		return bson.M{field: bson.M{operator: qb.typedValue(field, value)}}
+	}}
+}
+
+// compareList builds {field: {operator: [values]}}.
+func (f FieldCond) compareList(operator string, values []interface{}) Cond {
+	return Cond{build: func(qb *QueryBuilder) bson.M {
+		field := qb.resolveField(f.name)
+		list := make([]interface{}, 0, len(values))
+		for _, value := range values {
+			if text, ok := value.(string); ok {
+				if ids := qb.idValues(field, text); ids != nil {
+					list = append(list, ids...)
+					continue
+				}
+			}
+			list = append(list, qb.typedValue(field, value))
+		}
+		return bson.M{field: bson.M{operator: list}}
+	}}
+}
+
+// like builds the $regex filter of a LIKE pattern.
+func (f FieldCond) like(pattern string, insensitive bool) Cond {
+	return Cond{build: func(qb *QueryBuilder) bson.M {
+		regex := bson.M{"$regex": likeToRegex(pattern, 0)}
+		if insensitive {
+			regex["$options"] = "i"
+		}
+		return bson.M{qb.resolveField(f.name): regex}
+	}}
+}
+
+// exists builds the filter of [NOT] EXISTS.
+func (f FieldCond) exists(negated bool) Cond {
+	return Cond{build: func(qb *QueryBuilder) bson.M {
+		return existsFilter(qb.resolveField(f.name), negated)
+	}}
+}
+
+// null builds the filter of IS [NOT] NULL.
+func (f FieldCond) null(negated bool) Cond {
+	return Cond{build: func(qb *QueryBuilder) bson.M {
+		return qb.nullFilter(qb.resolveField(f.name), negated)
+	}}
+}
+
+// typedValue converts a Go value like a condition string value would be: ints become int64, and
+// strings are converted to the declared type of the field if any, or from ObjectID hex.
+func (qb *QueryBuilder) typedValue(field string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case string:
+		if fieldType, ok := qb.fieldTypes[field]; ok {
+			return qb.convertToType(v, fieldType)
+		}
+		return qb.hexObjectID(v)
+	}
+	return value
+}
+
+// MatchCond adds a $match stage filtering on a typed condition.
+func (qb *QueryBuilder) MatchCond(cond Cond) *QueryBuilder {
+	qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$match", Value: cond.filter(qb)}})
+	return qb
+}
+
+// WhereCond specifies the filter of the update as a typed condition.
+func (ub *UpdateBuilder) WhereCond(cond Cond) *UpdateBuilder {
+	qb := &QueryBuilder{stringIDs: ub.stringIDs, exactIDs: ub.exactIDs, location: ub.location}
+	ub.Filter = cond.filter(qb)
+	ub.failWith(qb.buildErrors)
+	return ub
+}
+
+// WhereCond specifies the filter of the delete operation as a typed condition.
+func (db *DeleteBuilder) WhereCond(cond Cond) *DeleteBuilder {
+	qb := &QueryBuilder{stringIDs: db.stringIDs, exactIDs: db.exactIDs, location: db.location}
+	db.Filter = cond.filter(qb)
+	db.failWith(qb.buildErrors)
+	return db
+}
@@ -0,0 +1,26 @@
+package builder
+
+import (
+	"context"
+	"runtime/pprof"
+	"sync/atomic"
+)
+
+// profilerLabels enables pprof labels on query executions.
+var profilerLabels atomic.Bool
+
+// SetProfilerLabels makes Execute and ExecuteToJSON run with the pprof labels "collection"
+// and "fingerprint" (see Fingerprint), so CPU profiles attribute driver and decode time to
+// query shapes. Disabled by default, since computing the fingerprint walks the pipeline.
+func SetProfilerLabels(enabled bool) {
+	profilerLabels.Store(enabled)
+}
+
+// profiled runs fn with ctx, labeled for pprof when profiler labels are enabled.
+func (qb *QueryBuilder) profiled(ctx context.Context, fn func(ctx context.Context)) {
+	if !profilerLabels.Load() {
+		fn(ctx)
+		return
+	}
+	pprof.Do(ctx, pprof.Labels("collection", qb.sourceCollection(), "fingerprint", qb.Fingerprint()), fn)
+}
@@ -0,0 +1,196 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Project adds a $project stage keeping the fields struct type T decodes, read from its bson
+// tags like the driver does, so the projection and the decoding cannot drift apart:
+//
+//	type order struct {
+//		ID     primitive.ObjectID `bson:"_id"`
+//		Amount int64              `bson:"amount"`
+//	}
+//	orders, err := builder.ExecuteAs[order](builder.Project[order](qb), mdb.Database)
+//
+// Types with an inline map, which take any field, add no stage.
+func Project[T any](qb *QueryBuilder) *QueryBuilder {
+	if projection := ProjectionOf[T](); projection != nil {
+		qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$project", Value: projection}})
+	}
+	return qb
+}
+
+// ProjectionOf returns the projection of the fields struct type T decodes, or nil when T is not
+// a struct or has an inline map. _id is excluded unless T has a field for it.
+func ProjectionOf[T any]() bson.M {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	projection := bson.M{"_id": 0}
+	if !addStructFields(projection, t) {
+		return nil
+	}
+	return projection
+}
+
+// addStructFields adds the BSON names of the fields of struct type t to projection. It reports
+// false when t has an inline map.
+func addStructFields(projection bson.M, t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue // Skip unexported fields
+		}
+		tags, err := bsoncodec.DefaultStructTagParser.ParseStructTags(field)
+		if err != nil || tags.Skip {
+			continue
+		}
+		if tags.Inline {
+			inline := field.Type
+			if inline.Kind() == reflect.Pointer {
+				inline = inline.Elem()
+			}
+			if inline.Kind() != reflect.Struct {
+				return false // Inline maps take every remaining field
+			}
+			if !addStructFields(projection, inline) {
+				return false
+			}
+			continue
+		}
+		projection[tags.Name] = 1
+	}
+	return true
+}
+
+// ExecuteAs executes the query with ctx and decodes each result into a T, typically with the
+// projection of Project[T]. The results are decoded with the registry of DecodeRegistry, if any.
+// A nil ctx runs with the context of WithContext, like ExecuteInto.
+func ExecuteAs[T any](ctx context.Context, db *mongo.Database, qb *QueryBuilder) (_ []T, err error) {
+	defer recoverTo(&err)
+	if ctx == nil {
+		ctx = qb.ctx
+	}
+	ctx, release, err := trackContext(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	observe := qb.observeExecution(ctx)
+	var results []T
+	qb.profiled(ctx, func(ctx context.Context) {
+		results, err = executeAs[T](ctx, qb, db)
+	})
+	observe(len(results), err)
+	return results, err
+}
+
+// First executes the query with ctx limited to one result and decodes it into a T, like
+// ExecuteAs. Without results it returns mongo.ErrNoDocuments, like the driver's FindOne.
+func First[T any](ctx context.Context, db *mongo.Database, qb *QueryBuilder) (T, error) {
+	var first T
+	results, err := ExecuteAs[T](ctx, db, qb.Clone().Limit(1))
+	if err != nil {
+		return first, err
+	}
+	if len(results) == 0 {
+		return first, mongo.ErrNoDocuments
+	}
+	return results[0], nil
+}
+
+// executeAs runs the aggregation and decodes its results into []T.
+func executeAs[T any](ctx context.Context, qb *QueryBuilder, db *mongo.Database) ([]T, error) {
+	ctx, cancel := qb.timeout(ctx)
+	defer cancel()
+
+	cursor, err := qb.aggregate(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tuner := qb.batchTuner()
+	results := []T{}
+	for cursor.Next(ctx) {
+		tuner.observe(cursor)
+		var result T
+		if err := cursor.Decode(&result); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ExecuteInto executes the query with ctx and decodes the results into results, a pointer to a
+// slice of any type the driver decodes, like *[]Order with bson struct tags. The slice is
+// replaced, keeping its backing array. A nil ctx runs with the context of WithContext.
+func (qb *QueryBuilder) ExecuteInto(ctx context.Context, db *mongo.Database, results interface{}) (err error) {
+	defer recoverTo(&err)
+	slice := reflect.ValueOf(results)
+	if slice.Kind() != reflect.Pointer || slice.IsNil() || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("results must be a non-nil pointer to a slice, got %T", results)
+	}
+	if err := qb.Err(); err != nil {
+		return err
+	}
+	if ctx == nil {
+		ctx = qb.ctx
+	}
+	ctx, release, err := trackContext(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	observe := qb.observeExecution(ctx)
+	qb.profiled(ctx, func(ctx context.Context) {
+		err = qb.executeInto(ctx, db, slice.Elem())
+	})
+	observe(slice.Elem().Len(), err)
+	return err
+}
+
+// executeInto runs the aggregation and decodes its results into the elements of slice.
+func (qb *QueryBuilder) executeInto(ctx context.Context, db *mongo.Database, slice reflect.Value) error {
+	ctx, cancel := qb.timeout(ctx)
+	defer cancel()
+
+	cursor, err := qb.aggregate(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	tuner := qb.batchTuner()
+	decoded := slice.Slice(0, 0)
+	for cursor.Next(ctx) {
+		tuner.observe(cursor)
+		result := reflect.New(slice.Type().Elem())
+		if err := cursor.Decode(result.Interface()); err != nil {
+			return fmt.Errorf("failed to decode result %d: %v", decoded.Len(), err)
+		}
+		decoded = reflect.Append(decoded, result.Elem())
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	slice.Set(decoded)
+	return nil
+}
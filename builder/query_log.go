@@ -0,0 +1,73 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// QueryLogEntry is one line of the structured query log written by QueryLogger and replayed by
+// cmd/mongoquery-replay.
+type QueryLogEntry struct {
+	Time        time.Time         `json:"time"`
+	Collection  string            `json:"collection"`
+	Fingerprint string            `json:"fingerprint"`
+	Pipeline    []json.RawMessage `json:"pipeline"` // Stages in canonical Extended JSON, so values keep their types
+	DurationMS  float64           `json:"duration_ms"`
+	Documents   int               `json:"documents"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// Stages decodes the pipeline of the entry.
+func (e QueryLogEntry) Stages() ([]bson.D, error) {
+	stages := make([]bson.D, len(e.Pipeline))
+	for i, stage := range e.Pipeline {
+		if err := bson.UnmarshalExtJSON(stage, true, &stages[i]); err != nil {
+			return nil, fmt.Errorf("invalid stage %d: %v", i, err)
+		}
+	}
+	return stages, nil
+}
+
+// QueryLogger returns an observer writing the stats of every execution to w as a JSON line
+// QueryLogEntry:
+//
+//	builder.SetQueryObserver(builder.QueryLogger(file))
+//
+// Lines are written whole, so concurrent executions do not interleave. Executions whose pipeline
+// fails to encode are logged without it.
+func QueryLogger(w io.Writer) QueryObserver {
+	var mu sync.Mutex
+	return func(stats QueryStats) {
+		entry := QueryLogEntry{
+			Time:        stats.Start.UTC(),
+			Collection:  stats.Collection,
+			Fingerprint: stats.Fingerprint,
+			Pipeline:    []json.RawMessage{},
+			DurationMS:  float64(stats.Duration.Microseconds()) / 1000,
+			Documents:   stats.Documents,
+		}
+		for _, stage := range stats.Pipeline {
+			encoded, err := bson.MarshalExtJSON(stage, true, false)
+			if err != nil {
+				entry.Pipeline = nil
+				break
+			}
+			entry.Pipeline = append(entry.Pipeline, encoded)
+		}
+		if stats.Err != nil {
+			entry.Error = stats.Err.Error()
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(append(line, '\n'))
+	}
+}
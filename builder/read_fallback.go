@@ -0,0 +1,42 @@
+package builder
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// StaleField is the field Execute adds to the rows of a fallback read, holding the read
+// preference they were read with, since they may lag behind the primary.
+const StaleField = "_stale"
+
+// ReadFallback makes Execute retry a read that timed out, e.g. because the primary of a
+// region is unreachable, with the fallback read preference, such as
+// readpref.SecondaryPreferred(readpref.WithMaxStaleness(90*time.Second)). Rows of the retry
+// are annotated under StaleField with the read preference mode and its max staleness in
+// seconds. Queries writing with $out never fall back.
+func (qb *QueryBuilder) ReadFallback(fallback *readpref.ReadPref) *QueryBuilder {
+	qb.readFallback = fallback
+	return qb
+}
+
+// shouldFallBack reports whether a failed read is retried with the read fallback: it timed
+// out while the caller's context is still live.
+func (qb *QueryBuilder) shouldFallBack(ctx context.Context, err error) bool {
+	return qb.readFallback != nil && qb.OutCollection == "" && mongo.IsTimeout(err) && ctx.Err() == nil
+}
+
+// annotateStale marks the rows of a fallback read with the read preference they were read with.
+func (qb *QueryBuilder) annotateStale(rows []map[string]interface{}) {
+	mode := qb.readFallback.Mode().String()
+	maxStaleness, hasMaxStaleness := qb.readFallback.MaxStaleness()
+	for _, row := range rows {
+		annotation := bson.M{"readPreference": mode} // One per row, so changing one leaves the others
+		if hasMaxStaleness {
+			annotation["maxStalenessSeconds"] = int64(maxStaleness.Seconds())
+		}
+		row[StaleField] = annotation
+	}
+}
@@ -0,0 +1,46 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrNoDatabase is returned by executions given a nil database.
+var ErrNoDatabase = errors.New("database is nil")
+
+// keepPanics disables the recovery of panics, see SetPanicRecovery.
+var keepPanics atomic.Bool
+
+// SetPanicRecovery sets whether the builders turn a panic while building or executing, such as
+// an unforeseen malformed input, into an error: of the builder (see Err) while building, or of
+// Execute. Enabled by default; disable it in tests to get the panic and its stack instead.
+func SetPanicRecovery(enabled bool) {
+	keepPanics.Store(!enabled)
+}
+
+// PanicRecovery reports whether panics are turned into errors, see SetPanicRecovery.
+func PanicRecovery() bool {
+	return !keepPanics.Load()
+}
+
+// recoverTo turns a panic into *err, when panic recovery is enabled. It must be deferred.
+func recoverTo(err *error) {
+	if keepPanics.Load() {
+		return
+	}
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("internal error: %v", r)
+	}
+}
+
+// recoverAs turns a panic into an error of the builder, formatted like fail with the panic
+// appended, when panic recovery is enabled. It must be deferred.
+func (b *buildErrors) recoverAs(format string, args ...interface{}) {
+	if keepPanics.Load() {
+		return
+	}
+	if r := recover(); r != nil {
+		b.fail(format+": internal error: %v", append(args, r)...)
+	}
+}
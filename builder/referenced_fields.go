@@ -0,0 +1,128 @@
+package builder
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// reshapingStages replace the documents of the collection with new ones, so later stages no
+// longer refer to the collection's fields.
+var reshapingStages = map[string]bool{
+	"$group": true, "$project": true, "$replaceRoot": true, "$replaceWith": true, "$bucket": true,
+	"$bucketAuto": true, "$facet": true, "$count": true, "$sortByCount": true, "$unionWith": true,
+}
+
+// ReferencedFields returns the collection the query reads and the fields of its documents the
+// query refers to, sorted: in filters, joins, sorts, groupings and projections, up to the first
+// stage replacing the documents. Fields added by the query, like the target of a join, are left
+// out. Schema checks use it to tell whether the query still finds its fields.
+func (qb *QueryBuilder) ReferencedFields() (string, []string) {
+	fields, derived := map[string]bool{}, map[string]bool{}
+	add := func(path string) {
+		root, _, _ := strings.Cut(path, ".")
+		if path != "" && !derived[root] {
+			fields[path] = true
+		}
+	}
+
+	for _, stage := range qb.scopedPipeline() {
+		for _, e := range stage {
+			switch e.Key {
+			case "$match":
+				filterFields(e.Value, add)
+			case "$sort":
+				forEachField(e.Value, func(key string, _ interface{}) { add(key) })
+			case "$lookup":
+				forEachField(e.Value, func(key string, value interface{}) {
+					switch key {
+					case "localField":
+						if field, ok := value.(string); ok {
+							add(field)
+						}
+					case "let":
+						fieldReferences(value, add)
+					}
+				})
+				forEachField(e.Value, func(key string, value interface{}) {
+					if as, ok := value.(string); ok && key == "as" {
+						derived[as] = true
+					}
+				})
+			case "$set", "$addFields":
+				fieldReferences(e.Value, add)
+				forEachField(e.Value, func(key string, _ interface{}) { derived[key] = true })
+			case "$setWindowFields":
+				forEachField(e.Value, func(key string, value interface{}) {
+					if key == "sortBy" {
+						forEachField(value, func(field string, _ interface{}) { add(field) })
+						return
+					}
+					fieldReferences(value, add)
+				})
+				forEachField(e.Value, func(key string, value interface{}) {
+					if key == "output" {
+						forEachField(value, func(field string, _ interface{}) { derived[field] = true })
+					}
+				})
+			case "$project":
+				forEachField(e.Value, func(key string, value interface{}) {
+					switch value.(type) {
+					case int, int32, int64, float64, bool:
+						add(key) // Inclusion or exclusion of the field
+					default:
+						fieldReferences(value, add)
+					}
+				})
+			case "$unionWith":
+			default:
+				fieldReferences(e.Value, add)
+			}
+			if reshapingStages[e.Key] {
+				return qb.sourceCollection(), slices.Sorted(maps.Keys(fields))
+			}
+		}
+	}
+	return qb.sourceCollection(), slices.Sorted(maps.Keys(fields))
+}
+
+// filterFields calls add with the fields a query filter like {"status": "paid"} refers to.
+func filterFields(filter interface{}, add func(string)) {
+	forEachField(filter, func(key string, value interface{}) {
+		switch key {
+		case "$and", "$or", "$nor":
+			items, _ := listItems(value)
+			for _, item := range items {
+				filterFields(item, add)
+			}
+		case "$expr":
+			fieldReferences(value, add)
+		default:
+			if !strings.HasPrefix(key, "$") {
+				add(key)
+			}
+		}
+	})
+}
+
+// fieldReferences calls add with the fields an expression refers to as "$field", leaving out
+// variables ("$$ROOT"), literals and the pipelines of joins, which read other collections.
+func fieldReferences(value interface{}, add func(string)) {
+	if text, ok := value.(string); ok {
+		if strings.HasPrefix(text, "$") && !strings.HasPrefix(text, "$$") && !strings.ContainsAny(text, " ()") {
+			add(text[1:])
+		}
+		return
+	}
+	if items, ok := listItems(value); ok {
+		for _, item := range items {
+			fieldReferences(item, add)
+		}
+		return
+	}
+	forEachField(value, func(key string, value interface{}) {
+		if key != "$literal" && key != "pipeline" {
+			fieldReferences(value, add)
+		}
+	})
+}
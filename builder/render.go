@@ -0,0 +1,226 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RenderFormat selects the diagram language produced by Render.
+type RenderFormat int
+
+const (
+	RenderMermaid  RenderFormat = iota // Mermaid flowchart, rendered by GitHub and most doc tools
+	RenderGraphviz                     // Graphviz DOT digraph
+)
+
+// renderLabelLength caps the stage body shown in a diagram node.
+const renderLabelLength = 60
+
+// Render describes the pipeline stages as a diagram, one node per stage, for reviewing
+// generated pipelines in docs and pull requests.
+func (qb *QueryBuilder) Render(format RenderFormat) string {
+	return qb.render(format, qb.subqueryPipeline(), nil)
+}
+
+// RenderExplained renders the pipeline like Render and labels each edge with the number of
+// documents the stage returned, taken from explain. The diagram shows the pipeline Execute
+// sends, scopes and annotations included. Explain runs the pipeline with executionStats
+// verbosity, so it costs as much as executing the query.
+func (qb *QueryBuilder) RenderExplained(db *mongo.Database, format RenderFormat) (_ string, err error) {
+	defer recoverTo(&err)
+	explain, pipeline, err := qb.explain(db, "executionStats")
+	if err != nil {
+		return "", err
+	}
+	return qb.render(format, pipeline, explainedCardinalities(explain, len(pipeline))), nil
+}
+
+// Explain returns the server's explain output for the pipeline and aggregate options Execute
+// sends, with the given verbosity: "queryPlanner" only plans it, "executionStats" and "allPlansExecution" also run it.
+func (qb *QueryBuilder) Explain(db *mongo.Database, verbosity string) (_ bson.M, err error) {
+	defer recoverTo(&err)
+	explain, _, err := qb.explain(db, verbosity)
+	return explain, err
+}
+
+// explain runs the explain command of Explain and returns its output with the pipeline explained.
+func (qb *QueryBuilder) explain(db *mongo.Database, verbosity string) (bson.M, []bson.D, error) {
+	if err := qb.checkPipeline(); err != nil {
+		return nil, nil, err
+	}
+	ctx, release, err := trackContext(qb.ctx, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	ctx, cancel := qb.timeout(ctx)
+	defer cancel()
+
+	pipeline, err := qb.executionPipeline(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var explain bson.M
+	command := bson.D{
+		{Key: "explain", Value: explainedAggregate(qb.sourceCollection(), pipeline, qb.aggregateOptions(ctx))},
+		{Key: "verbosity", Value: verbosity},
+	}
+	if err := db.RunCommand(ctx, command).Decode(&explain); err != nil {
+		return nil, nil, fmt.Errorf("failed to explain pipeline: %v", err)
+	}
+	return explain, pipeline, nil
+}
+
+// explainedAggregate returns the aggregate command explained for pipeline, with the options
+// that change its plan or limits: collation, hint, allowDiskUse, maxTimeMS and comment.
+func explainedAggregate(collection string, pipeline []bson.D, opts *options.AggregateOptions) bson.D {
+	command := bson.D{
+		{Key: "aggregate", Value: collection},
+		{Key: "pipeline", Value: pipeline},
+		{Key: "cursor", Value: bson.M{}},
+	}
+	if opts.Collation != nil {
+		command = append(command, bson.E{Key: "collation", Value: opts.Collation.ToDocument()})
+	}
+	if opts.Hint != nil {
+		command = append(command, bson.E{Key: "hint", Value: opts.Hint})
+	}
+	if opts.AllowDiskUse != nil {
+		command = append(command, bson.E{Key: "allowDiskUse", Value: *opts.AllowDiskUse})
+	}
+	if opts.MaxTime != nil {
+		command = append(command, bson.E{Key: "maxTimeMS", Value: opts.MaxTime.Milliseconds()})
+	}
+	if opts.Comment != nil {
+		command = append(command, bson.E{Key: "comment", Value: *opts.Comment})
+	}
+	return command
+}
+
+// render writes the diagram of stages; cardinalities[i] is the output count of stage i, or -1 if
+// unknown.
+func (qb *QueryBuilder) render(format RenderFormat, stages []bson.D, cardinalities []int64) string {
+	labels := []string{qb.sourceCollection()}
+	for _, stage := range stages {
+		labels = append(labels, stageLabel(stage))
+	}
+
+	var out strings.Builder
+	switch format {
+	case RenderGraphviz:
+		out.WriteString("digraph pipeline {\n  node [shape=box];\n")
+		for i, label := range labels {
+			fmt.Fprintf(&out, "  s%d [label=\"%s\"];\n", i, strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(label))
+		}
+		for i := 1; i < len(labels); i++ {
+			if count := cardinalityBefore(cardinalities, i); count >= 0 {
+				fmt.Fprintf(&out, "  s%d -> s%d [label=\"%d docs\"];\n", i-1, i, count)
+			} else {
+				fmt.Fprintf(&out, "  s%d -> s%d;\n", i-1, i)
+			}
+		}
+		out.WriteString("}\n")
+	default:
+		out.WriteString("flowchart TD\n")
+		for i, label := range labels {
+			fmt.Fprintf(&out, "  s%d[\"%s\"]\n", i, strings.ReplaceAll(label, `"`, "#quot;"))
+		}
+		for i := 1; i < len(labels); i++ {
+			if count := cardinalityBefore(cardinalities, i); count >= 0 {
+				fmt.Fprintf(&out, "  s%d -->|%d docs| s%d\n", i-1, count, i)
+			} else {
+				fmt.Fprintf(&out, "  s%d --> s%d\n", i-1, i)
+			}
+		}
+	}
+	return out.String()
+}
+
+// cardinalityBefore returns the number of documents flowing into node i (node 0 is the collection).
+func cardinalityBefore(cardinalities []int64, node int) int64 {
+	if node < 2 || node-2 >= len(cardinalities) {
+		return -1
+	}
+	return cardinalities[node-2]
+}
+
+// stageLabel returns the stage operator followed by its body, shortened for display.
+func stageLabel(stage bson.D) string {
+	if len(stage) == 0 {
+		return ""
+	}
+	body, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: stage[0].Value}}, false, false)
+	if err != nil {
+		return stage[0].Key
+	}
+	text := strings.TrimSuffix(strings.TrimPrefix(string(body), `{"v":`), "}")
+	if len(text) > renderLabelLength {
+		text = text[:renderLabelLength] + "..."
+	}
+	return stage[0].Key + " " + text
+}
+
+// explainedCardinalities extracts the per-stage nReturned counts of an aggregate explain,
+// aligned with the stages of the pipeline. The leading stages the server merged into the
+// initial query ($cursor) share one count, reported on the last of them.
+func explainedCardinalities(explain bson.M, stageCount int) []int64 {
+	cardinalities := make([]int64, stageCount)
+	for i := range cardinalities {
+		cardinalities[i] = -1
+	}
+	if stageCount == 0 {
+		return cardinalities
+	}
+
+	stages, ok := explain["stages"].(bson.A)
+	if !ok {
+		// The whole pipeline ran in the query layer
+		if stats, ok := explain["executionStats"].(bson.M); ok {
+			cardinalities[stageCount-1] = explainCount(stats["nReturned"])
+		}
+		return cardinalities
+	}
+
+	cursorCount, tail := int64(-1), []int64{}
+	for _, stage := range stages {
+		doc, ok := stage.(bson.M)
+		if !ok {
+			continue
+		}
+		if cursor, ok := doc["$cursor"].(bson.M); ok {
+			if stats, ok := cursor["executionStats"].(bson.M); ok {
+				cursorCount = explainCount(stats["nReturned"])
+			}
+			continue
+		}
+		tail = append(tail, explainCount(doc["nReturned"]))
+	}
+	if len(tail) > stageCount {
+		return cardinalities // Stages were rewritten beyond recognition
+	}
+
+	offset := stageCount - len(tail)
+	copy(cardinalities[offset:], tail)
+	if offset > 0 {
+		cardinalities[offset-1] = cursorCount
+	}
+	return cardinalities
+}
+
+// explainCount converts an explain counter to int64, or -1 when it is missing.
+func explainCount(value interface{}) int64 {
+	switch v := value.(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return -1
+}
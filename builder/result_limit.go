@@ -0,0 +1,82 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrResultTooLarge is returned by Execute when the results exceed MaxResultBytes and no
+// overflow handler is set.
+var ErrResultTooLarge = errors.New("query result exceeds the maximum size")
+
+// OverflowHandler receives the rows of a result that exceeded MaxResultBytes, in chunks of at
+// most the limit, e.g. to stream them to a writer or spill them to disk.
+type OverflowHandler func(rows []map[string]interface{}) error
+
+// MaxResultBytes limits the size, measured as BSON, of the results Execute accumulates in
+// memory. Without an overflow handler a larger result fails with ErrResultTooLarge. With one,
+// the handler receives the accumulated rows each time they reach the limit and once more at the
+// end, and Execute returns no rows.
+func (qb *QueryBuilder) MaxResultBytes(limit int64, overflow ...OverflowHandler) *QueryBuilder {
+	qb.maxResultBytes = limit
+	qb.overflow = nil
+	if len(overflow) > 0 {
+		qb.overflow = overflow[0]
+	}
+	return qb
+}
+
+// resultAccumulator collects the rows of one execution within the MaxResultBytes limit.
+type resultAccumulator struct {
+	limit    int64
+	overflow OverflowHandler
+	size     int64
+	rows     []map[string]interface{}
+	spilled  bool // Rows were handed to the overflow handler
+}
+
+// accumulator returns the accumulator for one execution of the query.
+func (qb *QueryBuilder) accumulator() *resultAccumulator {
+	return &resultAccumulator{limit: qb.maxResultBytes, overflow: qb.overflow}
+}
+
+// add appends a row of size bytes, handing the rows to the overflow handler when the limit is reached.
+func (a *resultAccumulator) add(row map[string]interface{}, size int) error {
+	if a.limit > 0 && a.size+int64(size) > a.limit {
+		if a.overflow == nil {
+			return fmt.Errorf("%w (%d bytes)", ErrResultTooLarge, a.limit)
+		}
+		if len(a.rows) > 0 {
+			if err := a.flush(); err != nil {
+				return err
+			}
+		}
+		a.spilled = true
+	}
+	a.rows = append(a.rows, row)
+	a.size += int64(size)
+	return nil
+}
+
+// flush hands the accumulated rows to the overflow handler.
+func (a *resultAccumulator) flush() error {
+	a.spilled = true
+	rows := a.rows
+	a.rows, a.size = nil, 0
+	if err := a.overflow(rows); err != nil {
+		return fmt.Errorf("overflow handler failed: %v", err)
+	}
+	return nil
+}
+
+// results returns the accumulated rows, after handing the last ones to the overflow handler
+// when the result was spilled.
+func (a *resultAccumulator) results() ([]map[string]interface{}, error) {
+	if !a.spilled {
+		return a.rows, nil
+	}
+	if len(a.rows) == 0 {
+		return nil, nil
+	}
+	return nil, a.flush()
+}
@@ -0,0 +1,104 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Defaults of RetryPolicy.
+const (
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = 100 * time.Millisecond
+)
+
+// RetryPolicy configures Retry and RunTransaction.
+type RetryPolicy struct {
+	Attempts int           // Attempts in all, defaultRetryAttempts when 0
+	Backoff  time.Duration // Wait before the first retry, doubled before each next one, defaultRetryBackoff when 0
+}
+
+// Retry runs fn until it succeeds, fails with an error retrying cannot fix, ctx ends or the
+// attempts of policy are used up, and returns its last error. Errors of failovers, such as
+// InterruptedDueToReplStateChange, network errors and errors labeled RetryableWriteError or
+// TransientTransactionError are retried, so a single write survives a primary stepping down:
+//
+//	err := builder.Retry(ctx, builder.RetryPolicy{}, func(ctx context.Context) error {
+//		_, err := ub.WithContext(ctx).Execute(db)
+//		return err
+//	})
+func Retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	return policy.run(ctx, isRetryable, func() error { return fn(ctx) })
+}
+
+// RunTransaction runs fn in a transaction of a new session of client and commits it. The whole
+// transaction runs again when it fails with the TransientTransactionError label, and the commit
+// alone when it fails with UnknownTransactionCommitResult, within the attempts of policy.
+func RunTransaction(ctx context.Context, client *mongo.Client, policy RetryPolicy, fn func(ctx mongo.SessionContext) error) error {
+	session, err := client.StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %v", err)
+	}
+	defer session.EndSession(ctx)
+
+	transient := func(err error) bool { return hasErrorLabel(err, "TransientTransactionError") }
+	return policy.run(ctx, transient, func() error {
+		return mongo.WithSession(ctx, session, func(sessionCtx mongo.SessionContext) error {
+			if err := session.StartTransaction(); err != nil {
+				return err
+			}
+			if err := fn(sessionCtx); err != nil {
+				session.AbortTransaction(context.Background())
+				return err
+			}
+			unknown := func(err error) bool { return hasErrorLabel(err, "UnknownTransactionCommitResult") }
+			return policy.run(sessionCtx, unknown, func() error { return session.CommitTransaction(sessionCtx) })
+		})
+	})
+}
+
+// run calls attempt until it succeeds or fails with an error retry rejects, waiting the backoff
+// between attempts.
+func (p RetryPolicy) run(ctx context.Context, retry func(error) bool, attempt func() error) error {
+	attempts, backoff := p.Attempts, p.Backoff
+	if attempts <= 0 {
+		attempts = defaultRetryAttempts
+	}
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	for i := 1; ; i++ {
+		err := attempt()
+		if err == nil || i >= attempts || !retry(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isRetryable reports whether a single operation may succeed when run again.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && slices.ContainsFunc(transientCodes, serverErr.HasErrorCode) {
+		return true
+	}
+	return isTransient(err)
+}
+
+// hasErrorLabel reports whether err carries the server or driver error label.
+func hasErrorLabel(err error, label string) bool {
+	var labeled mongo.LabeledError
+	return errors.As(err, &labeled) && labeled.HasErrorLabel(label)
+}
@@ -0,0 +1,94 @@
+package builder
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Scope holds the defaults applied to every query of a collection, see RegisterScope.
+type Scope struct {
+	Where   string   // Condition every document must match, e.g. "status != 'archived'"
+	OrderBy []string // Sort of queries without ORDER BY, e.g. "created_at DESC"
+	Select  []string // Fields of queries selecting none
+}
+
+// compiledScope is a registered Scope with its condition parsed.
+type compiledScope struct {
+	Scope
+	filter bson.M
+}
+
+var (
+	scopesMu sync.Mutex // Serializes registrations
+	scopes   atomic.Pointer[map[string]compiledScope]
+)
+
+func init() {
+	scopes.Store(&map[string]compiledScope{})
+}
+
+// RegisterScope sets the default scope of a collection, replacing any previous one; a zero
+// Scope removes it. Queries of the collection, including subqueries, first filter on the
+// scope's condition, and sort and project with its defaults unless they order or select
+// themselves. Grouped queries keep their own sort and projection. Unscoped opts a query out.
+// The condition is parsed once, here, with the default field types and time zone, and an
+// invalid condition, sort or selection is an error leaving the previous scope in place.
+func RegisterScope(collection string, scope Scope) error {
+	compiled := compiledScope{Scope: scope}
+	if scope.Where != "" {
+		qb := NewQueryBuilder().From(collection)
+		compiled.filter = qb.parseConditions(scope.Where)
+		if err := qb.Err(); err != nil {
+			return fmt.Errorf("invalid scope of %s: %v", collection, err)
+		}
+	}
+	if err := NewQueryBuilder().From(collection).OrderBy(scope.OrderBy...).Select(scope.Select...).Err(); err != nil {
+		return fmt.Errorf("invalid scope of %s: %v", collection, err)
+	}
+
+	scopesMu.Lock()
+	defer scopesMu.Unlock()
+	table := maps.Clone(*scopes.Load())
+	if scope.Where == "" && len(scope.OrderBy) == 0 && len(scope.Select) == 0 {
+		delete(table, collection)
+	} else {
+		table[collection] = compiled
+	}
+	scopes.Store(&table)
+	return nil
+}
+
+// Unscoped runs the query without the default scope of its collection.
+func (qb *QueryBuilder) Unscoped() *QueryBuilder {
+	qb.unscoped = true
+	return qb
+}
+
+// scopedPipeline returns the pipeline with the default scope of the collection applied.
+func (qb *QueryBuilder) scopedPipeline() []bson.D {
+	scope, ok := (*scopes.Load())[qb.sourceCollection()]
+	if qb.unscoped || !ok {
+		return qb.Pipeline
+	}
+
+	pipeline := []bson.D{}
+	if scope.Where != "" {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: scope.filter}})
+	}
+	pipeline = append(pipeline, qb.Pipeline...)
+
+	// The default sort and projection refer to the documents of the collection, not to groups
+	tail := qb.Clone()
+	tail.Pipeline, tail.Fields = []bson.D{}, []string{}
+	if qb.Group == nil && qb.Sort == nil {
+		tail.OrderBy(scope.OrderBy...)
+	}
+	if qb.Group == nil && len(qb.Fields) == 0 && len(scope.Select) > 0 {
+		tail.Select(scope.Select...)
+	}
+	return append(pipeline, tail.Pipeline...)
+}
@@ -0,0 +1,49 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Snapshot is a point-in-time view of the data (MongoDB 5.0+), so the queries of a report read
+// the same data even while it is being written:
+//
+//	snap, err := builder.StartSnapshot(mdb.Client)
+//	defer snap.Close()
+//	orders, err := builder.NewQueryBuilder().From("orders").WithSnapshot(snap).Execute(mdb.Database)
+//	refunds, err := builder.NewQueryBuilder().From("refunds").WithSnapshot(snap).Execute(mdb.Database)
+//
+// The view is fixed by the first query and kept by the server for a limited time, five minutes
+// by default (minSnapshotHistoryWindowInSeconds). Queries writing with $out cannot use it, and
+// like any session it serves one query at a time.
+type Snapshot struct {
+	session mongo.Session
+}
+
+// StartSnapshot starts a snapshot session on client.
+func StartSnapshot(client *mongo.Client) (*Snapshot, error) {
+	session, err := client.StartSession(options.Session().SetSnapshot(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start snapshot session: %v", err)
+	}
+	return &Snapshot{session: session}, nil
+}
+
+// Close ends the snapshot session.
+func (s *Snapshot) Close() {
+	s.session.EndSession(context.Background())
+}
+
+// context returns ctx running its operations in the snapshot session.
+func (s *Snapshot) context(ctx context.Context) context.Context {
+	return mongo.NewSessionContext(ctx, s.session)
+}
+
+// WithSnapshot makes the query read from snap, see Snapshot.
+func (qb *QueryBuilder) WithSnapshot(snap *Snapshot) *QueryBuilder {
+	qb.snapshot = snap
+	return qb
+}
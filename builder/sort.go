@@ -0,0 +1,233 @@
+package builder
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// sortDirection matches a trailing ASC/DESC in an ORDER BY item.
+	sortDirection = regexp.MustCompile(`(?i)\s+(ASC|DESC)$`)
+
+	// sortNulls matches a trailing NULLS FIRST/LAST in an ORDER BY item.
+	sortNulls = regexp.MustCompile(`(?i)\s+NULLS\s+(FIRST|LAST)$`)
+
+	// sortCollate matches a trailing COLLATE name in an ORDER BY item.
+	sortCollate = regexp.MustCompile(`(?i)\s+COLLATE\s+(\w+)$`)
+)
+
+// sortItem is a single parsed ORDER BY item.
+type sortItem struct {
+	key       string
+	direction int
+	nulls     string // "FIRST", "LAST" or empty for MongoDB's default null ordering
+	collate   string // Collation name, e.g. "NUMERIC" for numeric-aware string ordering
+}
+
+// parseSortItem parses an ORDER BY item like "price * qty DESC NULLS LAST".
+func parseSortItem(order string) sortItem {
+	item := sortItem{key: strings.TrimSpace(order), direction: 1}
+	if matches := sortNulls.FindStringSubmatch(item.key); matches != nil {
+		item.nulls = strings.ToUpper(matches[1])
+		item.key = strings.TrimSpace(item.key[:len(item.key)-len(matches[0])])
+	}
+	if matches := sortDirection.FindStringSubmatch(item.key); matches != nil {
+		if strings.ToUpper(matches[1]) == "DESC" {
+			item.direction = -1
+		}
+		item.key = strings.TrimSpace(item.key[:len(item.key)-len(matches[0])])
+	}
+	if matches := sortCollate.FindStringSubmatch(item.key); matches != nil {
+		item.collate = matches[1]
+		item.key = strings.TrimSpace(item.key[:len(item.key)-len(matches[0])])
+	}
+	return item
+}
+
+// collationFor returns the collation for a COLLATE name. NUMERIC orders digit sequences
+// by their numeric value ("item2" before "item10"); other names are used as the locale.
+func collationFor(name string) *options.Collation {
+	if strings.ToUpper(name) == "NUMERIC" {
+		return &options.Collation{Locale: "en", NumericOrdering: true}
+	}
+	return &options.Collation{Locale: name}
+}
+
+// splitTopLevel splits a list like the ORDER BY "status ASC, created_at DESC" or function
+// arguments on the commas outside parentheses and string literals.
+func splitTopLevel(order string) []string {
+	tokens, err := tokenize(order)
+	if err != nil {
+		return []string{order}
+	}
+	items := []string{}
+	start, depth := 0, 0
+	for _, tok := range tokens {
+		switch {
+		case tok.kind == tokenLParen:
+			depth++
+		case tok.kind == tokenRParen:
+			depth--
+		case tok.kind == tokenComma && depth == 0:
+			items = append(items, order[start:tok.pos])
+			start = tok.pos + 1
+		}
+	}
+	return append(items, order[start:])
+}
+
+// appendSort appends one $sort stage for the ORDER BY items, in order. Computed keys and
+// explicit NULLS FIRST/LAST are sorted on helper fields added with $addFields and removed afterwards.
+func (qb *QueryBuilder) appendSort(items []sortItem) {
+	for _, item := range items {
+		if item.collate != "" {
+			qb.Collation = collationFor(item.collate)
+		}
+	}
+
+	if len(items) == 1 && isRandomSort(items[0].key) {
+		qb.appendRandomSort()
+		return
+	}
+
+	suffix := len(qb.Pipeline)
+	helpers := bson.D{}
+	sort := bson.D{}
+	for i, item := range items {
+		plain, isPlain := plainSortField(item.key)
+		var key interface{} = "$" + qb.resolveField(plain)
+		switch {
+		case isRandomSort(item.key):
+			isPlain, key = false, bson.M{"$rand": bson.M{}}
+		case !isPlain:
+			expression, err := qb.parseArithmetic(item.key)
+			if err != nil {
+				qb.fail("invalid sort key %q: %v", item.key, err)
+				continue
+			}
+			key = expression
+		}
+
+		if item.nulls != "" {
+			// MongoDB orders null and missing values before everything else ascending
+			nullField := fmt.Sprintf("__sortNull%d_%d", suffix, i)
+			helpers = append(helpers, bson.E{Key: nullField, Value: bson.M{"$cond": []interface{}{
+				bson.M{"$eq": []interface{}{bson.M{"$ifNull": []interface{}{key, nil}}, nil}}, 1, 0,
+			}}})
+			nullDirection := 1
+			if item.nulls == "FIRST" {
+				nullDirection = -1
+			}
+			sort = append(sort, bson.E{Key: nullField, Value: nullDirection})
+		}
+		if isPlain {
+			sort = append(sort, bson.E{Key: qb.resolveField(plain), Value: item.direction})
+		} else {
+			keyField := fmt.Sprintf("__sortKey%d_%d", suffix, i)
+			helpers = append(helpers, bson.E{Key: keyField, Value: key})
+			sort = append(sort, bson.E{Key: keyField, Value: item.direction})
+		}
+	}
+	if len(sort) == 0 {
+		return
+	}
+
+	qb.Sort = sort
+	if len(helpers) == 0 {
+		qb.Pipeline = append(qb.Pipeline, bson.D{{Key: "$sort", Value: sort}})
+		return
+	}
+	unset := []string{}
+	for _, e := range helpers {
+		unset = append(unset, e.Key)
+	}
+	qb.Pipeline = append(qb.Pipeline,
+		bson.D{{Key: "$addFields", Value: helpers}},
+		bson.D{{Key: "$sort", Value: sort}},
+		bson.D{{Key: "$unset", Value: unset}},
+	)
+}
+
+// StableSort sets whether _id is appended as the last key of the outermost sort, unless it is
+// a key already, so documents with equal sort keys always come in the same order and pages
+// neither repeat nor skip them. ExecutePage sorts stably unless StableSort(false) is called;
+// the other executions only with StableSort(true).
+func (qb *QueryBuilder) StableSort(enabled bool) *QueryBuilder {
+	qb.stableSort = &enabled
+	return qb
+}
+
+// addSortTiebreaker appends _id to the keys of the last $sort stage of pipeline, if any. Random
+// sorts are left alone.
+func addSortTiebreaker(pipeline []bson.D) {
+	for i := len(pipeline) - 1; i >= 0; i-- {
+		if pipeline[i][0].Key != "$sort" {
+			continue
+		}
+		sort, ok := pipeline[i][0].Value.(bson.D)
+		if !ok || slices.ContainsFunc(sort, func(e bson.E) bool { return e.Key == "_id" || e.Key == randomSortField }) {
+			return
+		}
+		stable := append(append(bson.D{}, sort...), bson.E{Key: "_id", Value: 1})
+		pipeline[i] = bson.D{{Key: "$sort", Value: stable}} // Replaced, as clones share the stage
+		return
+	}
+}
+
+// SortMap returns the sort keys as a bson.M, for code written when Sort was a map. The map
+// does not keep the order of the keys; use Sort for multi-key sorts.
+func (qb *QueryBuilder) SortMap() bson.M {
+	if qb.Sort == nil {
+		return nil
+	}
+	sort := bson.M{}
+	for _, e := range qb.Sort {
+		sort[e.Key] = e.Value
+	}
+	return sort
+}
+
+// isRandomSort reports whether a sort key is RAND().
+func isRandomSort(key string) bool {
+	return strings.ToUpper(strings.ReplaceAll(key, " ", "")) == "RAND()"
+}
+
+// randomSortField holds the random sort key of ORDER BY RAND().
+const randomSortField = "__sortRandom"
+
+// appendRandomSort sorts documents on a $rand value. When it ends up as the last stage
+// before LIMIT, Execute replaces it with a $sample stage.
+func (qb *QueryBuilder) appendRandomSort() {
+	qb.Sort = bson.D{{Key: randomSortField, Value: 1}}
+	qb.Pipeline = append(qb.Pipeline,
+		bson.D{{Key: "$addFields", Value: bson.M{randomSortField: bson.M{"$rand": bson.M{}}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: randomSortField, Value: 1}}}},
+		bson.D{{Key: "$unset", Value: randomSortField}},
+	)
+}
+
+// trailingRandomSort returns the position of ORDER BY RAND() stages ending pipeline, or -1.
+func trailingRandomSort(pipeline []bson.D) int {
+	start := len(pipeline) - 3
+	if start < 0 || pipeline[start][0].Key != "$addFields" {
+		return -1
+	}
+	if fields, ok := pipeline[start][0].Value.(bson.M); !ok || fields[randomSortField] == nil {
+		return -1
+	}
+	return start
+}
+
+// plainSortField returns the field name when a sort key is a plain (possibly dotted or quoted) field.
+func plainSortField(key string) (string, bool) {
+	tokens, err := tokenize(key)
+	if err != nil || len(tokens) != 1 || tokens[0].kind != tokenIdent {
+		return "", false
+	}
+	return tokens[0].text, true
+}
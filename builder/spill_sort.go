@@ -0,0 +1,292 @@
+package builder
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ExternalSorter sorts rows on the client that may not fit in memory, e.g. the rows a
+// MaxResultBytes overflow handler receives. Rows are kept in memory up to maxBytes, then
+// written to temporary files as sorted runs, which Each merges. Rows round-trip through BSON,
+// so subdocuments come back as bson.M.
+//
+//	sorter := builder.NewExternalSorter("", bson.D{{Key: "total", Value: -1}}, 64<<20)
+//	defer sorter.Close()
+//	_, err := qb.MaxResultBytes(64<<20, sorter.Add).Execute(mdb.Database)
+//	err = sorter.Each(func(row map[string]interface{}) error { ... })
+type ExternalSorter struct {
+	dir      string
+	sort     bson.D
+	maxBytes int64
+
+	rows []map[string]interface{}
+	size int64
+	runs []string
+}
+
+// NewExternalSorter creates a sorter ordering rows on the keys of sort (1 ascending, -1
+// descending), spilling to temporary files in dir (os.TempDir() when empty) beyond maxBytes.
+func NewExternalSorter(dir string, sort bson.D, maxBytes int64) *ExternalSorter {
+	return &ExternalSorter{dir: dir, sort: sort, maxBytes: maxBytes}
+}
+
+// Add adds rows to the sorter. Its signature matches OverflowHandler.
+func (s *ExternalSorter) Add(rows []map[string]interface{}) error {
+	for _, row := range rows {
+		data, err := bson.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("failed to encode row: %v", err)
+		}
+		if s.maxBytes > 0 && s.size+int64(len(data)) > s.maxBytes && len(s.rows) > 0 {
+			if err := s.spill(); err != nil {
+				return err
+			}
+		}
+		s.rows = append(s.rows, row)
+		s.size += int64(len(data))
+	}
+	return nil
+}
+
+// Each calls fn with every row added so far, in sort order, stopping at the first error.
+func (s *ExternalSorter) Each(fn func(row map[string]interface{}) error) error {
+	if len(s.runs) == 0 {
+		s.sortRows()
+		for _, row := range s.rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(s.rows) > 0 {
+		if err := s.spill(); err != nil {
+			return err
+		}
+	}
+	return s.merge(fn)
+}
+
+// Close removes the temporary files of the sorter.
+func (s *ExternalSorter) Close() error {
+	var errs []error
+	for _, run := range s.runs {
+		if err := os.Remove(run); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	s.runs, s.rows, s.size = nil, nil, 0
+	return errors.Join(errs...)
+}
+
+// sortRows sorts the rows held in memory.
+func (s *ExternalSorter) sortRows() {
+	sort.SliceStable(s.rows, func(i, j int) bool {
+		return s.compareRows(s.rows[i], s.rows[j]) < 0
+	})
+}
+
+// spill writes the rows held in memory to a new sorted run.
+func (s *ExternalSorter) spill() error {
+	s.sortRows()
+	file, err := os.CreateTemp(s.dir, "mongoquery-sort-*.bson")
+	if err != nil {
+		return fmt.Errorf("failed to create sort run: %v", err)
+	}
+	defer file.Close()
+	s.runs = append(s.runs, file.Name())
+
+	writer := bufio.NewWriter(file)
+	for _, row := range s.rows {
+		data, err := bson.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("failed to encode row: %v", err)
+		}
+		if _, err := writer.Write(data); err != nil {
+			return fmt.Errorf("failed to write sort run: %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write sort run: %v", err)
+	}
+	s.rows, s.size = nil, 0
+	return nil
+}
+
+// merge calls fn with the rows of all runs in sort order.
+func (s *ExternalSorter) merge(fn func(row map[string]interface{}) error) error {
+	readers := make([]*bufio.Reader, len(s.runs))
+	heads := make([]map[string]interface{}, len(s.runs))
+	for i, run := range s.runs {
+		file, err := os.Open(run)
+		if err != nil {
+			return fmt.Errorf("failed to open sort run: %v", err)
+		}
+		defer file.Close()
+		readers[i] = bufio.NewReader(file)
+		if heads[i], err = readRow(readers[i]); err != nil {
+			return err
+		}
+	}
+
+	for {
+		next := -1
+		for i, head := range heads {
+			if head != nil && (next == -1 || s.compareRows(head, heads[next]) < 0) {
+				next = i
+			}
+		}
+		if next == -1 {
+			return nil
+		}
+		if err := fn(heads[next]); err != nil {
+			return err
+		}
+		var err error
+		if heads[next], err = readRow(readers[next]); err != nil {
+			return err
+		}
+	}
+}
+
+// readRow reads the next BSON document of a run, or nil at its end.
+func readRow(reader *bufio.Reader) (map[string]interface{}, error) {
+	header, err := reader.Peek(4)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sort run: %v", err)
+	}
+	data := make([]byte, binary.LittleEndian.Uint32(header))
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, fmt.Errorf("failed to read sort run: %v", err)
+	}
+
+	decoder, err := bson.NewDecoder(bsonrw.NewBSONDocumentReader(data))
+	if err != nil {
+		return nil, err
+	}
+	decoder.DefaultDocumentM()
+	row := map[string]interface{}{}
+	if err := decoder.Decode(&row); err != nil {
+		return nil, fmt.Errorf("failed to decode sort run: %v", err)
+	}
+	return row, nil
+}
+
+// compareRows compares two rows on the sort keys.
+func (s *ExternalSorter) compareRows(a, b map[string]interface{}) int {
+	for _, key := range s.sort {
+		result := compareValues(rowValue(a, key.Key), rowValue(b, key.Key))
+		if sortRank(key.Value) == 1 && sortNumber(key.Value) < 0 {
+			result = -result
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+// rowValue returns the value at a dotted path of a row, or nil when it is missing.
+func rowValue(row map[string]interface{}, path string) interface{} {
+	var value interface{} = row
+	for _, part := range strings.Split(path, ".") {
+		switch doc := value.(type) {
+		case map[string]interface{}:
+			value = doc[part]
+		case bson.M:
+			value = doc[part]
+		case bson.D:
+			value = doc.Map()[part]
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+// compareValues orders values like MongoDB across types: null, numbers, strings, ObjectIds,
+// booleans, dates, then anything else by its text.
+func compareValues(a, b interface{}) int {
+	rankA, rankB := sortRank(a), sortRank(b)
+	if rankA != rankB {
+		return rankA - rankB
+	}
+	switch rankA {
+	case 0:
+		return 0
+	case 1:
+		x, y := sortNumber(a), sortNumber(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case 3:
+		return strings.Compare(a.(primitive.ObjectID).Hex(), b.(primitive.ObjectID).Hex())
+	case 4:
+		x, y := a.(bool), b.(bool)
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		}
+		return 1
+	case 5:
+		return sortTime(a).Compare(sortTime(b))
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// sortRank returns the position of a value's type in the cross-type sort order.
+func sortRank(value interface{}) int {
+	switch value.(type) {
+	case nil, primitive.Null:
+		return 0
+	case int, int32, int64, float32, float64, primitive.Decimal128:
+		return 1
+	case string:
+		return 2
+	case primitive.ObjectID:
+		return 3
+	case bool:
+		return 4
+	case time.Time, primitive.DateTime:
+		return 5
+	}
+	return 6
+}
+
+// sortNumber converts a numeric value to a float64 for comparison.
+func sortNumber(value interface{}) float64 {
+	if decimal, ok := value.(primitive.Decimal128); ok {
+		var f float64
+		fmt.Sscan(decimal.String(), &f)
+		return f
+	}
+	return normalizeDiffValue(value).(float64)
+}
+
+// sortTime converts a date value to a time.Time for comparison.
+func sortTime(value interface{}) time.Time {
+	if dateTime, ok := value.(primitive.DateTime); ok {
+		return dateTime.Time()
+	}
+	return value.(time.Time)
+}
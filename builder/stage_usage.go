@@ -0,0 +1,226 @@
+package builder
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// stageUsageEnabled enables counting the stages and operators of executed pipelines.
+var stageUsageEnabled atomic.Bool
+
+var (
+	stageUsageMu sync.Mutex
+	stageUsage   = StageUsage{Stages: map[string]int64{}, Operators: map[string]int64{}}
+)
+
+// StageUsage counts the aggregation stages and operators of the pipelines the application
+// executed, see SetStageUsageTracking.
+type StageUsage struct {
+	Stages    map[string]int64 // Executions using each stage, like "$lookup"
+	Operators map[string]int64 // Executions using each operator, like "$dateTrunc" or "$sum"
+}
+
+// minServerVersions are the MongoDB versions introducing the stages and operators the builder
+// may generate, or users may write in a raw pipeline; others exist since 3.6 or earlier.
+var minServerVersions = map[string]string{
+	"$convert": "4.0", "$toString": "4.0", "$toInt": "4.0", "$toLong": "4.0", "$toDouble": "4.0",
+	"$toDecimal": "4.0", "$toDate": "4.0", "$toObjectId": "4.0", "$toBool": "4.0", "$trim": "4.0",
+	"$ltrim": "4.0", "$rtrim": "4.0",
+	"$merge": "4.2", "$set": "4.2", "$unset": "4.2", "$replaceWith": "4.2", "$regexMatch": "4.2",
+	"$regexFind": "4.2", "$regexFindAll": "4.2", "$round": "4.2", "$replaceOne": "4.4",
+	"$replaceAll": "4.4", "$unionWith": "4.4", "$accumulator": "4.4", "$function": "4.4",
+	"$rand": "4.4", "$binarySize": "4.4", "$bsonSize": "4.4", "$isNumber": "4.4",
+	"$setWindowFields": "5.0", "$dateTrunc": "5.0", "$dateAdd": "5.0", "$dateSubtract": "5.0",
+	"$dateDiff": "5.0", "$getField": "5.0", "$setField": "5.0", "$count": "5.0",
+	"$densify": "5.1", "$documents": "5.1",
+	"$sortArray": "5.2", "$top": "5.2", "$bottom": "5.2", "$topN": "5.2", "$bottomN": "5.2",
+	"$firstN": "5.2", "$lastN": "5.2", "$minN": "5.2", "$maxN": "5.2", "$locf": "5.2",
+	"$fill": "5.3", "$linearFill": "5.3",
+	"$bitAnd": "6.3", "$bitOr": "6.3", "$bitXor": "6.3", "$bitNot": "6.3",
+	"$median": "7.0", "$percentile": "7.0",
+}
+
+// SetStageUsageTracking makes Execute and the other executions count the stages and operators of
+// their pipelines, reported by StageUsageReport, to understand the shape of the application's
+// workload and the server version it needs. Disabled by default, since counting walks the
+// pipeline.
+func SetStageUsageTracking(enabled bool) {
+	stageUsageEnabled.Store(enabled)
+}
+
+// StageUsageReport returns a copy of the counts since the start or the last ResetStageUsage.
+func StageUsageReport() StageUsage {
+	stageUsageMu.Lock()
+	defer stageUsageMu.Unlock()
+	return StageUsage{Stages: maps.Clone(stageUsage.Stages), Operators: maps.Clone(stageUsage.Operators)}
+}
+
+// ResetStageUsage clears the counts.
+func ResetStageUsage() {
+	stageUsageMu.Lock()
+	defer stageUsageMu.Unlock()
+	stageUsage = StageUsage{Stages: map[string]int64{}, Operators: map[string]int64{}}
+}
+
+// MinServerVersion returns the lowest MongoDB version supporting every counted stage and
+// operator, and those requiring it; "" when any version from 3.6 on does.
+func (u StageUsage) MinServerVersion() (string, []string) {
+	version, features := "", []string{}
+	for _, counts := range []map[string]int64{u.Stages, u.Operators} {
+		for name := range counts {
+			required, ok := minServerVersions[name]
+			switch {
+			case !ok:
+			case compareVersions(required, version) > 0:
+				version, features = required, []string{name}
+			case required == version:
+				features = append(features, name)
+			}
+		}
+	}
+	slices.Sort(features)
+	return version, slices.Compact(features)
+}
+
+// compareVersions compares "major.minor" versions, "" being the lowest.
+func compareVersions(a, b string) int {
+	parse := func(version string) []int {
+		parts := []int{}
+		for _, part := range strings.Split(version, ".") {
+			n, _ := strconv.Atoi(part)
+			parts = append(parts, n)
+		}
+		return parts
+	}
+	if a == "" || b == "" {
+		return strings.Compare(a, b)
+	}
+	return slices.Compare(parse(a), parse(b))
+}
+
+// recordStageUsage counts the stages and operators of a pipeline about to run, once each.
+func recordStageUsage(pipeline []bson.D) {
+	if !stageUsageEnabled.Load() {
+		return
+	}
+	stages, operators := map[string]bool{}, map[string]bool{}
+	collectPipeline(pipeline, stages, operators)
+
+	stageUsageMu.Lock()
+	defer stageUsageMu.Unlock()
+	for name := range stages {
+		stageUsage.Stages[name]++
+	}
+	for name := range operators {
+		stageUsage.Operators[name]++
+	}
+}
+
+// collectPipeline adds the stages of pipeline and the operators inside them to the sets.
+func collectPipeline(pipeline []bson.D, stages, operators map[string]bool) {
+	for _, stage := range pipeline {
+		for _, e := range stage {
+			stages[e.Key] = true
+			if e.Key == "$facet" {
+				forEachField(e.Value, func(_ string, value interface{}) {
+					collectPipeline(subPipeline(value), stages, operators)
+				})
+				continue
+			}
+			collectOperators(e.Value, stages, operators)
+		}
+	}
+}
+
+// collectOperators adds the operators of an expression to operators, and the stages of the
+// pipelines of $lookup and $unionWith inside it to stages.
+func collectOperators(value interface{}, stages, operators map[string]bool) {
+	if items, ok := listItems(value); ok {
+		for _, item := range items {
+			collectOperators(item, stages, operators)
+		}
+		return
+	}
+	forEachField(value, func(key string, value interface{}) {
+		switch {
+		case key == "pipeline":
+			if pipeline := subPipeline(value); pipeline != nil {
+				collectPipeline(pipeline, stages, operators)
+				return
+			}
+		case strings.HasPrefix(key, "$"):
+			operators[key] = true
+		}
+		collectOperators(value, stages, operators)
+	})
+}
+
+// forEachField calls fn with the fields of a document value; other values have none.
+func forEachField(value interface{}, fn func(key string, value interface{})) {
+	switch v := value.(type) {
+	case bson.D:
+		for _, e := range v {
+			fn(e.Key, e.Value)
+		}
+	case bson.M:
+		for key, value := range v {
+			fn(key, value)
+		}
+	case map[string]interface{}:
+		for key, value := range v {
+			fn(key, value)
+		}
+	}
+}
+
+// listItems returns the items of an array value.
+func listItems(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case bson.A:
+		return v, true
+	case []interface{}:
+		return v, true
+	case []bson.D:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items, true
+	case []bson.M:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items, true
+	}
+	return nil, false
+}
+
+// subPipeline returns the stages of a nested pipeline value, nil when it is not one.
+func subPipeline(value interface{}) []bson.D {
+	if pipeline, ok := value.([]bson.D); ok {
+		return pipeline
+	}
+	items, ok := listItems(value)
+	if !ok {
+		return nil
+	}
+	pipeline := []bson.D{}
+	for _, item := range items {
+		stage := bson.D{}
+		forEachField(item, func(key string, value interface{}) {
+			stage = append(stage, bson.E{Key: key, Value: value})
+		})
+		if len(stage) != 1 {
+			return nil
+		}
+		pipeline = append(pipeline, stage)
+	}
+	return pipeline
+}
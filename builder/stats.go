@@ -0,0 +1,150 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// QueryStats describes one execution of a query.
+type QueryStats struct {
+	Collection  string
+	Fingerprint string   // Shape of the query, see Fingerprint
+	Pipeline    []bson.D // Pipeline as sent, with its values
+	Start       time.Time
+	Duration    time.Duration
+	Documents   int   // Documents returned
+	Err         error // Error the execution failed with, nil on success
+}
+
+// QueryObserver receives the stats of query executions.
+type QueryObserver func(stats QueryStats)
+
+var (
+	observerMu sync.RWMutex
+	observer   QueryObserver
+)
+
+// SetQueryObserver installs an observer called after every Execute and ExecuteToJSON of any
+// QueryBuilder, e.g. a metrics collector. A nil observer removes it.
+func SetQueryObserver(o QueryObserver) {
+	observerMu.Lock()
+	defer observerMu.Unlock()
+	observer = o
+}
+
+// queryObserver returns the installed observer, or nil.
+func queryObserver() QueryObserver {
+	observerMu.RLock()
+	defer observerMu.RUnlock()
+	return observer
+}
+
+// observeExecution starts timing an execution with ctx and returns the function reporting its
+// stats, which does nothing without an observer.
+func (qb *QueryBuilder) observeExecution(ctx context.Context) func(documents int, err error) {
+	o := queryObserver()
+	if o == nil {
+		return func(int, error) {}
+	}
+	collection, fingerprint, start := qb.sourceCollection(), qb.Fingerprint(), time.Now()
+	return func(documents int, err error) {
+		pipeline, _ := qb.executionPipeline(ctx) // Its error, if any, is the execution's
+		o(QueryStats{
+			Collection:  collection,
+			Fingerprint: fingerprint,
+			Pipeline:    pipeline,
+			Start:       start,
+			Duration:    time.Since(start),
+			Documents:   documents,
+			Err:         err,
+		})
+	}
+}
+
+// Fingerprint returns a short hash of the query's shape: the collection and the pipeline with
+// literal values left out, so queries differing only in their values, or in the number of values
+// of an IN list, share a fingerprint.
+func (qb *QueryBuilder) Fingerprint() string {
+	var shape strings.Builder
+	shape.WriteString(qb.sourceCollection())
+	writeShape(&shape, qb.Pipeline)
+	hash := fnv.New64a()
+	hash.Write([]byte(shape.String()))
+	return fmt.Sprintf("%016x", hash.Sum64())
+}
+
+// writeShape writes the shape of a pipeline value: keys, operators and field references are
+// kept, other values become "?" and arrays of such values a single "[?]".
+func writeShape(shape *strings.Builder, value interface{}) {
+	switch v := value.(type) {
+	case []bson.D:
+		for _, stage := range v {
+			writeShape(shape, stage)
+		}
+	case bson.D:
+		shape.WriteString("{")
+		for _, e := range v {
+			shape.WriteString(e.Key + ":")
+			writeShape(shape, e.Value)
+		}
+		shape.WriteString("}")
+	case bson.M:
+		writeShape(shape, map[string]interface{}(v))
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		shape.WriteString("{")
+		for _, key := range keys {
+			shape.WriteString(key + ":")
+			writeShape(shape, v[key])
+		}
+		shape.WriteString("}")
+	case bson.A:
+		writeShape(shape, []interface{}(v))
+		return
+	case []interface{}:
+		if !slices.ContainsFunc(v, isShaped) {
+			shape.WriteString("[?],") // Lists of values, as of IN, share a shape whatever their length
+			return
+		}
+		shape.WriteString("[")
+		for _, item := range v {
+			writeShape(shape, item)
+		}
+		shape.WriteString("]")
+	case []string:
+		shape.WriteString(fmt.Sprint(v))
+	case string:
+		if strings.HasPrefix(v, "$") {
+			shape.WriteString(v) // Field reference
+			return
+		}
+		shape.WriteString("?")
+	default:
+		shape.WriteString("?")
+	}
+	shape.WriteString(",")
+}
+
+// isShaped reports whether a pipeline value has a shape of its own: a document, an array or a
+// field reference, rather than a literal value.
+func isShaped(value interface{}) bool {
+	switch v := value.(type) {
+	case []bson.D, bson.D, bson.M, map[string]interface{}, bson.A, []interface{}, []string:
+		return true
+	case string:
+		return strings.HasPrefix(v, "$")
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MatchSubquery adds a filter comparing field against the values returned by sub,
+// with ANY (or SOME) and ALL semantics, e.g. "amount > ALL (SELECT amount FROM refunds)".
+func (qb *QueryBuilder) MatchSubquery(field, operator, quantifier string, sub *QueryBuilder) *QueryBuilder {
+	valueField := sub.subqueryValueField()
+	if valueField == "" {
+		qb.fail("subquery of %s must select exactly one field", field)
+		return qb
+	}
+
+	as := fmt.Sprintf("__subquery%d", len(qb.Pipeline))
+	comparison := quantifiedComparison("$"+qb.resolveField(field), operator, quantifier, "$"+as+"."+valueField)
+	if comparison == nil {
+		qb.fail("unsupported subquery comparison %s %s", operator, quantifier)
+		return qb
+	}
+
+	qb.Pipeline = append(qb.Pipeline,
+		bson.D{{Key: "$lookup", Value: bson.M{
+			"from":     sub.sourceCollection(),
+			"pipeline": sub.subqueryPipeline(),
+			"as":       as,
+		}}},
+		bson.D{{Key: "$match", Value: bson.M{"$expr": comparison}}},
+		bson.D{{Key: "$unset", Value: as}},
+	)
+	return qb
+}
+
+// subqueryValueField returns the single field a subquery selects.
+func (qb *QueryBuilder) subqueryValueField() string {
+	if len(qb.Fields) != 1 || qb.Fields[0] == "*" {
+		return ""
+	}
+	return qb.ResultKey(qb.Fields[0])
+}
+
+// subqueryPipeline returns the pipeline of a subquery including its scope, skip and limit.
+func (qb *QueryBuilder) subqueryPipeline() []bson.D {
+	pipeline := append([]bson.D{}, qb.scopedPipeline()...)
+	if qb.OffsetVal > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: qb.OffsetVal}})
+	}
+	if qb.LimitVal > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: qb.LimitVal}})
+	}
+	return pipeline
+}
+
+// quantifiedComparison builds the $expr comparing field against the array of subquery values.
+func quantifiedComparison(field, operator, quantifier, values string) bson.M {
+	mongoOperator := mapOperatorToMongo(operator)
+	switch mongoOperator {
+	case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte":
+	default:
+		return nil
+	}
+
+	all := false
+	switch strings.ToUpper(quantifier) {
+	case "ALL":
+		all = true
+	case "ANY", "SOME":
+	default:
+		return nil
+	}
+
+	// = ANY and != ALL are plain membership tests
+	if !all && mongoOperator == "$eq" {
+		return bson.M{"$in": []interface{}{field, values}}
+	}
+	if all && mongoOperator == "$ne" {
+		return bson.M{"$not": []interface{}{bson.M{"$in": []interface{}{field, values}}}}
+	}
+
+	var compare bson.M
+	switch {
+	case mongoOperator == "$gt" || mongoOperator == "$gte":
+		compare = bson.M{mongoOperator: []interface{}{field, bson.M{boundOperator(all, "$max", "$min"): values}}}
+	case mongoOperator == "$lt" || mongoOperator == "$lte":
+		compare = bson.M{mongoOperator: []interface{}{field, bson.M{boundOperator(all, "$min", "$max"): values}}}
+	default:
+		// = ALL and != ANY compare against every element
+		elements := bson.M{"$map": bson.M{
+			"input": values,
+			"as":    "value",
+			"in":    bson.M{mongoOperator: []interface{}{field, "$$value"}},
+		}}
+		if all {
+			return bson.M{"$allElementsTrue": []interface{}{elements}}
+		}
+		return bson.M{"$anyElementTrue": []interface{}{elements}}
+	}
+
+	// ALL over an empty set is true, ANY over an empty set is false
+	size := bson.M{"$size": values}
+	if all {
+		return bson.M{"$or": []interface{}{bson.M{"$eq": []interface{}{size, 0}}, compare}}
+	}
+	return bson.M{"$and": []interface{}{bson.M{"$gt": []interface{}{size, 0}}, compare}}
+}
+
+// boundOperator picks the accumulator used to compare against ALL or ANY values.
+func boundOperator(all bool, allOperator, anyOperator string) string {
+	if all {
+		return allOperator
+	}
+	return anyOperator
+}
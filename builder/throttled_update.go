@@ -0,0 +1,47 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UpdateProgress reports the state of a throttled update after each batch.
+type UpdateProgress struct {
+	Batch    int         // Number of batches processed so far
+	Matched  int64       // Documents matched so far
+	Modified int64       // Documents modified so far
+	LastID   interface{} // _id of the last document in the batch
+}
+
+// Throttle makes a multi-document update run in _id-ordered batches of batchSize documents,
+// pausing interval between batches and calling progress (if not nil) after each one. This
+// keeps write locks short and replication lag low on large collections.
+func (ub *UpdateBuilder) Throttle(batchSize int64, interval time.Duration, progress func(UpdateProgress)) *UpdateBuilder {
+	ub.batchSize = batchSize
+	ub.batchInterval = interval
+	ub.updateProgress = progress
+	return ub
+}
+
+// executeThrottled runs the update batch by batch and returns the modified count.
+func (ub *UpdateBuilder) executeThrottled(ctx context.Context, collection *mongo.Collection, update interface{}) (int64, error) {
+	state := UpdateProgress{}
+	err := forEachIDBatch(ctx, collection, ub.Filter, ub.batchSize, nil, ub.batchInterval, func(ids []interface{}) error {
+		result, err := collection.UpdateMany(ctx, inBatch(ub.Filter, ids), update)
+		if err != nil {
+			return fmt.Errorf("failed to update documents: %w", err)
+		}
+		state.Batch++
+		state.Matched += result.MatchedCount
+		state.Modified += result.ModifiedCount
+		state.LastID = ids[len(ids)-1]
+		if ub.updateProgress != nil {
+			ub.updateProgress(state)
+		}
+		return nil
+	})
+	return state.Modified, err
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // UpdateBuilder helps in updating documents in a MongoDB collection.
@@ -14,7 +18,23 @@ type UpdateBuilder struct {
 	Collection string
 	UpdateData bson.M
 	Filter     bson.M
-	Multi      bool // If true, updates multiple documents
+	Multi      bool     // If true, updates multiple documents
+	Copies     bson.M   // Fields set from the current value of another field
+	Divisors   bson.M   // Fields divided by a number, see Div
+	Unsets     []string // Fields removed by the update
+
+	writePolicy
+	batchSize      int64
+	batchInterval  time.Duration
+	updateProgress func(UpdateProgress)
+	idempotencyKey string
+	confirmTimeout time.Duration
+	stringIDs      bool
+	exactIDs       bool
+	location       *time.Location
+	ctx            context.Context
+
+	buildErrors
 }
 
 // NewUpdateBuilder initializes a new UpdateBuilder for a specific collection.
@@ -33,10 +53,59 @@ func (ub *UpdateBuilder) Set(data map[string]interface{}) *UpdateBuilder {
 	return ub
 }
 
-// Where specifies the filter condition for the update.
-func (ub *UpdateBuilder) Where(condition string) *UpdateBuilder {
-	qb := QueryBuilder{}
-	ub.Filter = qb.parseConditions(condition) // Reuse parseConditions from QueryBuilder
+// SetFrom sets field to the current value of source, e.g. to copy or rename a field.
+func (ub *UpdateBuilder) SetFrom(field, source string) *UpdateBuilder {
+	if ub.Copies == nil {
+		ub.Copies = bson.M{}
+	}
+	ub.Copies[field] = "$" + source
+	return ub
+}
+
+// Inc increments field by amount, which may be negative to decrement.
+func (ub *UpdateBuilder) Inc(field string, amount interface{}) *UpdateBuilder {
+	ub.operator("$inc")[field] = amount
+	return ub
+}
+
+// Mul multiplies field by factor.
+func (ub *UpdateBuilder) Mul(field string, factor interface{}) *UpdateBuilder {
+	ub.operator("$mul")[field] = factor
+	return ub
+}
+
+// Div divides field by divisor with $divide in a pipeline update, since $mul by the reciprocal
+// would round it. Missing fields count as 0, like with Inc and Mul.
+func (ub *UpdateBuilder) Div(field string, divisor interface{}) *UpdateBuilder {
+	if ub.Divisors == nil {
+		ub.Divisors = bson.M{}
+	}
+	ub.Divisors[field] = divisor
+	return ub
+}
+
+// operator returns the fields of an update operator, creating them on first use.
+func (ub *UpdateBuilder) operator(name string) bson.M {
+	fields, ok := ub.UpdateData[name].(bson.M)
+	if !ok {
+		fields = bson.M{}
+		ub.UpdateData[name] = fields
+	}
+	return fields
+}
+
+// Unset removes the given fields from the matched documents.
+func (ub *UpdateBuilder) Unset(fields ...string) *UpdateBuilder {
+	ub.Unsets = append(ub.Unsets, fields...)
+	return ub
+}
+
+// Where specifies the filter condition for the update, with values bound to placeholders as
+// in QueryBuilder.Match.
+func (ub *UpdateBuilder) Where(condition string, args ...interface{}) *UpdateBuilder {
+	qb := QueryBuilder{stringIDs: ub.stringIDs, exactIDs: ub.exactIDs, location: ub.location}
+	ub.Filter = qb.bindParams(condition, args, qb.parseConditions) // Reuse parseConditions from QueryBuilder
+	ub.failWith(qb.buildErrors)
 	return ub
 }
 
@@ -47,25 +116,135 @@ func (ub *UpdateBuilder) SetMulti(multi bool) *UpdateBuilder {
 }
 
 // Execute performs the update operation.
-func (ub *UpdateBuilder) Execute(db *mongo.Database) (int64, error) {
+func (ub *UpdateBuilder) Execute(db *mongo.Database) (_ int64, err error) {
+	defer recoverTo(&err)
 	if ub.Collection == "" {
 		return 0, errors.New("collection name is not specified")
 	}
+	if err := ub.Err(); err != nil {
+		return 0, err
+	}
+	if _, err := ub.buildUpdate(); err != nil {
+		return 0, err
+	}
+	ctx, release, err := trackContext(ub.ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	ub = ub.annotated()
+	if ub.idempotencyKey == "" {
+		return ub.execute(ctx, db)
+	}
 
+	stored, err := runIdempotent(ctx, db, ub.Collection, ub.idempotencyKey, func() (interface{}, error) {
+		return ub.execute(ctx, db)
+	})
+	if err != nil && !errors.Is(err, ErrIdempotencyNotRecorded) {
+		return 0, err
+	}
+	modified, ok := stored.AsInt64OK()
+	if !ok {
+		return 0, errors.New("failed to decode stored update result")
+	}
+	return modified, err
+}
+
+// execute runs the update with ctx and returns the modified count.
+func (ub *UpdateBuilder) execute(ctx context.Context, db *mongo.Database) (int64, error) {
 	collection := db.Collection(ub.Collection)
 
-	// UpdateOne or UpdateMany
-	var result *mongo.UpdateResult
 	var err error
 	if ub.Multi {
-		result, err = collection.UpdateMany(context.TODO(), ub.Filter, ub.UpdateData)
+		collection, err = ub.writePolicy.apply(ctx, collection, ub.Filter)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if ub.confirmTimeout <= 0 {
+		return ub.write(ctx, collection)
+	}
+	ids, err := matchingIDs(ctx, collection, ub.Filter, ub.Multi, ub.confirmTimeout)
+	if err != nil {
+		return 0, err
+	}
+	confirmed := *ub
+	confirmed.Filter = confirmedFilter(ub.Filter, ids, ub.Multi)
+	return confirmWrite(ctx, collection, ids, []string{"update", "replace"}, ub.confirmTimeout, func() (int64, error) {
+		return confirmed.write(ctx, collection)
+	})
+}
+
+// write performs UpdateOne or UpdateMany with ctx and returns the modified count.
+func (ub *UpdateBuilder) write(ctx context.Context, collection *mongo.Collection) (int64, error) {
+	update, err := ub.buildUpdate()
+	if err != nil {
+		return 0, err
+	}
+	if ub.Multi && ub.batchSize > 0 {
+		return ub.executeThrottled(ctx, collection, update)
+	}
+
+	var result *mongo.UpdateResult
+	if ub.Multi {
+		result, err = collection.UpdateMany(ctx, ub.Filter, update, commented(ctx, options.Update()))
 	} else {
-		result, err = collection.UpdateOne(context.TODO(), ub.Filter, ub.UpdateData)
+		result, err = collection.UpdateOne(ctx, ub.Filter, update, commented(ctx, options.Update()))
 	}
 
 	if err != nil {
-		return 0, fmt.Errorf("failed to update documents: %v", err)
+		return 0, fmt.Errorf("failed to update documents: %w", err)
 	}
 
 	return result.ModifiedCount, nil
 }
+
+// buildUpdate returns the update document, or an update pipeline when fields are copied
+// or removed. All assignments share one $set stage so they read the values before the update.
+// Operators other than $set, $inc, $mul and $unset have no pipeline form here, so combining
+// them with copies or removals is an error rather than an update leaving them out.
+func (ub *UpdateBuilder) buildUpdate() (interface{}, error) {
+	if len(ub.Copies) == 0 && len(ub.Divisors) == 0 && len(ub.Unsets) == 0 {
+		return ub.UpdateData, nil
+	}
+
+	set, unsets := bson.M{}, append([]string{}, ub.Unsets...)
+	for _, operator := range slices.Sorted(maps.Keys(ub.UpdateData)) {
+		fields := ub.UpdateData[operator]
+		switch operator {
+		case "$set":
+			forEachField(fields, func(field string, value interface{}) {
+				set[field] = bson.M{"$literal": value}
+			})
+		case "$inc": // Missing fields count as 0 here and in $mul, as with the operators
+			forEachField(fields, func(field string, amount interface{}) {
+				set[field] = bson.M{"$add": []interface{}{bson.M{"$ifNull": []interface{}{"$" + field, 0}}, bson.M{"$literal": amount}}}
+			})
+		case "$mul":
+			forEachField(fields, func(field string, factor interface{}) {
+				set[field] = bson.M{"$multiply": []interface{}{bson.M{"$ifNull": []interface{}{"$" + field, 0}}, bson.M{"$literal": factor}}}
+			})
+		case "$unset":
+			forEachField(fields, func(field string, _ interface{}) { unsets = append(unsets, field) })
+		default:
+			return nil, fmt.Errorf("update operator %s cannot be combined with SetFrom, Div or Unset", operator)
+		}
+	}
+	for field, source := range ub.Copies {
+		set[field] = source
+	}
+	for field, divisor := range ub.Divisors {
+		set[field] = bson.M{"$divide": []interface{}{bson.M{"$ifNull": []interface{}{"$" + field, 0}}, bson.M{"$literal": divisor}}}
+	}
+
+	pipeline := []bson.D{}
+	if len(set) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$set", Value: set}})
+	}
+	if len(unsets) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$unset", Value: unsets}})
+	}
+	return pipeline, nil
+}
@@ -0,0 +1,169 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UpsertBuilder writes documents matched on key fields: a document replaces the document with
+// the same keys, or is inserted when there is none.
+type UpsertBuilder struct {
+	Collection string
+	Documents  []interface{}
+	KeyFields  []string
+
+	merge       bool
+	ordered     bool
+	deadLetters deadLetterPolicy
+	ctx         context.Context
+
+	buildErrors
+}
+
+// UpsertResult counts the documents of an upsert.
+type UpsertResult struct {
+	Inserted    int64                 // Documents without a match, inserted
+	Matched     int64                 // Documents matching an existing document
+	Modified    int64                 // Matched documents that changed
+	UpsertedIDs map[int64]interface{} // _id of each inserted document, by index in Documents
+}
+
+// NewUpsertBuilder initializes a new UpsertBuilder for a specific collection.
+func NewUpsertBuilder(collection string) *UpsertBuilder {
+	return &UpsertBuilder{Collection: collection, ordered: true}
+}
+
+// UpsertMany adds documents, given as structs, maps or bson.D, matched on keyFields, e.g.
+// UpsertMany(products, "sku") or UpsertMany(rows, "tenant", "day"). Key fields may be dotted
+// paths and must be present in every document.
+func (ub *UpsertBuilder) UpsertMany(docs []interface{}, keyFields ...string) *UpsertBuilder {
+	ub.Documents = append(ub.Documents, docs...)
+	ub.KeyFields = keyFields
+	return ub
+}
+
+// Merge makes a matched document receive the fields of the new document with $set instead of
+// being replaced, keeping its other fields.
+func (ub *UpsertBuilder) Merge(enabled bool) *UpsertBuilder {
+	ub.merge = enabled
+	return ub
+}
+
+// Ordered sets whether the writes stop at the first error (the default) or continue past it.
+// Unordered writes of documents with the same keys may apply in any order.
+func (ub *UpsertBuilder) Ordered(ordered bool) *UpsertBuilder {
+	ub.ordered = ordered
+	return ub
+}
+
+// WithContext makes the upsert run with ctx, e.g. a mongo.SessionContext to run it inside
+// a transaction.
+func (ub *UpsertBuilder) WithContext(ctx context.Context) *UpsertBuilder {
+	ub.ctx = ctx
+	return ub
+}
+
+// Execute writes the documents as one bulk of upserts and counts the inserted and matched ones.
+func (ub *UpsertBuilder) Execute(db *mongo.Database) (_ *UpsertResult, err error) {
+	defer recoverTo(&err)
+	if ub.Collection == "" {
+		return nil, errors.New("collection name is not specified")
+	}
+	if len(ub.KeyFields) == 0 {
+		return nil, errors.New("upsert requires key fields")
+	}
+	if len(ub.Documents) == 0 {
+		return nil, errors.New("no documents to upsert")
+	}
+	if err := ub.Err(); err != nil {
+		return nil, err
+	}
+	ctx, release, err := trackContext(ub.ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	models, err := ub.models()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	collection := db.Collection(ub.Collection)
+	if ub.deadLetters.sink != nil {
+		return ub.upsertDeadLettered(ctx, collection, models)
+	}
+	res, err := collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(ub.ordered))
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert documents: %w", err)
+	}
+	return &UpsertResult{
+		Inserted:    res.UpsertedCount,
+		Matched:     res.MatchedCount,
+		Modified:    res.ModifiedCount,
+		UpsertedIDs: res.UpsertedIDs,
+	}, nil
+}
+
+// upsertDeadLettered writes the models unordered, handing the documents that fail to the dead
+// letter sink, and counts the documents written.
+func (ub *UpsertBuilder) upsertDeadLettered(ctx context.Context, collection *mongo.Collection, models []mongo.WriteModel) (*UpsertResult, error) {
+	result := &UpsertResult{UpsertedIDs: map[int64]interface{}{}}
+	_, err := ub.deadLetters.deliver(ctx, ub.Collection, ub.Documents, func(positions []int, _ []interface{}) error {
+		batch := make([]mongo.WriteModel, len(positions))
+		for i, position := range positions {
+			batch[i] = models[position]
+		}
+		res, err := collection.BulkWrite(ctx, batch, options.BulkWrite().SetOrdered(false))
+		if res != nil {
+			result.Inserted += res.UpsertedCount
+			result.Matched += res.MatchedCount
+			result.Modified += res.ModifiedCount
+			for i, id := range res.UpsertedIDs {
+				result.UpsertedIDs[int64(positions[i])] = id
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert documents: %w", err)
+	}
+	return result, nil
+}
+
+// models builds a replace, or with Merge an update, of each document filtered on its keys.
+func (ub *UpsertBuilder) models() ([]mongo.WriteModel, error) {
+	models := make([]mongo.WriteModel, 0, len(ub.Documents))
+	for i, doc := range ub.Documents {
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode document %d: %v", i, err)
+		}
+		filter := bson.D{}
+		for _, field := range ub.KeyFields {
+			value, err := bson.Raw(raw).LookupErr(strings.Split(field, ".")...)
+			if err != nil {
+				return nil, fmt.Errorf("document %d has no key field %s", i, field)
+			}
+			filter = append(filter, bson.E{Key: field, Value: value})
+		}
+
+		if ub.merge {
+			models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).
+				SetUpdate(bson.M{"$set": bson.Raw(raw)}).SetUpsert(true))
+		} else {
+			models = append(models, mongo.NewReplaceOneModel().SetFilter(filter).
+				SetReplacement(bson.Raw(raw)).SetUpsert(true))
+		}
+	}
+	return models, nil
+}
@@ -13,7 +13,7 @@ func mapOperatorToMongo(operator string) string {
 		return "$gte"
 	case "<=":
 		return "$lte"
-	case "!=":
+	case "!=", "<>":
 		return "$ne"
 	case "+":
 		return "$add"
@@ -0,0 +1,81 @@
+package builder
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// WhereEq adds a $match stage keeping documents where field equals value. Like the other
+// typed Where methods it takes Go values directly, without parsing a condition string:
+//
+//	qb.WhereEq("status", "active").WhereGt("amount", 1000)
+func (qb *QueryBuilder) WhereEq(field string, value interface{}) *QueryBuilder {
+	return qb.MatchCond(W.Field(field).Eq(value))
+}
+
+// WhereNe adds a $match stage keeping documents where field does not equal value.
+func (qb *QueryBuilder) WhereNe(field string, value interface{}) *QueryBuilder {
+	return qb.MatchCond(W.Field(field).Ne(value))
+}
+
+// WhereGt adds a $match stage keeping documents where field is greater than value.
+func (qb *QueryBuilder) WhereGt(field string, value interface{}) *QueryBuilder {
+	return qb.MatchCond(W.Field(field).Gt(value))
+}
+
+// WhereGte adds a $match stage keeping documents where field is greater than or equal to value.
+func (qb *QueryBuilder) WhereGte(field string, value interface{}) *QueryBuilder {
+	return qb.MatchCond(W.Field(field).Gte(value))
+}
+
+// WhereLt adds a $match stage keeping documents where field is less than value.
+func (qb *QueryBuilder) WhereLt(field string, value interface{}) *QueryBuilder {
+	return qb.MatchCond(W.Field(field).Lt(value))
+}
+
+// WhereLte adds a $match stage keeping documents where field is less than or equal to value.
+func (qb *QueryBuilder) WhereLte(field string, value interface{}) *QueryBuilder {
+	return qb.MatchCond(W.Field(field).Lte(value))
+}
+
+// WhereIn adds a $match stage keeping documents where field equals one of values.
+func (qb *QueryBuilder) WhereIn(field string, values ...interface{}) *QueryBuilder {
+	return qb.MatchCond(W.Field(field).In(values...))
+}
+
+// WhereNotIn adds a $match stage keeping documents where field equals none of values.
+func (qb *QueryBuilder) WhereNotIn(field string, values ...interface{}) *QueryBuilder {
+	return qb.MatchCond(W.Field(field).NotIn(values...))
+}
+
+// WhereBetween adds a $match stage keeping documents where field lies between low and high,
+// inclusive.
+func (qb *QueryBuilder) WhereBetween(field string, low, high interface{}) *QueryBuilder {
+	return qb.MatchCond(W.Field(field).Between(low, high))
+}
+
+// WhereRegex adds a $match stage keeping documents where field matches the regular expression
+// pattern, with $regex options such as "i".
+func (qb *QueryBuilder) WhereRegex(field, pattern, options string) *QueryBuilder {
+	return qb.MatchCond(W.Field(field).Regex(pattern, options))
+}
+
+// WhereNull adds a $match stage keeping documents where field is null, following NullSemantics.
+func (qb *QueryBuilder) WhereNull(field string) *QueryBuilder {
+	return qb.MatchCond(W.Field(field).IsNull())
+}
+
+// WhereNotNull adds a $match stage keeping documents where field is not null, following
+// NullSemantics.
+func (qb *QueryBuilder) WhereNotNull(field string) *QueryBuilder {
+	return qb.MatchCond(W.Field(field).IsNotNull())
+}
+
+// Regex matches documents where the field matches the regular expression pattern, with $regex
+// options such as "i".
+func (f FieldCond) Regex(pattern, options string) Cond {
+	return Cond{build: func(qb *QueryBuilder) bson.M {
+		regex := bson.M{"$regex": pattern}
+		if options != "" {
+			regex["$options"] = options
+		}
+		return bson.M{qb.resolveField(f.name): regex}
+	}}
+}
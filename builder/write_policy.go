@@ -0,0 +1,77 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// ErrPreflightRejected is returned when a write matches more documents than its preflight allows.
+var ErrPreflightRejected = errors.New("write rejected by preflight check")
+
+// writePolicy holds the safety checks applied before multi-document writes.
+type writePolicy struct {
+	escalateAbove  int64
+	preflightAbove int64
+	confirm        func(count int64) bool
+}
+
+// EscalateWriteConcern makes multi-document updates matching more than threshold documents
+// run with majority write concern, so mass writes are not lost on a failover.
+func (ub *UpdateBuilder) EscalateWriteConcern(threshold int64) *UpdateBuilder {
+	ub.escalateAbove = threshold
+	return ub
+}
+
+// EscalateWriteConcern makes multi-document deletes matching more than threshold documents
+// run with majority write concern, so mass deletes are not lost on a failover.
+func (db *DeleteBuilder) EscalateWriteConcern(threshold int64) *DeleteBuilder {
+	db.escalateAbove = threshold
+	return db
+}
+
+// Preflight counts the documents a multi-document update matches before running it. Above
+// threshold the update is refused, unless confirm is given and returns true for the count.
+func (ub *UpdateBuilder) Preflight(threshold int64, confirm func(count int64) bool) *UpdateBuilder {
+	ub.preflightAbove = threshold
+	ub.confirm = confirm
+	return ub
+}
+
+// Preflight counts the documents a multi-document delete matches before running it. Above
+// threshold the delete is refused, unless confirm is given and returns true for the count.
+func (db *DeleteBuilder) Preflight(threshold int64, confirm func(count int64) bool) *DeleteBuilder {
+	db.preflightAbove = threshold
+	db.confirm = confirm
+	return db
+}
+
+// apply runs the preflight check and returns the collection to write to, with majority
+// write concern when the escalation threshold is exceeded.
+func (wp writePolicy) apply(ctx context.Context, collection *mongo.Collection, filter interface{}) (*mongo.Collection, error) {
+	if wp.escalateAbove <= 0 && wp.preflightAbove <= 0 {
+		return collection, nil
+	}
+
+	// Without a preflight only the escalation matters, so counting can stop early
+	opts := options.Count()
+	if wp.preflightAbove <= 0 {
+		opts.SetLimit(wp.escalateAbove + 1)
+	}
+	count, err := collection.CountDocuments(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate matched documents: %v", err)
+	}
+
+	if wp.preflightAbove > 0 && count > wp.preflightAbove && (wp.confirm == nil || !wp.confirm(count)) {
+		return nil, fmt.Errorf("%w: %d documents match, limit is %d", ErrPreflightRejected, count, wp.preflightAbove)
+	}
+	if wp.escalateAbove > 0 && count > wp.escalateAbove {
+		return collection.Clone(options.Collection().SetWriteConcern(writeconcern.Majority()))
+	}
+	return collection, nil
+}
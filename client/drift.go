@@ -0,0 +1,236 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// Defaults of DriftOptions.
+const (
+	defaultDriftInterval   = 5 * time.Minute
+	defaultDriftSampleSize = 100
+)
+
+// DriftAlert reports a field a saved query refers to that disappeared from its collection or
+// started holding another type.
+type DriftAlert struct {
+	Collection string
+	Field      string
+	Queries    []string // Saved queries referring to the field
+	Missing    bool     // The field is in none of the sampled documents, though it was before
+	Types      []string // BSON types of the field in the sample, when one of them is new
+	Known      []string // BSON types of the field in earlier samples
+}
+
+// String describes the alert, e.g. `orders.amount changed type to [string] from [64-bit integer], used by totals`.
+func (a DriftAlert) String() string {
+	field := a.Collection + "." + a.Field
+	used := strings.Join(a.Queries, ", ")
+	if a.Missing {
+		return fmt.Sprintf("%s disappeared, used by %s", field, used)
+	}
+	return fmt.Sprintf("%s changed type to %v from %v, used by %s", field, a.Types, a.Known, used)
+}
+
+// DriftOptions configures a DriftDetector.
+type DriftOptions struct {
+	Interval   time.Duration    // Time between the checks of Run, defaultDriftInterval when 0
+	SampleSize int              // Documents sampled per collection, defaultDriftSampleSize when 0
+	OnDrift    func(DriftAlert) // Called with every drift found
+}
+
+// DriftDetector samples the collections of the saved queries and reports the fields the queries
+// refer to that disappear or change type, so a report breaks loudly instead of silently showing
+// empty results. The first check of a field learns its types; saved queries with parameters,
+// only known when Query is called, are not checked.
+type DriftDetector struct {
+	m    *MongoDB
+	opts DriftOptions
+
+	mu     sync.Mutex
+	fields map[string]*fieldState // Known state by "collection.field"
+}
+
+// fieldState is what earlier samples showed of a field.
+type fieldState struct {
+	types   map[string]bool
+	missing bool
+}
+
+// NewDriftDetector returns a detector of drift in the fields of the client's saved queries.
+func (m *MongoDB) NewDriftDetector(opts DriftOptions) *DriftDetector {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultDriftInterval
+	}
+	if opts.SampleSize <= 0 {
+		opts.SampleSize = defaultDriftSampleSize
+	}
+	return &DriftDetector{m: m, opts: opts, fields: map[string]*fieldState{}}
+}
+
+// Run checks for drift every Interval until ctx ends, and returns the context's error. Errors of
+// a check, such as an unreachable server, do not stop it.
+func (d *DriftDetector) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.opts.Interval)
+	defer ticker.Stop()
+	for {
+		d.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check samples each collection once and calls OnDrift with the drift found. Empty collections
+// are skipped, since they tell nothing about their fields.
+func (d *DriftDetector) Check(ctx context.Context) error {
+	references, err := d.references()
+	if err != nil {
+		return err
+	}
+	errs := []error{}
+	alerts := []DriftAlert{}
+	for _, collection := range slices.Sorted(maps.Keys(references)) {
+		samples, err := d.sample(ctx, collection)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to sample collection %s: %v", collection, err))
+			continue
+		}
+		if len(samples) == 0 {
+			continue
+		}
+		for _, field := range slices.Sorted(maps.Keys(references[collection])) {
+			types := map[string]bool{}
+			for _, doc := range samples {
+				addFieldTypes(doc, strings.Split(field, "."), types)
+			}
+			if alert, ok := d.update(collection, field, types); ok {
+				alert.Queries = references[collection][field]
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+	if d.opts.OnDrift != nil {
+		for _, alert := range alerts {
+			d.opts.OnDrift(alert)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// references returns the names of the saved queries referring to each field, by collection.
+func (d *DriftDetector) references() (map[string]map[string][]string, error) {
+	d.m.mu.Lock()
+	names := make([]string, 0, len(d.m.queries))
+	for name, query := range d.m.queries {
+		if !hasParameters(query.sql) {
+			names = append(names, name)
+		}
+	}
+	d.m.mu.Unlock()
+	slices.Sort(names)
+
+	references := map[string]map[string][]string{}
+	for _, name := range names {
+		qb, err := d.m.Query(name)
+		if err != nil {
+			return nil, err
+		}
+		collection, fields := qb.ReferencedFields()
+		if references[collection] == nil {
+			references[collection] = map[string][]string{}
+		}
+		for _, field := range fields {
+			references[collection][field] = append(references[collection][field], name)
+		}
+	}
+	return references, nil
+}
+
+// sample returns up to SampleSize random documents of collection.
+func (d *DriftDetector) sample(ctx context.Context, collection string) ([]bson.Raw, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	pipeline := []bson.D{{{Key: "$sample", Value: bson.M{"size": d.opts.SampleSize}}}}
+	cursor, err := d.m.Database.Collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	samples := []bson.Raw{}
+	if err := cursor.All(ctx, &samples); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// update records the types of a field in the latest sample and returns the alert they raise,
+// if any: the first sample is the reference, a field is reported missing once until it comes
+// back, and each new type is reported once.
+func (d *DriftDetector) update(collection, field string, types map[string]bool) (DriftAlert, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := collection + "." + field
+	state, known := d.fields[key]
+	if !known {
+		d.fields[key] = &fieldState{types: types, missing: len(types) == 0}
+		return DriftAlert{}, false
+	}
+
+	alert := DriftAlert{Collection: collection, Field: field, Known: slices.Sorted(maps.Keys(state.types))}
+	missing := len(types) == 0
+	wasMissing := state.missing
+	state.missing = missing
+	if missing {
+		alert.Missing = true
+		return alert, !wasMissing && len(state.types) > 0
+	}
+
+	changed := false
+	for t := range types {
+		if !state.types[t] && t != bsontype.Null.String() {
+			changed = true
+		}
+	}
+	maps.Copy(state.types, types)
+	if !changed || len(alert.Known) == 0 {
+		return DriftAlert{}, false
+	}
+	alert.Types = slices.Sorted(maps.Keys(types))
+	return alert, true
+}
+
+// addFieldTypes adds the BSON types of the value at path in doc to types, looking into each
+// document of arrays along the path.
+func addFieldTypes(doc bson.Raw, path []string, types map[string]bool) {
+	value, err := doc.LookupErr(path[0])
+	if err != nil {
+		return
+	}
+	if len(path) == 1 {
+		types[value.Type.String()] = true
+		return
+	}
+	switch value.Type {
+	case bsontype.EmbeddedDocument:
+		addFieldTypes(value.Document(), path[1:], types)
+	case bsontype.Array:
+		items, _ := value.Array().Values()
+		for _, item := range items {
+			if item.Type == bsontype.EmbeddedDocument {
+				addFieldTypes(item.Document(), path[1:], types)
+			}
+		}
+	}
+}
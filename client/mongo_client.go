@@ -2,8 +2,10 @@ package client
 
 import (
 	"context"
+	"sync"
 	"time"
 
+	"github.com/brothergiez/mongoquery/builder"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -12,6 +14,9 @@ import (
 type MongoDB struct {
 	Client   *mongo.Client
 	Database *mongo.Database
+
+	mu      sync.Mutex
+	queries map[string]*savedQuery // Saved queries by name, see RegisterQuery
 }
 
 // New initializes a new MongoDB client and connects to the specified database.
@@ -29,3 +34,17 @@ func New(uri, database string) (*MongoDB, error) {
 		Database: client.Database(database),
 	}, nil
 }
+
+// Shutdown stops new operations of the builders against the client, waits for the in-flight
+// ones until ctx ends, cancels the remaining ones and disconnects. It returns the context's
+// error when operations had to be cancelled.
+func (m *MongoDB) Shutdown(ctx context.Context) error {
+	drainErr := builder.Drain(ctx, m.Client)
+
+	disconnectCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := m.Client.Disconnect(disconnectCtx); err != nil {
+		return err
+	}
+	return drainErr
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/brothergiez/mongoquery/builder"
+	"github.com/brothergiez/mongoquery/parser"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultWarmupConnections is the number of connections Warmup opens by default.
+const defaultWarmupConnections = 4
+
+// savedQuery is a SQL query registered under a name, with its parse result once parsed.
+type savedQuery struct {
+	sql    string
+	parsed *builder.QueryBuilder
+}
+
+// WarmupOptions configures WarmupWith.
+type WarmupOptions struct {
+	Connections int  // Connections to open concurrently, defaultWarmupConnections when 0
+	Explain     bool // Explain the saved queries, so the server plans them before traffic arrives
+}
+
+// RegisterQuery saves a SQL query under name, to be parsed by Warmup and built with Query.
+func (m *MongoDB) RegisterQuery(name, sql string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.queries == nil {
+		m.queries = map[string]*savedQuery{}
+	}
+	m.queries[name] = &savedQuery{sql: sql}
+}
+
+// Query returns a builder for the saved query, with args bound to its placeholders. Queries
+// without arguments are parsed once, by Warmup or the first call, and cloned afterwards.
+func (m *MongoDB) Query(name string, args ...interface{}) (*builder.QueryBuilder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	query, ok := m.queries[name]
+	if !ok {
+		return nil, fmt.Errorf("no saved query %s", name)
+	}
+	if len(args) > 0 || strings.Contains(strings.ToUpper(query.sql), "NOW(") {
+		// NOW() is evaluated while parsing, so such queries are not cached either
+		return parser.NewSQLParser(query.sql).Bind(args...).ParseSQL()
+	}
+	if query.parsed == nil {
+		qb, err := parser.NewSQLParser(query.sql).ParseSQL()
+		if err != nil {
+			return nil, fmt.Errorf("saved query %s: %v", name, err)
+		}
+		query.parsed = qb
+	}
+	return query.parsed.Clone(), nil
+}
+
+// Warmup prepares the client before traffic shifts to it, e.g. during a deploy, using the
+// default WarmupOptions.
+func (m *MongoDB) Warmup(ctx context.Context, collections ...string) error {
+	return m.WarmupWith(ctx, WarmupOptions{}, collections...)
+}
+
+// WarmupWith opens connections to the server, reads a document of each collection to load
+// its metadata, parses the saved queries and optionally explains them.
+func (m *MongoDB) WarmupWith(ctx context.Context, opts WarmupOptions, collections ...string) error {
+	if err := m.openConnections(ctx, opts.Connections); err != nil {
+		return err
+	}
+
+	for _, collection := range collections {
+		err := m.Database.Collection(collection).FindOne(ctx, bson.D{}, options.FindOne().SetProjection(bson.M{"_id": 1})).Err()
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("failed to warm up collection %s: %v", collection, err)
+		}
+	}
+
+	m.mu.Lock()
+	names := make([]string, 0, len(m.queries))
+	for name, query := range m.queries {
+		if !hasParameters(query.sql) {
+			names = append(names, name)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, name := range names {
+		qb, err := m.Query(name)
+		if err != nil {
+			return err
+		}
+		if opts.Explain {
+			if _, err := qb.WithContext(ctx).Explain(m.Database, "queryPlanner"); err != nil {
+				return fmt.Errorf("saved query %s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// openConnections pings the server from n goroutines at once, so the pool opens n connections.
+func (m *MongoDB) openConnections(ctx context.Context, n int) error {
+	if n <= 0 {
+		n = defaultWarmupConnections
+	}
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = m.Client.Ping(ctx, nil)
+		}(i)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to open connections: %v", err)
+	}
+	return nil
+}
+
+// hasParameters reports whether a saved query reads placeholders or variables, which are only
+// known when Query is called.
+func hasParameters(sql string) bool {
+	return parser.HasParameters(sql)
+}
@@ -0,0 +1,86 @@
+// Command mongoquery-gen generates typed Go functions from .sql files of named queries, see
+// package codegen. Arguments are .sql files, directories or glob patterns, "*.sql" by default:
+//
+//	//go:generate go run github.com/brothergiez/mongoquery/cmd/mongoquery-gen -out queries_gen.go queries
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brothergiez/mongoquery/codegen"
+)
+
+func main() {
+	out := flag.String("out", "queries_gen.go", "file to write the generated code to")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package of the generated code, the directory of -out by default")
+	flag.Parse()
+
+	if err := run(*out, *pkg, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "mongoquery-gen:", err)
+		os.Exit(1)
+	}
+}
+
+// run generates the code of the queries in the .sql files matched by args into out.
+func run(out, pkg string, args []string) error {
+	if len(args) == 0 {
+		args = []string{"*.sql"}
+	}
+	if pkg == "" {
+		dir, err := filepath.Abs(filepath.Dir(out))
+		if err != nil {
+			return err
+		}
+		pkg = strings.ReplaceAll(filepath.Base(dir), "-", "_")
+	}
+
+	files, err := sqlFiles(args)
+	if err != nil {
+		return err
+	}
+	queries := []codegen.Query{}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		fileQueries, err := codegen.ParseFile(file, src)
+		if err != nil {
+			return err
+		}
+		queries = append(queries, fileQueries...)
+	}
+	if len(queries) == 0 {
+		return fmt.Errorf("no queries found in %s", strings.Join(args, " "))
+	}
+
+	code, err := codegen.Generate(pkg, queries)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(out, code, 0o644)
+}
+
+// sqlFiles expands the arguments into .sql files: directories to the .sql files they contain
+// and patterns to their matches.
+func sqlFiles(args []string) ([]string, error) {
+	files := []string{}
+	for _, arg := range args {
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			arg = filepath.Join(arg, "*.sql")
+		}
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no .sql files match %s", arg)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
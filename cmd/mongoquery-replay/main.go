@@ -0,0 +1,111 @@
+// Command mongoquery-replay replays a query log written by builder.QueryLogger against a target
+// database and compares the latencies with the logged ones, for capacity testing and upgrade
+// validation. Arguments are log files, standard input by default:
+//
+//	mongoquery-replay -uri mongodb://staging:27017 -db shop -speed 2 queries.log
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/brothergiez/mongoquery/builder"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func main() {
+	uri := flag.String("uri", "mongodb://localhost:27017", "URI of the target deployment")
+	database := flag.String("db", "", "database to run the queries against")
+	speed := flag.Float64("speed", 1, "replay rate relative to the logged one, 0 to replay as fast as possible")
+	concurrency := flag.Int("concurrency", 32, "maximum number of queries in flight")
+	timeout := flag.Duration("timeout", 30*time.Second, "time limit of each query")
+	flag.Parse()
+
+	if err := run(*uri, *database, *speed, *concurrency, *timeout, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "mongoquery-replay:", err)
+		os.Exit(1)
+	}
+}
+
+// run replays the queries of the logs in args against database and prints the report.
+func run(uri, database string, speed float64, concurrency int, timeout time.Duration, args []string) error {
+	if database == "" {
+		return fmt.Errorf("-db is required")
+	}
+	if speed < 0 || concurrency < 1 {
+		return fmt.Errorf("-speed must not be negative and -concurrency must be positive")
+	}
+	entries, err := readLogs(args)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no queries to replay")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+
+	results := replay(client.Database(database), entries, speed, concurrency, timeout)
+	return writeReport(os.Stdout, entries, results)
+}
+
+// readLogs reads the entries of the log files, or of standard input without files, in the order
+// they were logged.
+func readLogs(files []string) ([]builder.QueryLogEntry, error) {
+	if len(files) == 0 {
+		return readLog(os.Stdin, "stdin")
+	}
+	entries := []builder.QueryLogEntry{}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		fileEntries, err := readLog(f, file)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries, nil
+}
+
+// readLog reads the entries of one log. Failed executions and entries logged without their
+// pipeline are skipped, since their original latency means nothing.
+func readLog(r io.Reader, name string) ([]builder.QueryLogEntry, error) {
+	entries := []builder.QueryLogEntry{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry builder.QueryLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, line, err)
+		}
+		if entry.Error == "" && entry.Pipeline != nil {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brothergiez/mongoquery/builder"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// result is the outcome of replaying one entry.
+type result struct {
+	duration time.Duration
+	err      error
+}
+
+// replay runs the entries against db, each at its logged offset from the first one divided by
+// speed, and returns their results in the order of entries. At most concurrency queries run at
+// once; a query waiting for a slot starts late rather than being dropped.
+func replay(db *mongo.Database, entries []builder.QueryLogEntry, speed float64, concurrency int, timeout time.Duration) []result {
+	results := make([]result, len(entries))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	start, first := time.Now(), entries[0].Time
+	for i, entry := range entries {
+		if speed > 0 {
+			offset := time.Duration(float64(entry.Time.Sub(first)) / speed)
+			time.Sleep(time.Until(start.Add(offset)))
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			results[i] = replayEntry(db, entry, timeout)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// replayEntry executes the pipeline of one entry and reads all its documents.
+func replayEntry(db *mongo.Database, entry builder.QueryLogEntry, timeout time.Duration) result {
+	pipeline, err := entry.Stages()
+	if err != nil {
+		return result{err: err}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	cursor, err := db.Collection(entry.Collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return result{duration: time.Since(start), err: err}
+	}
+	defer cursor.Close(ctx)
+	var documents []bson.Raw
+	err = cursor.All(ctx, &documents)
+	return result{duration: time.Since(start), err: err}
+}
+
+// shape holds the latencies of the entries of one fingerprint.
+type shape struct {
+	collection  string
+	fingerprint string
+	logged      []time.Duration
+	replayed    []time.Duration
+	errors      int
+}
+
+// writeReport writes the logged and replayed latency percentiles of each fingerprint, the most
+// executed first, followed by the totals.
+func writeReport(w io.Writer, entries []builder.QueryLogEntry, results []result) error {
+	shapes := map[string]*shape{}
+	total := &shape{collection: "*", fingerprint: "total"}
+	for i, entry := range entries {
+		s, ok := shapes[entry.Fingerprint]
+		if !ok {
+			s = &shape{collection: entry.Collection, fingerprint: entry.Fingerprint}
+			shapes[entry.Fingerprint] = s
+		}
+		logged := time.Duration(entry.DurationMS * float64(time.Millisecond))
+		for _, s := range []*shape{s, total} {
+			s.logged = append(s.logged, logged)
+			if results[i].err != nil {
+				s.errors++
+			} else {
+				s.replayed = append(s.replayed, results[i].duration)
+			}
+		}
+	}
+	sorted := make([]*shape, 0, len(shapes))
+	for _, s := range shapes {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i].logged) != len(sorted[j].logged) {
+			return len(sorted[i].logged) > len(sorted[j].logged)
+		}
+		return sorted[i].fingerprint < sorted[j].fingerprint
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "COLLECTION\tFINGERPRINT\tQUERIES\tERRORS\tLOGGED P50\tREPLAYED P50\tLOGGED P90\tREPLAYED P90\tLOGGED P99\tREPLAYED P99\t")
+	for _, s := range append(sorted, total) {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d", s.collection, s.fingerprint, len(s.logged), s.errors)
+		for _, p := range []float64{0.5, 0.9, 0.99} {
+			fmt.Fprintf(tw, "\t%s\t%s", percentile(s.logged, p), percentile(s.replayed, p))
+		}
+		fmt.Fprintln(tw, "\t")
+	}
+	return tw.Flush()
+}
+
+// percentile returns the p-th percentile of the durations by the nearest-rank method, "-" when
+// there are none.
+func percentile(durations []time.Duration, p float64) string {
+	if len(durations) == 0 {
+		return "-"
+	}
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)].Round(10 * time.Microsecond).String()
+}
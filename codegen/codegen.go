@@ -0,0 +1,376 @@
+// Package codegen generates typed Go functions from .sql files of named queries, in the manner
+// of sqlc. Each query is parsed when generating, so a broken query fails the build instead of
+// the first request. The command is cmd/mongoquery-gen.
+//
+// A .sql file holds queries introduced by a name comment, with optional comments declaring
+// the types of the "?" parameters, in order, and of the result fields:
+//
+//	-- name: OrderTotals
+//	-- Totals per status of the orders above a minimum amount.
+//	-- param: minAmount int64
+//	-- result: total float64
+//	SELECT status, SUM(amount) AS total FROM orders WHERE amount > ? GROUP BY status;
+//
+// which generates
+//
+//	type OrderTotalsRow struct {
+//		Status interface{} `bson:"status"`
+//		Total  float64     `bson:"total"`
+//	}
+//
+//	func OrderTotals(ctx context.Context, db *mongo.Database, minAmount int64) ([]OrderTotalsRow, error)
+//
+// Undeclared parameters are named arg1, arg2, ... and, like undeclared result fields, typed
+// interface{}. Rows of SELECT * without result comments are bson.M.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/brothergiez/mongoquery/parser"
+)
+
+// Param is a parameter of a generated function, bound to a "?" placeholder.
+type Param struct {
+	Name string
+	Type string
+}
+
+// Field is a field of a generated row type.
+type Field struct {
+	Name string // Go field name
+	Key  string // Key of the field in the result documents
+	Type string
+}
+
+// Query is a named query of a .sql file.
+type Query struct {
+	Name   string
+	Doc    []string // Lines of the comments describing the query
+	SQL    string
+	Params []Param
+	Fields []Field // Fields of the row type, nil for rows decoded as bson.M
+	Pos    string  // File and line of the query, for messages
+}
+
+// directive matches the "-- name: X", "-- param: name type" and "-- result: key type" comments.
+var directive = regexp.MustCompile(`^--\s*(name|param|result):\s*(.*)$`)
+
+// packages maps the package qualifiers allowed in parameter and result types to their imports.
+var packages = map[string]string{
+	"time":      "time",
+	"bson":      "go.mongodb.org/mongo-driver/bson",
+	"primitive": "go.mongodb.org/mongo-driver/bson/primitive",
+}
+
+// ParseFile reads the named queries of a .sql file and checks them with the SQL parser.
+func ParseFile(name string, src []byte) ([]Query, error) {
+	var queries []Query
+	var current *Query
+	var sql strings.Builder
+	results := map[string]string{}
+
+	finish := func() error {
+		if current == nil {
+			return nil
+		}
+		current.SQL = strings.TrimSuffix(strings.TrimSpace(sql.String()), ";")
+		if err := current.check(results); err != nil {
+			return fmt.Errorf("%s: %s: %v", current.Pos, current.Name, err)
+		}
+		queries = append(queries, *current)
+		current, results = nil, map[string]string{}
+		sql.Reset()
+		return nil
+	}
+
+	for i, line := range strings.Split(string(src), "\n") {
+		pos := fmt.Sprintf("%s:%d", name, i+1)
+		trimmed := strings.TrimSpace(line)
+		matches := directive.FindStringSubmatch(trimmed)
+		switch {
+		case matches != nil && matches[1] == "name":
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			if !isExported(matches[2]) {
+				return nil, fmt.Errorf("%s: query name %q is not an exported Go identifier", pos, matches[2])
+			}
+			current = &Query{Name: matches[2], Pos: pos}
+		case current == nil:
+			if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				return nil, fmt.Errorf("%s: statement without a -- name: comment", pos)
+			}
+		case matches != nil:
+			parts := strings.Fields(matches[2])
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("%s: expected -- %s: <name> <type>", pos, matches[1])
+			}
+			if err := checkType(parts[1]); err != nil {
+				return nil, fmt.Errorf("%s: %v", pos, err)
+			}
+			if matches[1] == "param" {
+				if !token.IsIdentifier(parts[0]) {
+					return nil, fmt.Errorf("%s: parameter name %q is not a Go identifier", pos, parts[0])
+				}
+				current.Params = append(current.Params, Param{Name: parts[0], Type: parts[1]})
+			} else {
+				results[parts[0]] = parts[1]
+			}
+		case strings.HasPrefix(trimmed, "--") && sql.Len() == 0:
+			current.Doc = append(current.Doc, strings.TrimSpace(strings.TrimPrefix(trimmed, "--")))
+		case strings.HasPrefix(trimmed, "--"):
+			// Skip comments inside the statement
+		default:
+			sql.WriteString(line + "\n")
+		}
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+// check parses the query, names its undeclared parameters and derives its row fields.
+func (q *Query) check(results map[string]string) error {
+	if q.SQL == "" {
+		return fmt.Errorf("query has no statement")
+	}
+	count := countPlaceholders(q.SQL)
+	if len(q.Params) > count {
+		return fmt.Errorf("%d parameters declared for %d placeholders", len(q.Params), count)
+	}
+	for i := len(q.Params); i < count; i++ {
+		q.Params = append(q.Params, Param{Name: fmt.Sprintf("arg%d", i+1), Type: "interface{}"})
+	}
+
+	// Placeholders are bound to 1, which also passes for LIMIT and OFFSET
+	args := make([]interface{}, count)
+	for i := range args {
+		args[i] = int64(1)
+	}
+	qb, err := parser.NewSQLParser(q.SQL).Bind(args...).ParseSQL()
+	if err != nil {
+		return err
+	}
+	if qb.Collection == "" {
+		return fmt.Errorf("query has no FROM collection")
+	}
+
+	keys := []string{}
+	star := false
+	for _, field := range qb.Fields {
+		if field == "" {
+			return fmt.Errorf("query has an empty select field")
+		}
+		if field == "*" {
+			star = true
+			continue
+		}
+		keys = append(keys, qb.ResultKey(field))
+	}
+	if star {
+		if len(results) == 0 {
+			return nil // Rows decode as bson.M
+		}
+		declared := []string{}
+		for key := range results {
+			if !slices.Contains(keys, key) {
+				declared = append(declared, key)
+			}
+		}
+		sort.Strings(declared)
+		keys = append(keys, declared...)
+	}
+
+	names := map[string]string{}
+	for _, key := range keys {
+		if strings.Contains(key, ".") {
+			return fmt.Errorf("result field %s is nested, select it with an alias like %s AS %s", key, key, key[strings.LastIndex(key, ".")+1:])
+		}
+		name := goName(key)
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("result fields %s and %s both map to the Go field %s", other, key, name)
+		}
+		names[name] = key
+		fieldType := "interface{}"
+		if declared, ok := results[key]; ok {
+			fieldType = declared
+		}
+		q.Fields = append(q.Fields, Field{Name: name, Key: key, Type: fieldType})
+	}
+	for key := range results {
+		if !slices.Contains(keys, key) {
+			return fmt.Errorf("result %s is not selected", key)
+		}
+	}
+	return nil
+}
+
+// Generate returns the formatted Go source of package pkg with a row type and a function per query.
+func Generate(pkg string, queries []Query) ([]byte, error) {
+	seen := map[string]string{}
+	imports := map[string]bool{"context": true, "github.com/brothergiez/mongoquery/builder": true,
+		"github.com/brothergiez/mongoquery/parser": true, "go.mongodb.org/mongo-driver/mongo": true}
+	for _, q := range queries {
+		if pos, ok := seen[q.Name]; ok {
+			return nil, fmt.Errorf("%s: query %s is already defined at %s", q.Pos, q.Name, pos)
+		}
+		seen[q.Name] = q.Pos
+		types := []string{}
+		for _, param := range q.Params {
+			types = append(types, param.Type)
+		}
+		for _, field := range q.Fields {
+			types = append(types, field.Type)
+		}
+		if q.Fields == nil {
+			types = append(types, "bson.M")
+		}
+		for _, t := range types {
+			if qualifier, _, ok := strings.Cut(strings.TrimLeft(t, "*[]"), "."); ok {
+				imports[packages[qualifier]] = true
+			}
+		}
+	}
+
+	// Standard library imports go first, as goimports groups them
+	std, other := []string{}, []string{}
+	for path := range imports {
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+
+	var out bytes.Buffer
+	err := file.Execute(&out, struct {
+		Package string
+		Std     []string
+		Imports []string
+		Queries []Query
+	}{pkg, std, other, queries})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(out.Bytes())
+}
+
+// file is the template of a generated file.
+var file = template.Must(template.New("file").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+	"lower": func(name string) string { return strings.ToLower(name[:1]) + name[1:] },
+}).Parse(`// Code generated by mongoquery-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .Std}}
+	"{{.}}"
+{{- end}}
+{{range .Imports}}
+	"{{.}}"
+{{- end}}
+)
+{{range .Queries}}
+{{- $q := .}}
+{{- if .Fields}}
+// {{.Name}}Row is a row of {{.Name}}.
+type {{.Name}}Row struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}} ` + "`" + `bson:"{{.Key}}"` + "`" + `
+{{- end}}
+}
+{{else}}
+// {{.Name}}Row is a row of {{.Name}}.
+type {{.Name}}Row = bson.M
+{{end}}
+// {{lower .Name}}SQL is the statement of {{.Name}}, from {{.Pos}}.
+const {{lower .Name}}SQL = {{quote .SQL}}
+
+// {{.Name}} runs the query of {{.Pos}}.
+{{- range .Doc}}
+// {{.}}
+{{- end}}
+func {{.Name}}(ctx context.Context, db *mongo.Database{{range .Params}}, {{.Name}} {{.Type}}{{end}}) ([]{{.Name}}Row, error) {
+	qb, err := parser.NewSQLParser({{lower .Name}}SQL).Bind({{range $i, $p := .Params}}{{if $i}}, {{end}}{{$p.Name}}{{end}}).ParseSQL()
+	if err != nil {
+		return nil, err
+	}
+	return builder.ExecuteAs[{{$q.Name}}Row](ctx, db, qb)
+}
+{{end}}`))
+
+// checkType checks that a declared type only uses packages the generated file can import.
+func checkType(t string) error {
+	if qualifier, _, ok := strings.Cut(strings.TrimLeft(t, "*[]"), "."); ok {
+		if _, known := packages[qualifier]; !known {
+			return fmt.Errorf("type %s uses an unsupported package %s", t, qualifier)
+		}
+	}
+	return nil
+}
+
+// countPlaceholders counts the "?" placeholders outside quoted strings and names.
+func countPlaceholders(sql string) int {
+	count := 0
+	var quote rune
+	for _, c := range sql {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			count++
+		}
+	}
+	return count
+}
+
+// initialisms are the words Go names spell in capitals, like ID in OrderID.
+var initialisms = map[string]bool{"id": true, "url": true, "api": true, "json": true, "http": true, "ip": true}
+
+// goName converts a result key like "total_amount", "_id" or "COUNT(*)" to an exported Go
+// name: TotalAmount, ID and Count.
+func goName(key string) string {
+	words := strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var name strings.Builder
+	for _, word := range words {
+		if strings.ToUpper(word) == word || initialisms[strings.ToLower(word)] {
+			word = strings.ToLower(word) // COUNT becomes Count
+		}
+		if initialisms[word] {
+			name.WriteString(strings.ToUpper(word))
+			continue
+		}
+		runes := []rune(word)
+		name.WriteString(string(unicode.ToUpper(runes[0])) + string(runes[1:]))
+	}
+	if name.Len() == 0 || !unicode.IsLetter([]rune(name.String())[0]) {
+		return "F" + name.String()
+	}
+	return name.String()
+}
+
+// isExported reports whether name is an exported Go identifier.
+func isExported(name string) bool {
+	return token.IsIdentifier(name) && token.IsExported(name)
+}
@@ -0,0 +1,76 @@
+// Package metrics exports the query stats of the builder package as Prometheus metrics.
+package metrics
+
+import (
+	"github.com/brothergiez/mongoquery/builder"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// labels of every metric: the collection and the query fingerprint.
+var labels = []string{"collection", "fingerprint"}
+
+// Collector is a prometheus.Collector of per-collection and per-fingerprint query metrics.
+//
+//	collector := metrics.NewCollector("mongoquery")
+//	prometheus.MustRegister(collector)
+//	builder.SetQueryObserver(collector.Observe)
+type Collector struct {
+	executions *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	documents  *prometheus.CounterVec
+	duration   *prometheus.SummaryVec
+}
+
+// NewCollector creates a collector whose metric names start with namespace.
+func NewCollector(namespace string) *Collector {
+	return &Collector{
+		executions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "query_executions_total",
+			Help:      "Number of query executions.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "query_errors_total",
+			Help:      "Number of query executions that failed.",
+		}, labels),
+		documents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "query_documents_total",
+			Help:      "Number of documents returned by queries.",
+		}, labels),
+		duration: prometheus.NewSummaryVec(prometheus.SummaryOpts{
+			Namespace:  namespace,
+			Name:       "query_duration_seconds",
+			Help:       "Duration of query executions.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		}, labels),
+	}
+}
+
+// Observe records one query execution. It is a builder.QueryObserver.
+func (c *Collector) Observe(stats builder.QueryStats) {
+	values := []string{stats.Collection, stats.Fingerprint}
+	c.executions.WithLabelValues(values...).Inc()
+	if stats.Err != nil {
+		c.errors.WithLabelValues(values...).Inc()
+	}
+	c.documents.WithLabelValues(values...).Add(float64(stats.Documents))
+	c.duration.WithLabelValues(values...).Observe(stats.Duration.Seconds())
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.executions.Describe(ch)
+	c.errors.Describe(ch)
+	c.documents.Describe(ch)
+	c.duration.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.executions.Collect(ch)
+	c.errors.Collect(ch)
+	c.documents.Collect(ch)
+	c.duration.Collect(ch)
+}
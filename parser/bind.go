@@ -0,0 +1,209 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/brothergiez/mongoquery/builder"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// bindMarker matches the token a "?" placeholder is replaced with while parsing.
+var bindMarker = regexp.MustCompile(`^\$?__mq_bind_(\d+)__$`)
+
+// Bind sets the values for the "?" placeholders of the query, in order. Bound values are
+// substituted into the parsed filters as-is and never pass through the SQL lexer, e.g.
+// NewSQLParser("SELECT * FROM users WHERE age > ? AND status = ?").Bind(30, "active").
+func (sp *SQLParser) Bind(args ...interface{}) *SQLParser {
+	sp.args = args
+	return sp
+}
+
+// HasParameters reports whether query reads "?" placeholders or "@variables" outside quoted
+// strings, so it can only be parsed once their values are known.
+func HasParameters(query string) bool {
+	for i := 0; i < len(query); i++ {
+		switch {
+		case isQuote(query[i]):
+			i = skipQuoted(query, i)
+		case query[i] == '?':
+			return true
+		case query[i] == '@' && variableReference.MatchString(query[i:]) && !intoTarget(query, i):
+			return true
+		}
+	}
+	return false
+}
+
+// markPlaceholders replaces each "?" and "@variable" outside quoted strings with a numbered
+// marker token and checks that every placeholder has a bound value and every variable is set.
+func (sp *SQLParser) markPlaceholders(query string) (string, error) {
+	if sp.marked {
+		return query, nil
+	}
+	var marked strings.Builder
+	count := 0
+	variables := []interface{}{}
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if isQuote(c) {
+			end := min(skipQuoted(query, i), len(query)-1)
+			marked.WriteString(query[i : end+1])
+			i = end
+			continue
+		}
+		if c == '?' {
+			marked.WriteString(" __mq_bind_" + strconv.Itoa(count) + "__ ")
+			count++
+			continue
+		}
+		if c == '@' {
+			marker, size, err := sp.markVariable(query, i, &variables)
+			if err != nil {
+				return "", err
+			}
+			marked.WriteString(marker)
+			i += size - 1
+			continue
+		}
+		marked.WriteByte(c)
+	}
+	if count != len(sp.args) {
+		return "", fmt.Errorf("query has %d placeholders but %d values are bound", count, len(sp.args))
+	}
+	sp.args = append(append([]interface{}{}, sp.args...), variables...)
+	return marked.String(), nil
+}
+
+// markerToken matches a marker token anywhere in a condition.
+var markerToken = regexp.MustCompile(`__mq_bind_(\d+)__`)
+
+// boundCondition rewrites the marker tokens of a condition into named placeholders of the
+// builder and returns the Params binding them, so bound values are parsed as values and build
+// the same filters as literals: "age > ?" gives {age: {$gt: 30}}, not an $expr.
+func (sp *SQLParser) boundCondition(condition string) (string, builder.Params) {
+	params := builder.Params{}
+	condition = markerToken.ReplaceAllStringFunc(condition, func(marker string) string {
+		index, _ := strconv.Atoi(markerToken.FindStringSubmatch(marker)[1])
+		if index >= len(sp.args) {
+			return marker
+		}
+		name := "mq_bind_" + strconv.Itoa(index)
+		params[name] = normalizeArg(sp.args[index])
+		return ":" + name
+	})
+	return condition, params
+}
+
+// boundValue returns the value bound to a marker token.
+func (sp *SQLParser) boundValue(token string) (interface{}, bool) {
+	matches := bindMarker.FindStringSubmatch(strings.TrimSpace(token))
+	if matches == nil {
+		return nil, false
+	}
+	index, _ := strconv.Atoi(matches[1])
+	if index >= len(sp.args) {
+		return nil, false
+	}
+	return normalizeArg(sp.args[index]), true
+}
+
+// bindValues replaces the marker tokens left in a parsed document with their bound values.
+// Markers that ended up as "$field" references inside expressions are bound as $literal.
+func (sp *SQLParser) bindValues(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if bound, ok := sp.boundValue(v); ok {
+			if strings.HasPrefix(v, "$") {
+				return bson.M{"$literal": bound}
+			}
+			return bound
+		}
+		return v
+	case bson.M:
+		for key, item := range v {
+			if key == "$regex" {
+				// LIKE patterns are bound as patterns, not as regular expressions
+				if bound, ok := sp.boundValue(fmt.Sprint(item)); ok {
+					v[key] = builder.LikeToRegex(fmt.Sprint(bound))
+					continue
+				}
+			}
+			v[key] = sp.bindValues(item)
+		}
+		return v
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = sp.bindValues(item)
+		}
+		return v
+	case bson.D:
+		for i := range v {
+			v[i].Value = sp.bindValues(v[i].Value)
+		}
+		return v
+	case []bson.D:
+		for i := range v {
+			v[i] = sp.bindValues(v[i]).(bson.D)
+		}
+		return v
+	case []bson.M:
+		for i := range v {
+			v[i] = sp.bindValues(v[i]).(bson.M)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = sp.bindValues(v[i])
+		}
+		return v
+	}
+	return value
+}
+
+// normalizeArg converts bound Go values into types BSON can store.
+func normalizeArg(arg interface{}) interface{} {
+	switch v := arg.(type) {
+	case uint:
+		return int64(v)
+	case uint8:
+		return int32(v)
+	case uint16:
+		return int32(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		if v <= 1<<63-1 {
+			return int64(v)
+		}
+	}
+	return arg
+}
+
+// toInt64 converts a bound value used as LIMIT or OFFSET into an integer.
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/brothergiez/mongoquery/builder"
+)
+
+// deleteStatement matches "DELETE FROM collection ...".
+var deleteStatement = regexp.MustCompile(`(?is)^DELETE\s+FROM\s+(` + identifierPattern + `)\s*(.*)$`)
+
+// ParseDelete parses "DELETE FROM collection WHERE ... [LIMIT 1]" into a DeleteBuilder.
+// It deletes every matching document unless LIMIT 1 restricts it to DeleteOne.
+func (sp *SQLParser) ParseDelete() (_ *builder.DeleteBuilder, err error) {
+	defer recoverParse(&err)
+	statement, err := sp.applyDirectives(sp.query)
+	if err != nil {
+		return nil, err
+	}
+	matches := deleteStatement.FindStringSubmatch(statement)
+	if matches == nil {
+		return nil, sp.errorAt("", "invalid DELETE statement", firstWord(statement), "DELETE FROM")
+	}
+	db := builder.NewDeleteBuilder(sp.name(matches[1])).SetMulti(true)
+	rest := strings.TrimSpace(matches[2])
+
+	if index := indexTopLevel(rest, "LIMIT"); index != -1 {
+		limit, err := sp.parseLimit(strings.TrimSpace(rest[index+len("LIMIT"):]))
+		if err != nil {
+			return nil, err
+		}
+		if limit != 1 {
+			return nil, sp.errorAt("LIMIT", "DELETE only supports LIMIT 1", strings.TrimSpace(rest[index+len("LIMIT"):]), "1")
+		}
+		db.SetMulti(false)
+		rest = strings.TrimSpace(rest[:index])
+	}
+
+	if rest == "" {
+		if sp.session.SafeUpdates {
+			return nil, errors.New("DELETE without WHERE is not allowed with safe_updates")
+		}
+		return db, nil
+	}
+	if indexTopLevel(rest, "WHERE") != 0 {
+		return nil, sp.errorAt(matches[1], "invalid DELETE clause", firstWord(rest), "WHERE", "LIMIT")
+	}
+	condition, params := sp.boundCondition(strings.TrimSpace(rest[len("WHERE"):]))
+	db.StringIDs(sp.session.StringIDs).MixedIDs(!sp.session.ExactIDs).TimeZone(sp.session.Timezone).Where(condition, params)
+	if err := builderError(db.Err()); err != nil {
+		return nil, err
+	}
+	db.Filter = sp.bindValues(db.Filter).(map[string]interface{})
+	return db, nil
+}
@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// setStatement matches a "SET name = value" directive.
+	setStatement = regexp.MustCompile(`(?is)^SET\s+(\w+)\s*=\s*(.+)$`)
+
+	// useStatement matches a "USE database" directive.
+	useStatement = regexp.MustCompile(`(?is)^USE\s+(` + identifierPattern + `)$`)
+
+	// statementOption matches a trailing "OPTION (MAX_TIME_MS 500)" clause.
+	statementOption = regexp.MustCompile(`(?is)\s*OPTION\s*\(\s*(\w+)\s+(\w+)\s*\)\s*$`)
+)
+
+// applyDirectives runs the SET and USE directives preceding the final statement against the
+// session and strips the statement's OPTION clause, returning the statement left to parse.
+func (sp *SQLParser) applyDirectives(query string) (string, error) {
+	if offset := strings.IndexByte(sp.source, 0); offset != -1 {
+		return "", &ParseError{Message: "unexpected NUL byte", Offset: offset} // BSON names cannot hold it
+	}
+	if offset := unterminatedQuote(sp.source); offset != -1 {
+		message := "unterminated string literal"
+		if sp.source[offset] == '`' {
+			message = "unterminated quoted identifier"
+		}
+		return "", &ParseError{Message: message, Offset: offset}
+	}
+	query, err := sp.markPlaceholders(query)
+	if err != nil {
+		return "", err
+	}
+	statements := splitStatements(query)
+	if len(statements) == 0 {
+		return "", sp.errorAt("", "empty query", "", "statement")
+	}
+
+	for _, statement := range statements[:len(statements)-1] {
+		if !sp.isDirective(statement) {
+			return "", sp.errorAt("", "only SET and USE statements may precede the query", firstWord(statement), "SET", "USE")
+		}
+		if err := sp.applyDirective(statement); err != nil {
+			return "", err
+		}
+	}
+
+	statement := statements[len(statements)-1]
+	if matches := statementOption.FindStringSubmatch(statement); matches != nil {
+		if err := sp.setOption(matches[1], matches[2]); err != nil {
+			return "", err
+		}
+		statement = strings.TrimSpace(statement[:len(statement)-len(matches[0])])
+	}
+	return statement, nil
+}
+
+// isDirective reports whether statement is a SET or USE directive.
+func (sp *SQLParser) isDirective(statement string) bool {
+	return setStatement.MatchString(statement) || useStatement.MatchString(statement)
+}
+
+// applyDirective applies a SET or USE directive to the session.
+func (sp *SQLParser) applyDirective(statement string) error {
+	if matches := useStatement.FindStringSubmatch(statement); matches != nil {
+		return sp.session.Set("database", sp.name(matches[1]))
+	}
+	if matches := setStatement.FindStringSubmatch(statement); matches != nil {
+		if value, ok := sp.boundValue(matches[2]); ok {
+			return sp.session.Set(matches[1], fmt.Sprint(value))
+		}
+		return sp.session.Set(matches[1], matches[2])
+	}
+	return errors.New("invalid directive")
+}
+
+// setOption applies a per-statement OPTION setting, which overrides the session for this statement only.
+func (sp *SQLParser) setOption(name, value string) error {
+	switch strings.ToLower(name) {
+	case "max_time_ms":
+		ms, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil || ms < 0 {
+			return sp.errorAt("OPTION", "invalid max_time_ms value", value, "integer")
+		}
+		sp.maxTimeMS = ms
+		return nil
+	case "memo":
+		switch strings.ToUpper(value) {
+		case "ON":
+			sp.noMemo = false
+		case "OFF":
+			sp.noMemo = true
+		default:
+			return sp.errorAt("OPTION", "invalid memo value", value, "ON", "OFF")
+		}
+		return nil
+	default:
+		return sp.errorAt("OPTION", "unknown option", name, "MAX_TIME_MS", "MEMO")
+	}
+}
+
+// splitStatements splits a script on semicolons outside quoted strings, dropping empty statements.
+func splitStatements(script string) []string {
+	statements := []string{}
+	start := 0
+	for i := 0; i <= len(script); i++ {
+		if i < len(script) {
+			if isQuote(script[i]) || script[i] == '`' {
+				i = min(skipQuoted(script, i), len(script)-1) // An unterminated quote ends the last statement
+				continue
+			}
+			if script[i] != ';' {
+				continue
+			}
+		}
+		if statement := strings.TrimSpace(script[start:i]); statement != "" {
+			statements = append(statements, statement)
+		}
+		start = i + 1
+	}
+	return statements
+}
+
+// effectiveMaxTimeMS returns the statement's OPTION time limit, or the session default.
+func (sp *SQLParser) effectiveMaxTimeMS() int64 {
+	if sp.maxTimeMS > 0 {
+		return sp.maxTimeMS
+	}
+	return sp.session.MaxTimeMS
+}
@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/brothergiez/mongoquery/builder"
+)
+
+// ParseError describes a syntax error in an SQL statement and where it occurred.
+type ParseError struct {
+	Message  string   // What is wrong, e.g. "invalid LIMIT value"
+	Token    string   // The offending token, empty at the end of the query
+	Offset   int      // Byte offset of Token in the query given to NewSQLParser, -1 if unknown
+	Expected []string // Tokens that would have been accepted, if known
+}
+
+// Error formats the error with its position, e.g. `invalid LIMIT value at offset 31 near "ten" (expected integer)`.
+func (e *ParseError) Error() string {
+	var message strings.Builder
+	message.WriteString(e.Message)
+	if e.Offset >= 0 {
+		fmt.Fprintf(&message, " at offset %d", e.Offset)
+	}
+	if e.Token != "" {
+		fmt.Fprintf(&message, " near %q", e.Token)
+	}
+	if len(e.Expected) > 0 {
+		fmt.Fprintf(&message, " (expected %s)", strings.Join(e.Expected, " or "))
+	}
+	return message.String()
+}
+
+// errorAt returns a ParseError for token, locating it in the original query after keyword
+// (from the start when keyword is empty). Placeholder markers are reported as "?".
+func (sp *SQLParser) errorAt(keyword, message, token string, expected ...string) error {
+	token = strings.TrimSpace(token)
+	offset := -1
+	if matches := bindMarker.FindStringSubmatch(token); matches != nil {
+		index, _ := strconv.Atoi(matches[1])
+		token, offset = "?", placeholderOffset(sp.source, index)
+	} else {
+		start := 0
+		if keyword != "" {
+			if index := indexTopLevel(sp.source, keyword); index != -1 {
+				start = index + len(keyword)
+			}
+		}
+		if token == "" {
+			offset = len(sp.source)
+		} else if index := strings.Index(sp.source[start:], token); index != -1 {
+			offset = start + index
+		}
+	}
+	return &ParseError{Message: message, Token: token, Offset: offset, Expected: expected}
+}
+
+// markerText matches the placeholder markers in the errors of builders.
+var markerText = regexp.MustCompile(`\s*(\$?__mq_bind_\d+__|:mq_bind_\d+)\s*`)
+
+// builderError returns an error a builder recorded while the statement was applied, see
+// builder.QueryBuilder.Err, as a ParseError with placeholder markers shown as "?".
+func builderError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ParseError{Message: markerText.ReplaceAllString(err.Error(), " ? "), Offset: -1}
+}
+
+// recoverParse turns a panic while parsing or running statements into a ParseError in *err,
+// unless panic recovery is disabled, see builder.SetPanicRecovery. It must be deferred.
+func recoverParse(err *error) {
+	if !builder.PanicRecovery() {
+		return
+	}
+	if r := recover(); r != nil {
+		*err = &ParseError{Message: fmt.Sprintf("internal error: %v", r), Offset: -1}
+	}
+}
+
+// placeholderOffset returns the byte offset of the n-th "?" placeholder outside quoted strings.
+func placeholderOffset(query string, n int) int {
+	for i := 0; i < len(query); i++ {
+		switch {
+		case isQuote(query[i]) || query[i] == '`':
+			i = skipQuoted(query, i)
+		case query[i] == '?':
+			if n == 0 {
+				return i
+			}
+			n--
+		}
+	}
+	return -1
+}
+
+// firstWord returns the first whitespace-separated word of a statement.
+func firstWord(statement string) string {
+	if fields := strings.Fields(statement); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/brothergiez/mongoquery/builder"
+)
+
+// fragmentReference matches an included fragment like "active_paying(?, 'gold')" or "active_paying".
+var fragmentReference = regexp.MustCompile(`(?s)^(` + identifierPattern + `)\s*(?:\((.*)\))?$`)
+
+// applyIncludes adds the fragments of the "INCLUDE name(args), ..." clauses following the joins,
+// see builder.RegisterFragment, and returns the rest of the query.
+func (sp *SQLParser) applyIncludes(qb *builder.QueryBuilder, query string) (string, error) {
+	for strings.EqualFold(firstWord(query), "INCLUDE") {
+		clause, rest := sp.extractClause("INCLUDE", query)
+		for _, reference := range splitList(clause) {
+			if err := sp.applyInclude(qb, reference); err != nil {
+				return "", err
+			}
+		}
+		query = rest
+	}
+	if indexTopLevel(query, "INCLUDE") != -1 {
+		return "", sp.errorAt("", "INCLUDE must follow FROM and JOIN", "INCLUDE", "WHERE")
+	}
+	return query, nil
+}
+
+// applyInclude adds one fragment, with arguments that are literals or bound values.
+func (sp *SQLParser) applyInclude(qb *builder.QueryBuilder, reference string) error {
+	matches := fragmentReference.FindStringSubmatch(reference)
+	if matches == nil {
+		return sp.errorAt("INCLUDE", "invalid fragment reference", reference, "name(arguments)")
+	}
+	fragment, ok := builder.LookupFragment(sp.name(matches[1]))
+	if !ok {
+		return sp.errorAt("INCLUDE", "unknown fragment", matches[1])
+	}
+
+	args := []interface{}{}
+	if strings.TrimSpace(matches[2]) != "" {
+		for _, arg := range splitList(matches[2]) {
+			value, ok := sp.boundValue(arg)
+			if !ok {
+				value, ok = parseLiteral(arg)
+			}
+			if !ok {
+				return sp.errorAt("INCLUDE", "invalid fragment argument", arg, "literal", "?")
+			}
+			args = append(args, value)
+		}
+	}
+	if len(args) != len(fragment.Params) {
+		return sp.errorAt("INCLUDE", "wrong number of fragment arguments", reference, fragment.Params...)
+	}
+	qb.Apply(fragment, args...)
+	return nil
+}
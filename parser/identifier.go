@@ -0,0 +1,44 @@
+package parser
+
+import "strings"
+
+// identifierPattern matches a collection or field name: letters (including non-ASCII ones),
+// digits and underscores, or any text quoted in backticks such as `straße`.
+const identifierPattern = "(?:`(?:[^`]|``)+`|[\\p{L}_][\\p{L}\\p{N}\\p{Mn}_]*)"
+
+// pathPattern matches a dotted field path like "address.`código postal`".
+const pathPattern = identifierPattern + `(?:\.` + identifierPattern + `)*`
+
+// unquoteIdentifier removes the backticks from the quoted segments of a name or path.
+func unquoteIdentifier(name string) string {
+	if !strings.Contains(name, "`") {
+		return name
+	}
+	var unquoted strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] != '`' {
+			unquoted.WriteByte(name[i])
+			continue
+		}
+		for i++; i < len(name); i++ {
+			if name[i] == '`' {
+				if i+1 < len(name) && name[i+1] == '`' {
+					i++
+				} else {
+					break
+				}
+			}
+			unquoted.WriteByte(name[i])
+		}
+	}
+	return unquoted.String()
+}
+
+// name unquotes a collection or field name and applies the session's normalization form.
+func (sp *SQLParser) name(raw string) string {
+	name := unquoteIdentifier(raw)
+	if sp.session.NameForm != nil {
+		name = sp.session.NameForm.String(name)
+	}
+	return name
+}
@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/brothergiez/mongoquery/builder"
+)
+
+// insertStatement matches "INSERT INTO collection (fields) VALUES ...".
+var insertStatement = regexp.MustCompile(`(?is)^INSERT\s+INTO\s+(` + identifierPattern + `)\s*\(([^)]*)\)\s*VALUES\s*(.+)$`)
+
+// ParseInsert parses "INSERT INTO collection (a, b) VALUES (1, 'x'), (2, 'y')" into an
+// InsertBuilder, converting each literal to its Go type.
+func (sp *SQLParser) ParseInsert() (_ *builder.InsertBuilder, err error) {
+	defer recoverParse(&err)
+	statement, err := sp.applyDirectives(sp.query)
+	if err != nil {
+		return nil, err
+	}
+	matches := insertStatement.FindStringSubmatch(statement)
+	if matches == nil {
+		return nil, sp.errorAt("", "invalid INSERT statement", firstWord(statement), "INSERT INTO collection (fields) VALUES")
+	}
+
+	fields := splitList(matches[2])
+	for i, field := range fields {
+		if !fieldReference.MatchString(field) {
+			return nil, sp.errorAt(matches[1], "invalid INSERT field", field, "field name")
+		}
+		fields[i] = sp.name(field)
+	}
+	ib := builder.NewInsertBuilder().InsertInto(sp.name(matches[1]), fields)
+
+	for _, row := range splitList(matches[3]) {
+		if !strings.HasPrefix(row, "(") || !strings.HasSuffix(row, ")") {
+			return nil, sp.errorAt("VALUES", "invalid VALUES row", row, "(")
+		}
+		tokens := splitList(row[1 : len(row)-1])
+		if len(tokens) != len(fields) {
+			return nil, sp.errorAt("VALUES", fmt.Sprintf("VALUES row has %d values for %d fields", len(tokens), len(fields)), row)
+		}
+
+		values := make([]interface{}, len(tokens))
+		for i, token := range tokens {
+			value, ok := sp.boundValue(token)
+			if !ok {
+				value, ok = parseLiteral(token)
+			}
+			if !ok {
+				return nil, sp.errorAt("VALUES", "invalid literal in VALUES", token, "string", "number", "TRUE", "FALSE", "NULL", "?")
+			}
+			values[i] = value
+		}
+		ib.Values(values)
+	}
+	return ib, nil
+}
@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/brothergiez/mongoquery/builder"
+)
+
+var (
+	// joinClause matches the start of "[INNER | LEFT | RIGHT | FULL [OUTER]] JOIN collection [AS alias] ON".
+	joinClause = regexp.MustCompile(`(?is)^(?:(INNER|LEFT|RIGHT|FULL)(?:\s+OUTER)?\s+)?JOIN\s+(` + identifierPattern + `)(?:\s+(?:AS\s+)?(` + identifierPattern + `))?\s+ON\s+`)
+
+	// joinEquality matches a single equality ON condition like "orders.user_id = users._id".
+	joinEquality = regexp.MustCompile(`^(` + pathPattern + `)\s*=\s*(` + pathPattern + `)$`)
+
+	// qualifiedField matches a field qualified with a collection or alias like "o.total".
+	qualifiedField = regexp.MustCompile(`(` + identifierPattern + `)\.` + identifierPattern)
+)
+
+// joinKeywords start the next JOIN of a FROM clause.
+var joinKeywords = []string{"JOIN", "INNER", "LEFT", "RIGHT", "FULL"}
+
+// applyJoins parses the JOIN clauses following the FROM collection and returns the rest of the query.
+// Joined rows are flattened like in SQL: INNER JOIN drops unmatched documents, the outer joins keep them.
+func (sp *SQLParser) applyJoins(qb *builder.QueryBuilder, query string) (string, error) {
+	for {
+		matches := joinClause.FindStringSubmatchIndex(query)
+		if matches == nil {
+			return query, nil
+		}
+		kind := ""
+		if matches[2] != -1 {
+			kind = strings.ToUpper(query[matches[2]:matches[3]])
+		}
+		collection := sp.name(query[matches[4]:matches[5]])
+		as := collection
+		if matches[6] != -1 {
+			as = sp.name(query[matches[6]:matches[7]])
+		}
+
+		rest := query[matches[1]:]
+		end := sp.findNextKeyword(rest)
+		for _, keyword := range joinKeywords {
+			if index := indexTopLevel(rest, keyword); index != -1 && (end == -1 || index < end) {
+				end = index
+			}
+		}
+		on := rest
+		query = ""
+		if end != -1 {
+			on, query = rest[:end], rest[end:]
+		}
+
+		if err := sp.applyJoin(qb, kind, collection, as, strings.TrimSpace(on)); err != nil {
+			return "", err
+		}
+		query = strings.TrimSpace(query)
+	}
+}
+
+// applyJoin adds one JOIN to the query builder.
+func (sp *SQLParser) applyJoin(qb *builder.QueryBuilder, kind, collection, as, on string) error {
+	if sp.qualifiers == nil {
+		sp.qualifiers = []string{qb.Collection}
+		if qb.Alias != "" {
+			sp.qualifiers = append(sp.qualifiers, qb.Alias)
+		}
+	}
+	sp.qualifiers = append(sp.qualifiers, collection)
+	if as != collection {
+		sp.qualifiers = append(sp.qualifiers, as)
+	}
+
+	if kind == "" || kind == "INNER" {
+		qb.SetJoinShape(builder.JoinInner)
+	} else {
+		qb.SetJoinShape(builder.JoinFlat)
+	}
+
+	localField, foreignField, ok := sp.joinFields(on, collection, as)
+	switch {
+	case ok && kind == "RIGHT":
+		qb.RightJoin(localField, collection, foreignField, as)
+	case ok && kind == "FULL":
+		qb.FullOuterJoin(localField, collection, foreignField, as)
+	case ok:
+		qb.Join(localField, collection, foreignField, as)
+	case kind == "RIGHT" || kind == "FULL":
+		return sp.errorAt("ON", kind+" JOIN requires a single equality ON condition", on, "field = field")
+	default:
+		qb.JoinOn(collection, as, on)
+	}
+	return nil
+}
+
+// joinFields splits a single equality ON condition into the local field and the field of the
+// joined collection, which is the side qualified with its alias or name.
+func (sp *SQLParser) joinFields(on, collection, as string) (string, string, bool) {
+	matches := joinEquality.FindStringSubmatch(on)
+	if matches == nil {
+		return "", "", false
+	}
+	matches[1], matches[2] = sp.name(matches[1]), sp.name(matches[2])
+	joinedField := func(operand string) (string, bool) {
+		qualifier, field, ok := strings.Cut(operand, ".")
+		return field, ok && (qualifier == as || qualifier == collection)
+	}
+
+	if field, ok := joinedField(matches[2]); ok {
+		if _, both := joinedField(matches[1]); !both {
+			return matches[1], field, true
+		}
+	}
+	if field, ok := joinedField(matches[1]); ok {
+		if _, both := joinedField(matches[2]); !both {
+			return matches[2], field, true
+		}
+	}
+	return "", "", false
+}
+
+// checkQualifiers verifies that the qualified fields of a clause of a joined query refer to the
+// FROM or JOIN collections or their aliases, so a mistyped alias does not silently match nothing.
+// Without joins, a dotted name is a path into an embedded document and is not checked.
+func (sp *SQLParser) checkQualifiers(keyword, clause string) error {
+	if sp.qualifiers == nil {
+		return nil
+	}
+
+	// Blank out string literals, which may contain dots
+	unquoted := []byte(clause)
+	for i := 0; i < len(unquoted); i++ {
+		switch unquoted[i] {
+		case '`':
+			i = skipQuoted(clause, i)
+		case '\'', '"':
+			end := skipQuoted(clause, i)
+			for j := i; j <= end && j < len(unquoted); j++ {
+				unquoted[j] = ' '
+			}
+			i = end
+		}
+	}
+
+	for _, match := range qualifiedField.FindAllSubmatchIndex(unquoted, -1) {
+		if match[0] > 0 && isWordByte(unquoted[match[0]-1]) {
+			continue // Inside a longer word, e.g. a number
+		}
+		qualifier := sp.name(clause[match[2]:match[3]])
+		known := false
+		for _, name := range sp.qualifiers {
+			known = known || name == qualifier
+		}
+		if !known {
+			return sp.errorAt(keyword, "unknown collection or alias "+qualifier, clause[match[0]:match[1]], sp.qualifiers...)
+		}
+	}
+	return nil
+}
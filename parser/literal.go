@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// numericLiteral matches a number with optional sign, fraction and exponent: -5, .5, 1e6, 2.5E-3.
+var numericLiteral = regexp.MustCompile(`^[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?$`)
+
+// parseLiteral converts an SQL literal (quoted string, number, TRUE, FALSE or NULL) into a
+// Go value. It reports false when token is not a literal, e.g. a field name.
+func parseLiteral(token string) (interface{}, bool) {
+	token = strings.TrimSpace(token)
+	if len(token) >= 2 && isQuote(token[0]) && skipQuoted(token, 0) == len(token)-1 {
+		return unquote(token), true
+	}
+	if numericLiteral.MatchString(token) {
+		if num, err := strconv.ParseInt(token, 10, 64); err == nil {
+			return num, true
+		}
+		if num, err := strconv.ParseFloat(token, 64); err == nil {
+			return num, true
+		}
+	}
+	switch strings.ToUpper(token) {
+	case "TRUE":
+		return true, true
+	case "FALSE":
+		return false, true
+	case "NULL":
+		return nil, true
+	}
+	return nil, false
+}
+
+// unquote returns the text of a quoted string literal, resolving doubled-quote and backslash
+// escapes, including \n, \t and \r.
+func unquote(literal string) string {
+	quote := literal[0]
+	var text strings.Builder
+	for i := 1; i < len(literal)-1; i++ {
+		c := literal[i]
+		if c == '\\' && i+1 < len(literal)-1 {
+			i++
+			c = escapeSequence(literal[i])
+		} else if c == quote && literal[i+1] == quote && i+1 < len(literal)-1 {
+			i++
+		}
+		text.WriteByte(c)
+	}
+	return text.String()
+}
+
+// escapeSequence returns the character a backslash escape like \n stands for.
+func escapeSequence(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	}
+	return c
+}
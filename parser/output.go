@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WriteRows writes the rows of a SELECT in the session's OutputFormat, set with
+// SET output_format: an aligned text table, a JSON array in relaxed Extended JSON, or CSV with
+// a header. Columns are the fields of all rows, _id first and the others sorted.
+func (s *Session) WriteRows(w io.Writer, rows []map[string]interface{}) error {
+	if s.OutputFormat == "json" {
+		return writeJSONRows(w, rows)
+	}
+
+	columns := rowColumns(rows)
+	records := [][]string{columns}
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			if value, ok := row[column]; ok {
+				record[i] = cellText(value)
+			}
+		}
+		records = append(records, record)
+	}
+
+	if s.OutputFormat == "csv" {
+		out := csv.NewWriter(w)
+		out.WriteAll(records)
+		return out.Error()
+	}
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, record := range records {
+		fmt.Fprintln(table, strings.Join(record, "\t"))
+	}
+	return table.Flush()
+}
+
+// writeJSONRows writes the rows as a JSON array, one row per line.
+func writeJSONRows(w io.Writer, rows []map[string]interface{}) error {
+	var out strings.Builder
+	out.WriteString("[")
+	for i, row := range rows {
+		encoded, err := bson.MarshalExtJSON(row, false, false)
+		if err != nil {
+			return fmt.Errorf("failed to encode row %d: %v", i, err)
+		}
+		if i > 0 {
+			out.WriteString(",")
+		}
+		out.WriteString("\n  ")
+		out.Write(encoded)
+	}
+	out.WriteString("\n]\n")
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// rowColumns returns the fields of the rows, _id first and the others sorted.
+func rowColumns(rows []map[string]interface{}) []string {
+	seen := map[string]bool{}
+	columns := []string{}
+	for _, row := range rows {
+		for field := range row {
+			if !seen[field] {
+				seen[field] = true
+				columns = append(columns, field)
+			}
+		}
+	}
+	slices.SortFunc(columns, func(a, b string) int {
+		switch {
+		case a == "_id":
+			return -1
+		case b == "_id":
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+	return columns
+}
+
+// cellText renders a field value in a table or CSV cell: scalars as text, ObjectIDs in hex,
+// dates in RFC 3339 and subdocuments and arrays as relaxed Extended JSON.
+func cellText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return v
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.DateTime:
+		return v.Time().UTC().Format(time.RFC3339Nano)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case map[string]interface{}, bson.M, bson.D, bson.A, []interface{}:
+		encoded, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: v}}, false, false)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		// Strip the {"v": ...} wrapper, needed since MarshalExtJSON only encodes documents
+		return string(encoded[len(`{"v":`) : len(encoded)-1])
+	}
+	return fmt.Sprint(value)
+}
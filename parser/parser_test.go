@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/brothergiez/mongoquery/builder"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// sqlExamples are the statements of the README, seeding the fuzz targets.
+var sqlExamples = []string{
+	"SELECT field1, field2 FROM collection WHERE field1 = 'value' ORDER BY field2 DESC LIMIT 10",
+	"SELECT * FROM orders WHERE customer_id = ?",
+	"SELECT * FROM orders WHERE status = 'active'",
+	"SELECT * FROM orders o JOIN users u ON o.user_id = u._id",
+	"SELECT * FROM users u LEFT JOIN orders o ON o.user_id = u._id WHERE o.total > 100 AND u.active = 1",
+	"SELECT * FROM users WHERE age > ? AND status = ? LIMIT ?",
+	"SELECT * FROM users LIMIT ten",
+	"SELECT * FROM plans WHERE max_users >= @active",
+	"SELECT COUNT(*) INTO @active FROM users WHERE active = 1",
+	"SELECT name FROM customers INCLUDE active_paying(?) WHERE country = 'ID'",
+	"SELECT status, SUM(amount) AS total, AVG(amount) AS avg FROM orders GROUP BY status",
+	"SELECT status, SUM(amount) AS total FROM orders WHERE amount > 10 GROUP BY status HAVING total > 100",
+	"SELECT *, SUM(amount) AS total FROM orders",
+	"SELECT address.city AS city FROM users WHERE address.city = 'Jakarta' ORDER BY meta.created_at DESC",
+	"SELECT * FROM users OPTION (MAX_TIME_MS 500)",
+	"SELECT * FROM users WHERE name LIKE 'A%' AND deleted_at IS NULL AND role IN ('admin', 'owner')",
+	"SELECT * FROM orders WHERE amount > ALL (SELECT amount FROM refunds)",
+	"select * from users where sum(amount) > 10",
+}
+
+// conditionExamples are WHERE conditions seeding FuzzMatch.
+var conditionExamples = []string{
+	"status != 'archived'",
+	"age > 18 AND (status = 'active' OR role IN ('admin', 'owner'))",
+	"name LIKE '%son' AND deleted_at IS NOT NULL",
+	"price * qty >= 100 OR NOT (total BETWEEN 1 AND 5)",
+	"created_at > '2024-01-01' AND tags = ?",
+	"`address`.`city` = \"Jakarta\"",
+}
+
+// FuzzParseSQL checks that any statement either fails to parse or builds a pipeline the driver
+// can marshal, without panicking.
+func FuzzParseSQL(f *testing.F) {
+	for _, query := range sqlExamples {
+		f.Add(query)
+	}
+	builder.SetPanicRecovery(false)
+	f.Cleanup(func() { builder.SetPanicRecovery(true) })
+
+	f.Fuzz(func(t *testing.T, query string) {
+		qb, err := NewSQLParser(query).ParseSQL()
+		if err != nil {
+			return
+		}
+		assertMarshals(t, query, qb)
+	})
+}
+
+// FuzzMatch checks that any condition given to the builder either is an error of the builder
+// or builds a pipeline the driver can marshal, without panicking.
+func FuzzMatch(f *testing.F) {
+	for _, condition := range conditionExamples {
+		f.Add(condition)
+	}
+	builder.SetPanicRecovery(false)
+	f.Cleanup(func() { builder.SetPanicRecovery(true) })
+
+	f.Fuzz(func(t *testing.T, condition string) {
+		assertMarshals(t, condition, builder.NewQueryBuilder().From("items").Match(condition, "value"))
+	})
+}
+
+// assertMarshals fails t unless the pipeline of qb is an error or marshals to BSON.
+func assertMarshals(t *testing.T, input string, qb *builder.QueryBuilder) {
+	t.Helper()
+	pipeline, err := qb.ToPipeline()
+	if err != nil {
+		return
+	}
+	if _, err := bson.Marshal(bson.D{{Key: "pipeline", Value: pipeline}}); err != nil {
+		t.Errorf("%q: pipeline does not marshal: %v", input, err)
+	}
+}
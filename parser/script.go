@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/brothergiez/mongoquery/builder"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Script is a named, parameterized sequence of SQL statements, reads and writes, that run with
+// one Session, optionally inside a transaction: a lightweight substitute for stored procedures.
+//
+//	expire := parser.NewScript("expire_orders", "before").
+//		Step("expired", "UPDATE orders SET status = 'expired' WHERE status = 'open' AND created_at < @before").
+//		Step("removed", "DELETE FROM carts WHERE updated_at < @before").
+//		Step("open", "SELECT COUNT(*) INTO @open FROM orders WHERE status = 'open'").
+//		InTransaction()
+//	results, err := expire.Run(ctx, parser.NewSession(), mdb.Database, cutoff)
+type Script struct {
+	Name          string
+	Params        []string            // Parameter names, read as @name by the statements
+	Steps         []ScriptStep        // Statements in execution order
+	Transactional bool                // Run all steps in one transaction, which needs a replica set
+	Retry         builder.RetryPolicy // Retries of the transaction on transient errors, see builder.RunTransaction
+}
+
+// ScriptStep is a statement of a Script whose result is stored under Name.
+type ScriptStep struct {
+	Name      string
+	Statement string
+}
+
+// StepResult is the result of one step of a Script.
+type StepResult struct {
+	Rows        []map[string]interface{} // Documents returned by a SELECT
+	Count       int64                    // Documents returned, inserted, modified or deleted
+	InsertedIDs interface{}              // ID or IDs of the documents an INSERT inserted
+}
+
+// NewScript creates an empty Script with the given parameter names.
+func NewScript(name string, params ...string) *Script {
+	return &Script{Name: name, Params: params}
+}
+
+// Step appends a statement whose result is stored under name. Later steps read its Count as
+// @name, unless a SELECT ... INTO of the step sets the variable itself.
+func (s *Script) Step(name, statement string) *Script {
+	s.Steps = append(s.Steps, ScriptStep{Name: name, Statement: statement})
+	return s
+}
+
+// InTransaction makes the script run all of its steps in one transaction, so a failing step
+// rolls back the writes of the steps before it.
+func (s *Script) InTransaction() *Script {
+	s.Transactional = true
+	return s
+}
+
+// Run binds args to the parameters and executes the steps with session against db, or the
+// session's database after a USE step. It returns the results of the steps by name.
+func (s *Script) Run(ctx context.Context, session *Session, db *mongo.Database, args ...interface{}) (_ map[string]*StepResult, err error) {
+	defer recoverParse(&err)
+	if len(args) != len(s.Params) {
+		return nil, fmt.Errorf("script %s takes %d arguments, got %d", s.Name, len(s.Params), len(args))
+	}
+	for i, param := range s.Params {
+		session.SetVariable(param, args[i])
+	}
+
+	if !s.Transactional {
+		return s.runSteps(ctx, session, db)
+	}
+
+	var results map[string]*StepResult
+	err = builder.RunTransaction(ctx, db.Client(), s.Retry, func(sessionCtx mongo.SessionContext) error {
+		results, err = s.runSteps(sessionCtx, session, db)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// runSteps executes the steps in order, stopping at the first failing one.
+func (s *Script) runSteps(ctx context.Context, session *Session, db *mongo.Database) (map[string]*StepResult, error) {
+	results := map[string]*StepResult{}
+	for _, step := range s.Steps {
+		sp := NewSQLParser(step.Statement).WithSession(session)
+		result, err := sp.execute(ctx, session.DatabaseFor(db.Client(), db))
+		if err == nil && result != nil {
+			results[step.Name] = result
+			session.SetVariable(step.Name, result.Count)
+			err = sp.Assign(result.Rows) // Variables of SELECT ... INTO take precedence
+		}
+		if err != nil {
+			return nil, fmt.Errorf("script %s, step %s: %w", s.Name, step.Name, err)
+		}
+	}
+	return results, nil
+}
+
+// execute parses and executes the parser's statement. SET and USE statements only change the
+// session and return no result.
+func (sp *SQLParser) execute(ctx context.Context, db *mongo.Database) (*StepResult, error) {
+	switch strings.ToUpper(firstWord(sp.query)) {
+	case "SET", "USE":
+		return nil, sp.session.Exec(sp.query)
+	case "INSERT":
+		ib, err := sp.ParseInsert()
+		if err != nil {
+			return nil, err
+		}
+		ids, err := ib.WithContext(ctx).Execute(db)
+		if err != nil {
+			return nil, err
+		}
+		return &StepResult{Count: int64(len(ib.ValuesList)), InsertedIDs: ids}, nil
+	case "UPDATE":
+		ub, err := sp.ParseUpdate()
+		if err != nil {
+			return nil, err
+		}
+		modified, err := ub.WithContext(ctx).Execute(db)
+		if err != nil {
+			return nil, err
+		}
+		return &StepResult{Count: modified}, nil
+	case "DELETE":
+		deleteBuilder, err := sp.ParseDelete()
+		if err != nil {
+			return nil, err
+		}
+		deleted, err := deleteBuilder.WithContext(ctx).Execute(db)
+		if err != nil {
+			return nil, err
+		}
+		return &StepResult{Count: deleted}, nil
+	}
+
+	qb, err := sp.ParseSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := qb.WithContext(ctx).Execute(db)
+	if err != nil {
+		return nil, err
+	}
+	return &StepResult{Rows: rows, Count: int64(len(rows))}, nil
+}
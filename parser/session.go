@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brothergiez/mongoquery/builder"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Session holds settings changed by SET statements and consulted by the statements that follow.
+type Session struct {
+	Database     string           // Default database, set with SET database = 'shop' or USE shop
+	Timezone     *time.Location   // Time zone for date literals without an explicit offset
+	OutputFormat string           // Format of WriteRows: "table", "json" or "csv"
+	SafeUpdates  bool             // Refuse UPDATE and DELETE statements without a WHERE clause
+	MaxTimeMS    int64            // Default server-side time limit, 0 for none
+	NameForm     *norm.Form       // Unicode normalization of collection and field names, nil for none
+	NullMode     builder.NullMode // Translation of IS NULL, set with SET null_semantics = EXISTS
+	StringIDs    bool             // Keep hex strings as strings instead of ObjectIDs, SET string_ids = ON
+	ExactIDs     bool             // Compare _id only with the ObjectID of hex strings, SET mixed_ids = OFF
+
+	variables map[string]interface{} // Values of @variables, set by SELECT ... INTO
+}
+
+// NewSession creates a Session with default settings.
+func NewSession() *Session {
+	return &Session{
+		Timezone:     time.UTC,
+		OutputFormat: "table",
+	}
+}
+
+// Set changes a session setting by name.
+func (s *Session) Set(name, value string) error {
+	value = strings.TrimSpace(value)
+	if literal, ok := parseLiteral(value); ok {
+		if text, isString := literal.(string); isString {
+			value = text
+		}
+	}
+	switch strings.ToLower(name) {
+	case "database", "db":
+		s.Database = value
+	case "timezone", "time_zone":
+		location, err := time.LoadLocation(value)
+		if err != nil {
+			return errors.New("invalid timezone " + value)
+		}
+		s.Timezone = location
+	case "output_format", "format":
+		switch format := strings.ToLower(value); format {
+		case "table", "json", "csv":
+			s.OutputFormat = format
+		default:
+			return errors.New("invalid output_format " + value)
+		}
+	case "safe_updates":
+		enabled, err := parseSwitch(value)
+		if err != nil {
+			return err
+		}
+		s.SafeUpdates = enabled
+	case "max_time_ms":
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || ms < 0 {
+			return errors.New("invalid max_time_ms value")
+		}
+		s.MaxTimeMS = ms
+	case "normalize_names":
+		switch strings.ToUpper(value) {
+		case "NFC":
+			s.NameForm = formPtr(norm.NFC)
+		case "NFD":
+			s.NameForm = formPtr(norm.NFD)
+		case "NFKC":
+			s.NameForm = formPtr(norm.NFKC)
+		case "NFKD":
+			s.NameForm = formPtr(norm.NFKD)
+		case "OFF", "NONE":
+			s.NameForm = nil
+		default:
+			return errors.New("invalid normalize_names " + value)
+		}
+	case "null_semantics":
+		switch strings.ToUpper(value) {
+		case "EQUALITY":
+			s.NullMode = builder.NullEquality
+		case "EXISTS":
+			s.NullMode = builder.NullExists
+		default:
+			return errors.New("invalid null_semantics " + value)
+		}
+	case "string_ids":
+		enabled, err := parseSwitch(value)
+		if err != nil {
+			return err
+		}
+		s.StringIDs = enabled
+	case "mixed_ids":
+		enabled, err := parseSwitch(value)
+		if err != nil {
+			return err
+		}
+		s.ExactIDs = !enabled
+	default:
+		return errors.New("unknown setting " + name)
+	}
+	return nil
+}
+
+// Exec applies a script made only of SET and USE statements to the session.
+func (s *Session) Exec(script string) (err error) {
+	defer recoverParse(&err)
+	sp := NewSQLParser(script).WithSession(s)
+	for _, statement := range splitStatements(script) {
+		if !sp.isDirective(statement) {
+			return errors.New("not a SET or USE statement: " + statement)
+		}
+		if err := sp.applyDirective(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetVariable sets the session variable read as @name by later statements. Names are
+// case-insensitive.
+func (s *Session) SetVariable(name string, value interface{}) {
+	if s.variables == nil {
+		s.variables = map[string]interface{}{}
+	}
+	s.variables[strings.ToLower(strings.TrimPrefix(name, "@"))] = value
+}
+
+// Variable returns the value of the session variable @name.
+func (s *Session) Variable(name string) (interface{}, bool) {
+	value, ok := s.variables[strings.ToLower(strings.TrimPrefix(name, "@"))]
+	return value, ok
+}
+
+// DatabaseFor returns the session's default database on client, or fallback when none is set.
+func (s *Session) DatabaseFor(client *mongo.Client, fallback *mongo.Database) *mongo.Database {
+	if s.Database == "" || client == nil {
+		return fallback
+	}
+	return client.Database(s.Database)
+}
+
+// formPtr returns a pointer to a normalization form.
+func formPtr(form norm.Form) *norm.Form {
+	return &form
+}
+
+// parseSwitch parses ON/OFF style setting values.
+func parseSwitch(value string) (bool, error) {
+	switch strings.ToUpper(value) {
+	case "ON", "TRUE", "1":
+		return true, nil
+	case "OFF", "FALSE", "0":
+		return false, nil
+	}
+	return false, errors.New("invalid switch value " + value)
+}
@@ -0,0 +1,127 @@
+package parser
+
+import "strings"
+
+// splitTopLevel splits a clause on a keyword that appears outside parentheses and quotes.
+func splitTopLevel(clause, keyword string) []string {
+	parts := []string{}
+	for {
+		index := indexTopLevel(clause, keyword)
+		if index == -1 {
+			break
+		}
+		parts = append(parts, strings.TrimSpace(clause[:index]))
+		clause = clause[index+len(keyword):]
+	}
+	return append(parts, strings.TrimSpace(clause))
+}
+
+// indexTopLevel finds a whole-word keyword outside parentheses and quoted strings.
+func indexTopLevel(query, keyword string) int {
+	depth := 0
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case isQuote(c) || c == '`':
+			i = skipQuoted(query, i)
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && i+len(keyword) <= len(query) && strings.EqualFold(query[i:i+len(keyword)], keyword):
+			if (i == 0 || !isWordByte(query[i-1])) && (i+len(keyword) == len(query) || !isWordByte(query[i+len(keyword)])) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// skipQuoted returns the offset of the quote closing the string literal or quoted name that
+// starts at query[start]. Doubled quotes and backslash-escaped quotes do not close it.
+func skipQuoted(query string, start int) int {
+	quote := query[start]
+	for i := start + 1; i < len(query); i++ {
+		switch {
+		case query[i] == '\\':
+			i++
+		case query[i] == quote && i+1 < len(query) && query[i+1] == quote:
+			i++
+		case query[i] == quote:
+			return i
+		}
+	}
+	return len(query)
+}
+
+// unterminatedQuote returns the offset of the quote opening a string literal or quoted
+// identifier that is never closed, or -1.
+func unterminatedQuote(query string) int {
+	for i := 0; i < len(query); i++ {
+		if isQuote(query[i]) || query[i] == '`' {
+			end := skipQuoted(query, i)
+			if end == len(query) {
+				return i
+			}
+			i = end
+		}
+	}
+	return -1
+}
+
+// isQuote reports whether c opens a string literal, which may be single- or double-quoted.
+func isQuote(c byte) bool {
+	return c == '\'' || c == '"'
+}
+
+// isWordByte reports whether c can be part of an identifier. Bytes of multi-byte UTF-8
+// characters count as word bytes, so keywords are not found inside non-ASCII names.
+func isWordByte(c byte) bool {
+	return c == '_' || c == '.' || c >= 0x80 || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// collapseSpace replaces each run of whitespace outside quotes with a single space, so clause
+// keywords like "GROUP BY" are found however the statement is laid out.
+func collapseSpace(query string) string {
+	var collapsed strings.Builder
+	space := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			space = true
+			continue
+		}
+		if space && collapsed.Len() > 0 {
+			collapsed.WriteByte(' ')
+		}
+		space = false
+
+		end := i
+		if isQuote(c) || c == '`' {
+			end = min(skipQuoted(query, i), len(query)-1) // Quoted text is copied as is
+		}
+		collapsed.WriteString(query[i : end+1])
+		i = end
+	}
+	return collapsed.String()
+}
+
+// splitList splits a comma-separated list on commas outside parentheses and quotes.
+func splitList(list string) []string {
+	parts := []string{}
+	depth := 0
+	start := 0
+	for i := 0; i < len(list); i++ {
+		switch c := list[i]; {
+		case isQuote(c) || c == '`':
+			i = skipQuoted(list, i)
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(list[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(list[start:]))
+}
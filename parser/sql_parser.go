@@ -1,80 +1,245 @@
 package parser
 
 import (
-	"errors"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/brothergiez/mongoquery/builder"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
+// createTableAs matches "CREATE [OR REPLACE] TABLE name AS SELECT ...".
+var createTableAs = regexp.MustCompile(`(?is)^CREATE\s+(OR\s+REPLACE\s+)?TABLE\s+(` + identifierPattern + `)\s+AS\s+(SELECT\s.*)$`)
+
+// limitPer matches the "LIMIT n PER field" grouped top-N extension.
+var limitPer = regexp.MustCompile(`(?i)^(\d+)\s+PER\s+(` + pathPattern + `)$`)
+
 // SQLParser is a utility to parse SQL-like syntax into MongoDB query components.
 type SQLParser struct {
-	query string
+	query     string
+	source    string // The query as given, for error offsets
+	session   *Session
+	maxTimeMS int64
+	noMemo    bool // OPTION (MEMO OFF) runs the statement even under a memoizing context
+	args      []interface{}
+	marked    bool // Placeholders were already replaced by an enclosing statement
+
+	qualifiers []string       // Collections and aliases of a query with joins, nil without joins
+	into       []intoVariable // Variables set by SELECT ... INTO
 }
 
-// NewSQLParser creates a new instance of SQLParser.
+// NewSQLParser creates a new instance of SQLParser with a fresh Session.
 func NewSQLParser(query string) *SQLParser {
-	return &SQLParser{query: query}
+	return &SQLParser{query: query, source: query, session: NewSession()}
+}
+
+// WithSession makes the parser read and update session, so settings carry across statements.
+func (sp *SQLParser) WithSession(session *Session) *SQLParser {
+	sp.session = session
+	return sp
+}
+
+// Session returns the session used by the parser.
+func (sp *SQLParser) Session() *Session {
+	return sp.session
 }
 
 // ParseSQL parses an SQL-like query into a QueryBuilder.
-func (sp *SQLParser) ParseSQL() (*builder.QueryBuilder, error) {
-	sp.query = strings.TrimSpace(sp.query)
+func (sp *SQLParser) ParseSQL() (_ *builder.QueryBuilder, err error) {
+	defer recoverParse(&err)
+	query, err := sp.applyDirectives(sp.query)
+	if err != nil {
+		return nil, err
+	}
+	sp.query = collapseSpace(query)
+
+	// CREATE TABLE ... AS SELECT materializes the results with $out
+	outCollection, replace := "", false
+	if matches := createTableAs.FindStringSubmatch(sp.query); matches != nil {
+		outCollection, replace = sp.name(matches[2]), matches[1] != ""
+		sp.query = matches[3]
+	}
+
+	if !strings.EqualFold(firstWord(sp.query), "SELECT") {
+		return nil, sp.errorAt("", "invalid SELECT statement", firstWord(sp.query), "SELECT")
+	}
+
 	qb := builder.NewQueryBuilder()
+	if sp.session.NameForm != nil {
+		qb.NormalizeNames(*sp.session.NameForm)
+	}
+	qb.NullSemantics(sp.session.NullMode)
+	qb.StringIDs(sp.session.StringIDs).MixedIDs(!sp.session.ExactIDs)
+	qb.TimeZone(sp.session.Timezone)
+	if maxTimeMS := sp.effectiveMaxTimeMS(); maxTimeMS > 0 {
+		qb.MaxTime(time.Duration(maxTimeMS) * time.Millisecond)
+	}
+	if sp.noMemo {
+		qb.NoMemo()
+	}
 
 	// Parse SELECT
+	sp.query, err = sp.extractInto(sp.query)
+	if err != nil {
+		return nil, err
+	}
 	fields, rest := sp.extractFields(strings.Split(sp.query, " "))
-	qb.Fields = fields
 
 	// Parse FROM
 	collection, rest := sp.extractCollection(rest)
-	qb.Collection = collection
+	qb.From(collection)
+
+	// Parse JOIN
+	rest, err = sp.applyJoins(qb, rest)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse INCLUDE
+	rest, err = sp.applyIncludes(qb, rest)
+	if err != nil {
+		return nil, err
+	}
 
 	// Parse WHERE
-	if strings.Contains(strings.ToUpper(rest), "WHERE") {
+	if indexTopLevel(rest, "WHERE") != -1 {
 		whereClause, remaining := sp.extractClause("WHERE", rest)
-		qb.Match(strings.TrimSpace(whereClause))
+		if err := sp.applyWhere(qb, strings.TrimSpace(whereClause)); err != nil {
+			return nil, err
+		}
 		rest = remaining
 	}
 
 	// Parse GROUP BY
-	if strings.Contains(strings.ToUpper(rest), "GROUP BY") {
+	if indexTopLevel(rest, "GROUP BY") != -1 {
 		groupByClause, remaining := sp.extractClause("GROUP BY", rest)
-		qb.GroupBy(strings.TrimSpace(groupByClause))
+		groupFields := splitList(strings.TrimSpace(groupByClause))
+		for i, field := range groupFields {
+			groupFields[i] = sp.name(field)
+		}
+		aggregates := []string{}
+		for _, field := range fields {
+			if builder.IsAggregate(field) {
+				aggregates = append(aggregates, field)
+			}
+		}
+		qb.GroupBy(append(groupFields, aggregates...)...)
+		for _, field := range fields {
+			if field != "*" && !builder.IsAggregate(field) && !qb.IsGroupKey(field) {
+				return nil, sp.errorAt("SELECT", "field must appear in GROUP BY or be an aggregate function", field, groupFields...)
+			}
+		}
 		rest = remaining
 	}
 
+	// A select list of aggregates without GROUP BY returns a single row; next to * the
+	// aggregates are added to every row
+	if len(qb.Group) == 0 && isAggregateList(fields) {
+		qb.GroupAll(fields...)
+	} else if len(qb.Group) == 0 && slices.Contains(fields, "*") {
+		aggregates := []string{}
+		for _, field := range fields {
+			if builder.IsAggregate(field) {
+				aggregates = append(aggregates, field)
+			}
+		}
+		qb.WindowAggregate(aggregates...)
+	}
+
 	// Parse HAVING
-	if strings.Contains(strings.ToUpper(rest), "HAVING") {
+	if indexTopLevel(rest, "HAVING") != -1 {
 		havingClause, remaining := sp.extractClause("HAVING", rest)
 		qb.Having(strings.TrimSpace(havingClause))
 		rest = remaining
 	}
 
 	// Parse ORDER BY
-	if strings.Contains(strings.ToUpper(rest), "ORDER BY") {
+	if indexTopLevel(rest, "ORDER BY") != -1 {
 		orderByClause, remaining := sp.extractClause("ORDER BY", rest)
 		qb.OrderBy(strings.TrimSpace(orderByClause))
 		rest = remaining
 	}
 
 	// Parse LIMIT
-	if strings.Contains(strings.ToUpper(rest), "LIMIT") {
+	if indexTopLevel(rest, "LIMIT") != -1 {
 		limitClause, _ := sp.extractClause("LIMIT", rest)
-		limit, err := sp.parseLimit(strings.TrimSpace(limitClause))
+		if matches := limitPer.FindStringSubmatch(strings.TrimSpace(limitClause)); matches != nil {
+			limit, err := sp.parseLimit(matches[1])
+			if err != nil {
+				return nil, err
+			}
+			qb.LimitPer(limit, sp.name(matches[2]))
+		} else if offsetText, limitText, ok := strings.Cut(limitClause, ","); ok {
+			// MySQL-style "LIMIT offset, count"
+			offset, err := sp.parseOffset(strings.TrimSpace(offsetText))
+			if err != nil {
+				return nil, err
+			}
+			limit, err := sp.parseLimit(strings.TrimSpace(limitText))
+			if err != nil {
+				return nil, err
+			}
+			qb.Offset(offset).Limit(limit)
+		} else {
+			limit, err := sp.parseLimit(strings.TrimSpace(limitClause))
+			if err != nil {
+				return nil, err
+			}
+			qb.Limit(limit)
+		}
+	}
+
+	// Parse OFFSET, before or after LIMIT
+	if indexTopLevel(rest, "OFFSET") != -1 {
+		offsetClause, _ := sp.extractClause("OFFSET", rest)
+		offset, err := sp.parseOffset(strings.TrimSpace(offsetClause))
 		if err != nil {
 			return nil, err
 		}
-		qb.Limit(limit)
+		qb.Offset(offset)
+	}
+
+	// Grouped rows keep only the selected keys and aggregates, other rows the selected fields
+	switch {
+	case len(qb.Group) > 0 && !slices.Contains(fields, "*"):
+		qb.Fields = fields
+		qb.SelectGrouped(fields...)
+	case len(qb.Group) > 0 || slices.Contains(fields, "*"):
+		qb.Fields = fields
+	default:
+		qb.Select(fields...)
+	}
+
+	if outCollection != "" {
+		qb.Out(outCollection, !replace)
 	}
+	if err := sp.assignFields(qb); err != nil {
+		return nil, err
+	}
+	if err := builderError(qb.Err()); err != nil {
+		return nil, err
+	}
+	qb.Pipeline = sp.bindValues(qb.Pipeline).([]bson.D)
 
 	return qb, nil
 }
 
+// isAggregateList reports whether every selected field is an aggregate function.
+func isAggregateList(fields []string) bool {
+	for _, field := range fields {
+		if !builder.IsAggregate(field) {
+			return false
+		}
+	}
+	return len(fields) > 0
+}
+
 // extractFields extracts fields from the SELECT clause.
 func (sp *SQLParser) extractFields(parts []string) ([]string, string) {
-	if strings.ToUpper(parts[0]) != "SELECT" {
+	if !strings.EqualFold(parts[0], "SELECT") {
 		return nil, strings.Join(parts, " ")
 	}
 
@@ -82,10 +247,10 @@ func (sp *SQLParser) extractFields(parts []string) ([]string, string) {
 	rest := strings.Join(parts[1:], " ")
 
 	// Handle field extraction until the FROM keyword
-	fromIndex := strings.Index(strings.ToUpper(rest), " FROM ")
+	fromIndex := indexTopLevel(rest, "FROM")
 	if fromIndex != -1 {
 		fields = strings.Split(rest[:fromIndex], ",")
-		rest = rest[fromIndex+len(" FROM "):]
+		rest = rest[fromIndex+len("FROM"):]
 	}
 
 	// Trim whitespace and return
@@ -95,15 +260,52 @@ func (sp *SQLParser) extractFields(parts []string) ([]string, string) {
 	return fields, rest
 }
 
-// extractCollection extracts the collection name from the FROM clause.
+// extractCollection extracts the collection name and its optional alias from the FROM clause.
 func (sp *SQLParser) extractCollection(query string) (string, string) {
-	parts := strings.SplitN(query, " ", 2)
-	return parts[0], parts[1]
+	name, rest := nextWord(query)
+	if name == "" {
+		return "", ""
+	}
+
+	collection := sp.name(name)
+	word, afterWord := nextWord(rest)
+	if alias, afterAlias := nextWord(afterWord); strings.EqualFold(word, "AS") && alias != "" {
+		collection += " " + sp.name(alias)
+		rest = afterAlias
+	} else if word != "" && !sp.isKeyword(word) {
+		collection += " " + sp.name(word)
+		rest = afterWord
+	}
+	return collection, strings.TrimSpace(rest)
+}
+
+// nextWord splits the first space-separated word off query, keeping the rest as written.
+// Spaces inside backtick-quoted names do not end the word.
+func nextWord(query string) (string, string) {
+	query = strings.TrimSpace(query)
+	for i := 0; i < len(query); i++ {
+		switch query[i] {
+		case '`':
+			i = skipQuoted(query, i)
+		case ' ', '\t', '\n', '\r':
+			return query[:i], query[i:]
+		}
+	}
+	return query, ""
+}
+
+// isKeyword reports whether word starts an SQL clause.
+func (sp *SQLParser) isKeyword(word string) bool {
+	switch strings.ToUpper(word) {
+	case "WHERE", "GROUP", "HAVING", "ORDER", "LIMIT", "OFFSET", "JOIN", "INNER", "LEFT", "RIGHT", "FULL", "CROSS", "ON", "INCLUDE":
+		return true
+	}
+	return false
 }
 
 // extractClause extracts a clause and the remaining query after it.
 func (sp *SQLParser) extractClause(keyword string, query string) (string, string) {
-	keywordIndex := strings.Index(strings.ToUpper(query), keyword)
+	keywordIndex := indexTopLevel(query, keyword)
 	if keywordIndex == -1 {
 		return "", query
 	}
@@ -117,23 +319,44 @@ func (sp *SQLParser) extractClause(keyword string, query string) (string, string
 	return strings.TrimSpace(remaining[:nextKeywordIndex]), strings.TrimSpace(remaining[nextKeywordIndex:])
 }
 
-// findNextKeyword finds the position of the next SQL keyword.
+// findNextKeyword finds the position of the next SQL keyword outside parentheses.
 func (sp *SQLParser) findNextKeyword(query string) int {
-	keywords := []string{"WHERE", "GROUP BY", "HAVING", "ORDER BY", "LIMIT"}
+	keywords := []string{"WHERE", "GROUP BY", "HAVING", "ORDER BY", "LIMIT", "OFFSET", "INCLUDE"}
+	next := -1
 	for _, keyword := range keywords {
-		keywordIndex := strings.Index(strings.ToUpper(query), keyword)
-		if keywordIndex != -1 {
-			return keywordIndex
+		keywordIndex := indexTopLevel(query, keyword)
+		if keywordIndex != -1 && (next == -1 || keywordIndex < next) {
+			next = keywordIndex
 		}
 	}
-	return -1
+	return next
 }
 
 // parseLimit parses the LIMIT clause into an integer.
 func (sp *SQLParser) parseLimit(limit string) (int64, error) {
-	parsedLimit, err := strconv.ParseInt(limit, 10, 64)
+	return sp.parseCount("LIMIT", limit)
+}
+
+// parseOffset parses an OFFSET value into a non-negative integer.
+func (sp *SQLParser) parseOffset(offset string) (int64, error) {
+	parsedOffset, err := sp.parseCount("OFFSET", offset)
+	if err == nil && parsedOffset < 0 {
+		return 0, sp.errorAt("OFFSET", "invalid OFFSET value", offset, "non-negative integer")
+	}
+	return parsedOffset, err
+}
+
+// parseCount parses the integer value of a LIMIT or OFFSET clause, literal or bound.
+func (sp *SQLParser) parseCount(keyword, count string) (int64, error) {
+	if value, ok := sp.boundValue(count); ok {
+		if parsedCount, ok := toInt64(value); ok {
+			return parsedCount, nil
+		}
+		return 0, sp.errorAt(keyword, "invalid "+keyword+" value", count, "integer")
+	}
+	parsedCount, err := strconv.ParseInt(count, 10, 64)
 	if err != nil {
-		return 0, errors.New("invalid LIMIT value")
+		return 0, sp.errorAt(keyword, "invalid "+keyword+" value", count, "integer")
 	}
-	return parsedLimit, nil
+	return parsedCount, nil
 }
@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestKeywordCase checks that keywords and operators parse the same in any letter case.
+func TestKeywordCase(t *testing.T) {
+	tests := []struct {
+		name    string
+		queries []string
+	}{
+		{"select", []string{
+			"SELECT name FROM users WHERE age > 18 ORDER BY name DESC LIMIT 5",
+			"select name from users where age > 18 order by name desc limit 5",
+			"SeLeCt name FrOm users WhErE age > 18 OrDeR bY name DeSc LiMiT 5",
+		}},
+		{"and or not", []string{
+			"SELECT * FROM users WHERE age > 18 AND (active = 1 OR NOT role = 'guest')",
+			"SELECT * FROM users WHERE age > 18 and (active = 1 or not role = 'guest')",
+			"SELECT * FROM users WHERE age > 18 AnD (active = 1 oR NoT role = 'guest')",
+		}},
+		{"like", []string{
+			"SELECT * FROM users WHERE name LIKE 'A%' AND email NOT LIKE '%@test.com'",
+			"SELECT * FROM users WHERE name like 'A%' AND email not like '%@test.com'",
+			"SELECT * FROM users WHERE name LiKe 'A%' AND email NoT lIkE '%@test.com'",
+		}},
+		{"in", []string{
+			"SELECT * FROM users WHERE role IN ('admin', 'owner') AND id NOT IN (1, 2)",
+			"SELECT * FROM users WHERE role in ('admin', 'owner') AND id not in (1, 2)",
+			"SELECT * FROM users WHERE role In ('admin', 'owner') AND id nOt iN (1, 2)",
+		}},
+		{"is null", []string{
+			"SELECT * FROM users WHERE deleted_at IS NULL AND verified_at IS NOT NULL",
+			"SELECT * FROM users WHERE deleted_at is null AND verified_at is not null",
+			"SELECT * FROM users WHERE deleted_at Is NuLl AND verified_at iS NoT nUlL",
+		}},
+		{"between", []string{
+			"SELECT * FROM orders WHERE amount BETWEEN 10 AND 20",
+			"SELECT * FROM orders WHERE amount between 10 and 20",
+			"SELECT * FROM orders WHERE amount BeTwEeN 10 aNd 20",
+		}},
+		{"group by having", []string{
+			"SELECT status, SUM(amount) AS total FROM orders GROUP BY status HAVING total > 100",
+			"select status, sum(amount) as total from orders group by status having total > 100",
+			"SeLeCt status, SuM(amount) aS total FrOm orders GrOuP By status HaViNg total > 100",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want []bson.D
+			for i, query := range tt.queries {
+				qb, err := NewSQLParser(query).ParseSQL()
+				if err != nil {
+					t.Fatalf("%q: %v", query, err)
+				}
+				pipeline, err := qb.ToPipeline()
+				if err != nil {
+					t.Fatalf("%q: %v", query, err)
+				}
+				if i == 0 {
+					want = pipeline
+					continue
+				}
+				if !reflect.DeepEqual(pipeline, want) {
+					t.Errorf("%q:\ngot  %v\nwant %v", query, pipeline, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/brothergiez/mongoquery/builder"
+)
+
+// subqueryComparison matches "field op ANY|ALL|SOME (SELECT ...)".
+var subqueryComparison = regexp.MustCompile(`(?is)^(` + pathPattern + `)\s*(=|!=|<>|>=|<=|>|<)\s*(ANY|ALL|SOME)\s*\((\s*SELECT\s.*)\)$`)
+
+// nestedSelect detects a subquery left in a plain condition.
+var nestedSelect = regexp.MustCompile(`(?i)\(\s*SELECT\s`)
+
+// applyWhere adds the WHERE clause to the QueryBuilder, compiling subquery comparisons separately.
+func (sp *SQLParser) applyWhere(qb *builder.QueryBuilder, clause string) error {
+	conditions := []string{}
+	subqueries := [][]string{}
+	for _, part := range splitTopLevel(clause, "AND") {
+		if matches := subqueryComparison.FindStringSubmatch(part); matches != nil {
+			subqueries = append(subqueries, matches)
+		} else {
+			conditions = append(conditions, part)
+		}
+	}
+
+	if len(subqueries) > 0 && indexTopLevel(clause, "OR") != -1 {
+		return sp.errorAt("WHERE", "subqueries are only supported as ANDed ANY/ALL comparisons", "OR", "AND")
+	}
+
+	// Plain conditions go first so they can use indexes before the $lookup stages
+	if len(conditions) > 0 {
+		rest := strings.Join(conditions, " AND ")
+		if nestedSelect.MatchString(rest) {
+			return sp.errorAt("WHERE", "subqueries are only supported as ANDed ANY/ALL comparisons", nestedSelect.FindString(rest), "ANY", "ALL")
+		}
+		if err := sp.checkQualifiers("WHERE", rest); err != nil {
+			return err
+		}
+		condition, params := sp.boundCondition(rest)
+		qb.Match(condition, params)
+	}
+
+	for _, matches := range subqueries {
+		subParser := NewSQLParser(matches[4]).WithSession(sp.session)
+		subParser.source, subParser.args, subParser.marked = sp.source, sp.args, true
+		sub, err := subParser.ParseSQL()
+		if err != nil {
+			return err
+		}
+		if len(sub.Fields) != 1 {
+			return sp.errorAt("WHERE", "subquery must select exactly one field", matches[4])
+		}
+		qb.MatchSubquery(sp.name(matches[1]), matches[2], matches[3], sub)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/brothergiez/mongoquery/builder"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	// updateStatement matches "UPDATE collection SET ...".
+	updateStatement = regexp.MustCompile(`(?is)^UPDATE\s+(` + identifierPattern + `)\s+SET\s+(.+)$`)
+
+	// assignment matches a single "field = value" SET assignment.
+	assignment = regexp.MustCompile(`(?s)^(` + pathPattern + `)\s*=\s*(.+)$`)
+
+	// selfArithmetic matches arithmetic on the assigned field itself, e.g. "age + 1".
+	selfArithmetic = regexp.MustCompile(`^(` + pathPattern + `)\s*([-+*/])\s*(\S+)$`)
+
+	// fieldReference matches a plain (possibly dotted) field name.
+	fieldReference = regexp.MustCompile(`^` + pathPattern + `$`)
+)
+
+// ParseUpdate parses "UPDATE collection SET a = 1, b = other REMOVE other WHERE ..." into an
+// UpdateBuilder. Assigning another field copies its current value, and REMOVE unsets fields,
+// so "SET newField = oldField REMOVE oldField" renames a field in place. Arithmetic on the
+// assigned field itself ("age = age + 1", "price = price * 2") maps to $inc and $mul.
+func (sp *SQLParser) ParseUpdate() (_ *builder.UpdateBuilder, err error) {
+	defer recoverParse(&err)
+	statement, err := sp.applyDirectives(sp.query)
+	if err != nil {
+		return nil, err
+	}
+	matches := updateStatement.FindStringSubmatch(statement)
+	if matches == nil {
+		return nil, sp.errorAt("", "invalid UPDATE statement", firstWord(statement), "UPDATE collection SET")
+	}
+	ub := builder.NewUpdateBuilder(sp.name(matches[1]))
+	rest := matches[2]
+
+	whereClause := ""
+	if index := indexTopLevel(rest, "WHERE"); index != -1 {
+		whereClause = strings.TrimSpace(rest[index+len("WHERE"):])
+		rest = rest[:index]
+	}
+	removeClause := ""
+	if index := indexTopLevel(rest, "REMOVE"); index != -1 {
+		removeClause = strings.TrimSpace(rest[index+len("REMOVE"):])
+		rest = rest[:index]
+	}
+
+	values := map[string]interface{}{}
+	for _, part := range splitList(rest) {
+		matches := assignment.FindStringSubmatch(part)
+		if matches == nil {
+			return nil, sp.errorAt("SET", "invalid SET assignment", part, "field = value")
+		}
+		field, value := sp.name(matches[1]), strings.TrimSpace(matches[2])
+		if bound, ok := sp.boundValue(value); ok {
+			values[field] = bound
+		} else if literal, ok := parseLiteral(value); ok {
+			values[field] = literal
+		} else if fieldReference.MatchString(value) {
+			ub.SetFrom(field, sp.name(value))
+		} else if arithmetic := selfArithmetic.FindStringSubmatch(value); arithmetic != nil && sp.name(arithmetic[1]) == field {
+			if err := sp.applyArithmetic(ub, field, arithmetic[2], arithmetic[3]); err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, sp.errorAt("SET", "unsupported SET value", value, "literal", "field", "field + number", "?")
+		}
+	}
+	if len(values) > 0 {
+		ub.Set(values)
+	}
+
+	if removeClause != "" {
+		for _, field := range splitList(removeClause) {
+			if !fieldReference.MatchString(field) {
+				return nil, sp.errorAt("REMOVE", "invalid REMOVE field", field, "field name")
+			}
+			ub.Unset(sp.name(field))
+		}
+	}
+
+	if whereClause == "" {
+		if sp.session.SafeUpdates {
+			return nil, errors.New("UPDATE without WHERE is not allowed with safe_updates")
+		}
+	} else {
+		condition, params := sp.boundCondition(whereClause)
+		ub.StringIDs(sp.session.StringIDs).MixedIDs(!sp.session.ExactIDs).TimeZone(sp.session.Timezone).Where(condition, params)
+	}
+	if err := builderError(ub.Err()); err != nil {
+		return nil, err
+	}
+	ub.Filter = sp.bindValues(ub.Filter).(bson.M)
+	ub.SetMulti(true)
+	return ub, nil
+}
+
+// applyArithmetic maps "field <operator> operand" to $inc (for + and -), $mul (for *) or a
+// pipeline $divide (for /).
+func (sp *SQLParser) applyArithmetic(ub *builder.UpdateBuilder, field, operator, operand string) error {
+	value, ok := sp.boundValue(operand)
+	if !ok {
+		value, ok = parseLiteral(operand)
+	}
+	number, isNumber := toFloat64(value)
+	if !ok || !isNumber {
+		return sp.errorAt("SET", "unsupported SET arithmetic operand", operand, "number", "?")
+	}
+
+	switch operator {
+	case "+":
+		ub.Inc(field, value)
+	case "-":
+		ub.Inc(field, negate(value))
+	case "*":
+		ub.Mul(field, value)
+	case "/":
+		if number == 0 {
+			return sp.errorAt("SET", "division by zero in SET "+field, operand)
+		}
+		ub.Div(field, value)
+	}
+	return nil
+}
+
+// toFloat64 converts a numeric literal or bound value to a float64.
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	}
+	if n, ok := toInt64(value); ok {
+		if _, isString := value.(string); !isString {
+			return float64(n), true
+		}
+	}
+	return 0, false
+}
+
+// negate returns the negated numeric value, keeping its type.
+func negate(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return -v
+	case int8:
+		return -v
+	case int16:
+		return -v
+	case int32:
+		return -v
+	case int64:
+		return -v
+	case float32:
+		return -v
+	case float64:
+		return -v
+	}
+	number, _ := toFloat64(value)
+	return -number
+}
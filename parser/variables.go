@@ -0,0 +1,144 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/brothergiez/mongoquery/builder"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// variableReference matches a session variable like "@total" at the start of the input.
+var variableReference = regexp.MustCompile(`^@([\p{L}_][\p{L}\p{N}_]*)`)
+
+// Statements splits a script into its statements, so front-ends can run them one at a time
+// with a shared Session and have later statements read the variables earlier ones set.
+func Statements(script string) []string {
+	return splitStatements(script)
+}
+
+// extractInto removes the "INTO @a, @b" clause between the select list and FROM and records
+// the variables the result row is assigned to.
+func (sp *SQLParser) extractInto(query string) (string, error) {
+	into := indexTopLevel(query, "INTO")
+	from := indexTopLevel(query, "FROM")
+	if into == -1 || (from != -1 && into > from) {
+		return query, nil
+	}
+	if from == -1 {
+		from = len(query)
+	}
+
+	sp.into = nil
+	for _, target := range splitList(query[into+len("INTO") : from]) {
+		matches := variableReference.FindStringSubmatch(target)
+		if matches == nil || len(matches[0]) != len(target) {
+			return "", sp.errorAt("INTO", "invalid INTO target", target, "@variable")
+		}
+		sp.into = append(sp.into, intoVariable{variable: matches[1]})
+	}
+	return strings.TrimSpace(query[:into]) + " " + query[from:], nil
+}
+
+// intoVariable is a session variable set by SELECT ... INTO and the result field it is set from.
+type intoVariable struct {
+	variable string
+	key      string
+}
+
+// assignFields pairs the INTO variables with the selected fields of qb.
+func (sp *SQLParser) assignFields(qb *builder.QueryBuilder) error {
+	if len(sp.into) == 0 {
+		return nil
+	}
+	if len(sp.into) != len(qb.Fields) {
+		return sp.errorAt("INTO", fmt.Sprintf("INTO lists %d variables for %d selected fields", len(sp.into), len(qb.Fields)), "@"+sp.into[0].variable)
+	}
+	for i, field := range qb.Fields {
+		if field == "*" {
+			return sp.errorAt("", "SELECT * cannot be assigned INTO variables", "*", "field list")
+		}
+		sp.into[i].key = qb.ResultKey(field)
+	}
+	return nil
+}
+
+// Assign stores the single result row of a SELECT ... INTO statement in the session variables
+// it names, in the order of the select list. It does nothing for statements without INTO:
+//
+//	results, err := qb.Execute(db)
+//	err = sp.Assign(results) // Later statements of the session can read @c
+func (sp *SQLParser) Assign(results []map[string]interface{}) error {
+	if len(sp.into) == 0 {
+		return nil
+	}
+	if len(results) != 1 {
+		return fmt.Errorf("SELECT INTO expects exactly one row, got %d", len(results))
+	}
+	for _, target := range sp.into {
+		sp.session.SetVariable(target.variable, lookupPath(results[0], target.key))
+	}
+	return nil
+}
+
+// markVariable replaces the variable reference at query[i] with a marker bound to its value,
+// and returns the marker and the length of the reference.
+func (sp *SQLParser) markVariable(query string, i int, values *[]interface{}) (string, int, error) {
+	matches := variableReference.FindStringSubmatch(query[i:])
+	if matches == nil {
+		return "@", 1, nil
+	}
+	if intoTarget(query, i) {
+		return matches[0], len(matches[0]), nil // Assigned, not read
+	}
+	value, ok := sp.session.Variable(matches[1])
+	if !ok {
+		return "", 0, fmt.Errorf("undefined variable %s", matches[0])
+	}
+	*values = append(*values, value)
+	return " __mq_bind_" + strconv.Itoa(len(sp.args)+len(*values)-1) + "__ ", len(matches[0]), nil
+}
+
+// intoTarget reports whether the variable at query[i] is listed after INTO.
+func intoTarget(query string, i int) bool {
+	for {
+		for i > 0 && (query[i-1] == ' ' || query[i-1] == ',') {
+			i--
+		}
+		end := i
+		for i > 0 && isWordByte(query[i-1]) {
+			i--
+		}
+		if i > 0 && query[i-1] == '@' {
+			i-- // Another variable of the INTO list
+			continue
+		}
+		return strings.EqualFold(query[i:end], "INTO")
+	}
+}
+
+// lookupPath returns the value at a dotted path of a result document.
+func lookupPath(document map[string]interface{}, path string) interface{} {
+	if value, ok := document[path]; ok {
+		return value
+	}
+	head, rest, ok := strings.Cut(path, ".")
+	if !ok {
+		return nil
+	}
+	switch nested := document[head].(type) {
+	case map[string]interface{}:
+		return lookupPath(nested, rest)
+	case bson.M:
+		return lookupPath(nested, rest)
+	case bson.D:
+		document := map[string]interface{}{}
+		for _, element := range nested {
+			document[element.Key] = element.Value
+		}
+		return lookupPath(document, rest)
+	}
+	return nil
+}